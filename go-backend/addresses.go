@@ -0,0 +1,286 @@
+package main
+
+import (
+        "bytes"
+        "crypto/sha256"
+        "encoding/hex"
+        "math/big"
+        "regexp"
+        "strings"
+
+        "golang.org/x/crypto/sha3"
+)
+
+var (
+        evmAddressPattern   = regexp.MustCompile(`^0x[0-9a-fA-F]{40}$`)
+        aptosAddressPattern = regexp.MustCompile(`^0x[0-9a-fA-F]{64}$`)
+)
+
+// Address version bytes
+const (
+        btcP2PKHVersion = 0x00
+        btcP2SHVersion  = 0x05
+        tronVersion     = 0x41
+)
+
+const (
+        base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+        bech32Charset  = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+        // Checksum constants of bech32 (BIP173) and bech32m (BIP350)
+        bech32Const  = 1
+        bech32mConst = 0x2bc830a3
+)
+
+// validateAddress checks that address is well formed for network and
+// returns a message describing the problem, or ""
+func validateAddress(network, address string) string {
+        switch network {
+        case "BSC", "ETH", "ERC20":
+                if !evmAddressPattern.MatchString(address) {
+                        return "must be a 0x-prefixed 40-character hex address"
+                }
+                lower, upper := strings.ToLower(address[2:]), strings.ToUpper(address[2:])
+                if address[2:] != lower && address[2:] != upper && address != evmChecksumAddress(address) {
+                        return "has an invalid EIP-55 checksum"
+                }
+        case "TRC20":
+                version, payload, ok := base58CheckDecode(address)
+                if !ok || version != tronVersion || len(payload) != 20 {
+                        return "must be a TRON base58 address starting with T"
+                }
+        case "APTOS":
+                if !aptosAddressPattern.MatchString(address) {
+                        return "must be a 0x-prefixed 64-character hex address"
+                }
+        case "BTC":
+                if strings.HasPrefix(strings.ToLower(address), "bc1") {
+                        if _, _, ok := decodeSegwitAddress("bc", address); !ok {
+                                return "is not a valid bech32 Bitcoin address"
+                        }
+                        return ""
+                }
+                version, payload, ok := base58CheckDecode(address)
+                if !ok || (version != btcP2PKHVersion && version != btcP2SHVersion) || len(payload) != 20 {
+                        return "is not a valid Bitcoin address"
+                }
+        default:
+                return "is not supported on " + network
+        }
+        return ""
+}
+
+// keccak256 hashes data with the Keccak variant Ethereum uses
+func keccak256(data []byte) []byte {
+        h := sha3.NewLegacyKeccak256()
+        h.Write(data)
+        return h.Sum(nil)
+}
+
+// evmChecksumAddress returns the EIP-55 mixed-case form of a hex address
+func evmChecksumAddress(address string) string {
+        lower := strings.ToLower(strings.TrimPrefix(address, "0x"))
+        hash := hex.EncodeToString(keccak256([]byte(lower)))
+        out := []byte(lower)
+        for i, c := range out {
+                if c >= 'a' && hash[i] >= '8' {
+                        out[i] = c - 'a' + 'A'
+                }
+        }
+        return "0x" + string(out)
+}
+
+// doubleSHA256 is the base58check checksum hash
+func doubleSHA256(data []byte) []byte {
+        first := sha256.Sum256(data)
+        second := sha256.Sum256(first[:])
+        return second[:]
+}
+
+// base58Decode decodes a base58 string, keeping leading zero bytes
+func base58Decode(s string) ([]byte, bool) {
+        n := new(big.Int)
+        radix := big.NewInt(58)
+        for _, c := range s {
+                digit := strings.IndexRune(base58Alphabet, c)
+                if digit < 0 {
+                        return nil, false
+                }
+                n.Mul(n, radix)
+                n.Add(n, big.NewInt(int64(digit)))
+        }
+        zeros := 0
+        for zeros < len(s) && s[zeros] == base58Alphabet[0] {
+                zeros++
+        }
+        return append(make([]byte, zeros), n.Bytes()...), true
+}
+
+// base58Encode encodes data as base58, keeping leading zero bytes
+func base58Encode(data []byte) string {
+        n := new(big.Int).SetBytes(data)
+        radix := big.NewInt(58)
+        mod := new(big.Int)
+        var out []byte
+        for n.Sign() > 0 {
+                n.DivMod(n, radix, mod)
+                out = append(out, base58Alphabet[mod.Int64()])
+        }
+        for _, b := range data {
+                if b != 0 {
+                        break
+                }
+                out = append(out, base58Alphabet[0])
+        }
+        for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+                out[i], out[j] = out[j], out[i]
+        }
+        return string(out)
+}
+
+// base58CheckDecode decodes a version byte and payload and verifies the
+// 4 byte checksum
+func base58CheckDecode(s string) (version byte, payload []byte, ok bool) {
+        data, ok := base58Decode(s)
+        if !ok || len(data) < 5 {
+                return 0, nil, false
+        }
+        body, checksum := data[:len(data)-4], data[len(data)-4:]
+        if !bytes.Equal(doubleSHA256(body)[:4], checksum) {
+                return 0, nil, false
+        }
+        return body[0], body[1:], true
+}
+
+// base58CheckEncode encodes a version byte and payload with a checksum
+func base58CheckEncode(version byte, payload []byte) string {
+        body := append([]byte{version}, payload...)
+        return base58Encode(append(body, doubleSHA256(body)[:4]...))
+}
+
+// bech32Polymod computes the bech32 checksum over 5-bit values
+func bech32Polymod(values []byte) uint32 {
+        generator := [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+        chk := uint32(1)
+        for _, v := range values {
+                top := chk >> 25
+                chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+                for i := 0; i < 5; i++ {
+                        if (top>>uint(i))&1 == 1 {
+                                chk ^= generator[i]
+                        }
+                }
+        }
+        return chk
+}
+
+// bech32HRPExpand expands the human-readable part for the checksum
+func bech32HRPExpand(hrp string) []byte {
+        out := make([]byte, 0, len(hrp)*2+1)
+        for _, c := range hrp {
+                out = append(out, byte(c)>>5)
+        }
+        out = append(out, 0)
+        for _, c := range hrp {
+                out = append(out, byte(c)&31)
+        }
+        return out
+}
+
+// bech32Decode splits a bech32 or bech32m string into its human-readable
+// part and 5-bit data, returning which checksum constant it used
+func bech32Decode(s string) (hrp string, data []byte, checksum uint32, ok bool) {
+        if len(s) > 90 || (strings.ToLower(s) != s && strings.ToUpper(s) != s) {
+                return "", nil, 0, false
+        }
+        s = strings.ToLower(s)
+        sep := strings.LastIndexByte(s, '1')
+        if sep < 1 || sep+7 > len(s) {
+                return "", nil, 0, false
+        }
+        hrp = s[:sep]
+        for _, c := range s[sep+1:] {
+                v := strings.IndexRune(bech32Charset, c)
+                if v < 0 {
+                        return "", nil, 0, false
+                }
+                data = append(data, byte(v))
+        }
+        checksum = bech32Polymod(append(bech32HRPExpand(hrp), data...))
+        if checksum != bech32Const && checksum != bech32mConst {
+                return "", nil, 0, false
+        }
+        return hrp, data[:len(data)-6], checksum, true
+}
+
+// bech32Encode encodes 5-bit data with the given checksum constant
+func bech32Encode(hrp string, data []byte, checksum uint32) string {
+        values := append(bech32HRPExpand(hrp), data...)
+        polymod := bech32Polymod(append(values, 0, 0, 0, 0, 0, 0)) ^ checksum
+        var sb strings.Builder
+        sb.WriteString(hrp)
+        sb.WriteByte('1')
+        for _, v := range data {
+                sb.WriteByte(bech32Charset[v])
+        }
+        for i := 0; i < 6; i++ {
+                sb.WriteByte(bech32Charset[(polymod>>uint(5*(5-i)))&31])
+        }
+        return sb.String()
+}
+
+// convertBits regroups data from frombits-bit to tobits-bit values
+func convertBits(data []byte, frombits, tobits uint, pad bool) ([]byte, bool) {
+        acc, bits := uint32(0), uint(0)
+        maxv := uint32(1)<<tobits - 1
+        var out []byte
+        for _, v := range data {
+                if uint32(v)>>frombits != 0 {
+                        return nil, false
+                }
+                acc = acc<<frombits | uint32(v)
+                bits += frombits
+                for bits >= tobits {
+                        bits -= tobits
+                        out = append(out, byte(acc>>bits&maxv))
+                }
+        }
+        if pad {
+                if bits > 0 {
+                        out = append(out, byte(acc<<(tobits-bits)&maxv))
+                }
+        } else if bits >= frombits || acc<<(tobits-bits)&maxv != 0 {
+                return nil, false
+        }
+        return out, true
+}
+
+// decodeSegwitAddress decodes a segwit address per BIP173 and BIP350
+func decodeSegwitAddress(hrp, address string) (version byte, program []byte, ok bool) {
+        gotHRP, data, checksum, ok := bech32Decode(address)
+        if !ok || gotHRP != hrp || len(data) < 1 {
+                return 0, nil, false
+        }
+        version = data[0]
+        program, ok = convertBits(data[1:], 5, 8, false)
+        if !ok || version > 16 || len(program) < 2 || len(program) > 40 {
+                return 0, nil, false
+        }
+        if version == 0 && len(program) != 20 && len(program) != 32 {
+                return 0, nil, false
+        }
+        if (version == 0) != (checksum == bech32Const) {
+                return 0, nil, false
+        }
+        return version, program, true
+}
+
+// encodeSegwitAddress encodes a witness program as a segwit address
+func encodeSegwitAddress(hrp string, version byte, program []byte) string {
+        data, _ := convertBits(program, 8, 5, true)
+        checksum := uint32(bech32mConst)
+        if version == 0 {
+                checksum = bech32Const
+        }
+        return bech32Encode(hrp, append([]byte{version}, data...), checksum)
+}
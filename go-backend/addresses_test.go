@@ -0,0 +1,49 @@
+package main
+
+import "testing"
+
+func TestValidateAddress(t *testing.T) {
+        valid := []struct{ network, address string }{
+                {"ETH", "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"},
+                {"ETH", "0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359"},
+                {"ERC20", "0xDBF03B407C01E7CD3CBEA99509D93F8DDDC8C6FB"},
+                {"BSC", "0xD1220A0cf47c7B9Be7A2E6BA89F429762e7b9aDb"},
+                {"TRC20", "TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj6t"},
+                {"APTOS", "0x0000000000000000000000000000000000000000000000000000000000000001"},
+                {"BTC", "1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa"},
+                {"BTC", "3J98t1WpEZ73CNmQviecrnyiWrnqRhWNLy"},
+                {"BTC", "BC1QAR0SRRR7XFKVY5L643LYDNW9RE59GTZZWF5MDQ"},
+                {"BTC", "bc1qar0srrr7xfkvy5l643lydnw9re59gtzzwf5mdq"},
+                {"BTC", "bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0"},
+        }
+        for _, tt := range valid {
+                if msg := validateAddress(tt.network, tt.address); msg != "" {
+                        t.Errorf("validateAddress(%s, %s) = %q, want valid", tt.network, tt.address, msg)
+                }
+        }
+
+        invalid := []struct{ network, address, want string }{
+                {"ETH", "5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed", "must be a 0x-prefixed 40-character hex address"},
+                {"ETH", "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAe", "must be a 0x-prefixed 40-character hex address"},
+                {"BSC", "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAeG", "must be a 0x-prefixed 40-character hex address"},
+                {"ERC20", "0x5AAeb6053F3E94C9b9A09f33669435E7Ef1BeAed", "has an invalid EIP-55 checksum"},
+                {"TRC20", "TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj6u", "must be a TRON base58 address starting with T"},
+                {"TRC20", "1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa", "must be a TRON base58 address starting with T"},
+                {"TRC20", "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed", "must be a TRON base58 address starting with T"},
+                {"APTOS", "0x1", "must be a 0x-prefixed 64-character hex address"},
+                {"APTOS", "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed", "must be a 0x-prefixed 64-character hex address"},
+                {"BTC", "1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNb", "is not a valid Bitcoin address"},
+                {"BTC", "TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj6t", "is not a valid Bitcoin address"},
+                {"BTC", "mipcBbFg9gMiCh81Kj8tqqdgoZub1ZJRfn", "is not a valid Bitcoin address"},
+                {"BTC", "bc1qar0srrr7xfkvy5l643lydnw9re59gtzzwf5mdr", "is not a valid bech32 Bitcoin address"},
+                {"BTC", "bc1qar0srrr7xfkvy5l643lydnw9re59gtzzwF5mdq", "is not a valid bech32 Bitcoin address"},
+                {"BTC", "bc1qar0srrr7xfkvy5l643lydnw9re59gtzzm4yhgz", "is not a valid bech32 Bitcoin address"},
+                {"BTC", "tb1qw508d6qejxtdg4c5r3zarvary0c5xw7kxpjzsx", "is not a valid Bitcoin address"},
+                {"DOGE", "DH5yaieqoZN36fDVciNyRueRGvGLR3mr7L", "is not supported on DOGE"},
+        }
+        for _, tt := range invalid {
+                if msg := validateAddress(tt.network, tt.address); msg != tt.want {
+                        t.Errorf("validateAddress(%s, %s) = %q, want %q", tt.network, tt.address, msg, tt.want)
+                }
+        }
+}
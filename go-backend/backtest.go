@@ -0,0 +1,589 @@
+package main
+
+import (
+        "context"
+        "encoding/json"
+        "errors"
+        "flag"
+        "fmt"
+        "io"
+        "os"
+        "sort"
+        "text/tabwriter"
+
+        "github.com/jackc/pgx/v4/pgxpool"
+        "github.com/shopspring/decimal"
+)
+
+// backtestUser is a user in a backtest snapshot. Mining users are those who
+// would share block rewards: started mining, not paused and not inactive.
+type backtestUser struct {
+        ID                string          `json:"id"`
+        Username          string          `json:"username"`
+        ReferralCode      string          `json:"referralCode,omitempty"`
+        ReferredBy        string          `json:"referredBy,omitempty"`
+        BaseHashPower     decimal.Decimal `json:"baseHashPower"`
+        ReferralHashBonus decimal.Decimal `json:"referralHashBonus"`
+        Mining            bool            `json:"mining"`
+        GBTCBalance       decimal.Decimal `json:"gbtcBalance"`
+        UnclaimedBalance  decimal.Decimal `json:"unclaimedBalance"`
+}
+
+// backtestSnapshot is the starting state of a backtest; it is also the JSON
+// fixture format. Difficulty is zero before the network's first retarget and
+// DifficultyHashPower is the window hash power of the latest one.
+type backtestSnapshot struct {
+        Height              int64           `json:"height"`
+        Mined               decimal.Decimal `json:"mined"`
+        Difficulty          decimal.Decimal `json:"difficulty"`
+        DifficultyHashPower decimal.Decimal `json:"difficultyHashPower"`
+        Users               []*backtestUser `json:"users"`
+}
+
+// backtestParams are the reward parameters under test
+type backtestParams struct {
+        Supply             supplyParams
+        DifficultyInterval int64            // blocks between retargets
+        DifficultyBase     decimal.Decimal  // network hash power at difficulty 1
+        ReferralBonus      *decimal.Decimal // fraction of referrals' base hash power, nil to keep stored bonuses
+        DailyGrowth        decimal.Decimal  // fraction every base hash power grows per day
+        Days               int
+        GBTCPrice          decimal.Decimal // USDT per GBTC for the liability, zero to omit
+}
+
+// backtestDay is one day of the emission series
+type backtestDay struct {
+        Day              int             `json:"day"`
+        Emitted          decimal.Decimal `json:"emitted"`
+        NetworkHashPower decimal.Decimal `json:"networkHashPower"`
+        EndReward        decimal.Decimal `json:"endBlockReward"`
+        EndDifficulty    decimal.Decimal `json:"endDifficulty"`
+}
+
+// backtestEarner is a user's result in the report
+type backtestEarner struct {
+        Username string          `json:"username"`
+        Amount   decimal.Decimal `json:"amount"`
+}
+
+// backtestReport is the outcome of a backtest
+type backtestReport struct {
+        Days        int   `json:"days"`
+        Blocks      int64 `json:"blocks"`
+        StartHeight int64 `json:"startHeight"`
+        EndHeight   int64 `json:"endHeight"`
+
+        Emissions struct {
+                Emitted      decimal.Decimal `json:"emitted"`
+                Distributed  decimal.Decimal `json:"distributed"`
+                Dust         decimal.Decimal `json:"dust"`
+                MinedBefore  decimal.Decimal `json:"minedBefore"`
+                MinedAfter   decimal.Decimal `json:"minedAfter"`
+                MaxSupply    decimal.Decimal `json:"maxSupply"`
+                StartReward  decimal.Decimal `json:"startBlockReward"`
+                EndReward    decimal.Decimal `json:"endBlockReward"`
+                Halvings     int64           `json:"halvings"`
+                CapReachedAt *int64          `json:"capReachedAtHeight"`
+                Daily        []backtestDay   `json:"daily"`
+        } `json:"emissions"`
+
+        Difficulty struct {
+                Start     decimal.Decimal `json:"start"`
+                End       decimal.Decimal `json:"end"`
+                Retargets int             `json:"retargets"`
+        } `json:"difficulty"`
+
+        Earnings struct {
+                Earners     int               `json:"earners"`
+                Mean        decimal.Decimal   `json:"mean"`
+                Median      decimal.Decimal   `json:"median"`
+                P10         decimal.Decimal   `json:"p10"`
+                P90         decimal.Decimal   `json:"p90"`
+                Max         decimal.Decimal   `json:"max"`
+                TopTenthPct decimal.Decimal   `json:"topTenthSharePercent"`
+                Gini        decimal.Decimal   `json:"gini"`
+                Top         []*backtestEarner `json:"top"`
+        } `json:"earnings"`
+
+        Referrals struct {
+                Payouts   decimal.Decimal   `json:"payouts"`
+                Referrers int               `json:"referrers"`
+                Top       []*backtestEarner `json:"top"`
+        } `json:"referrals"`
+
+        Treasury struct {
+                Opening     decimal.Decimal  `json:"openingLiability"`
+                Closing     decimal.Decimal  `json:"closingLiability"`
+                Increase    decimal.Decimal  `json:"increase"`
+                ClosingUSDT *decimal.Decimal `json:"closingLiabilityUsdt"`
+        } `json:"treasury"`
+}
+
+// loadBacktestSnapshot reads the current users and chain position from
+// Postgres
+func loadBacktestSnapshot(ctx context.Context, pool *pgxpool.Pool) (*backtestSnapshot, error) {
+        var snapshot backtestSnapshot
+        var err error
+        if snapshot.Height, err = getIntSetting(ctx, pool, settingTotalBlockHeight, 0); err != nil {
+                return nil, err
+        }
+        if snapshot.Mined, err = totalMinedSupply(ctx, pool); err != nil {
+                return nil, err
+        }
+        latest, err := latestDifficultyAdjustment(ctx, pool)
+        if err != nil {
+                return nil, err
+        }
+        if latest != nil {
+                snapshot.Difficulty, snapshot.DifficultyHashPower = latest.Difficulty, latest.TotalHashPower
+        }
+
+        rows, err := pool.Query(ctx, `
+                SELECT u.id, u.username, COALESCE(u.referral_code, ''), COALESCE(u.referred_by, ''),
+                       COALESCE(u.base_hash_power, 0), COALESCE(u.referral_hash_bonus, 0), (`+eligibleMinerCondition+`) IS TRUE,
+                       COALESCE(u.gbtc_balance, 0), COALESCE(u.unclaimed_balance, 0)
+                FROM users u
+                WHERE u.is_banned IS NOT TRUE
+                ORDER BY u.created_at, u.id`)
+        if err != nil {
+                return nil, fmt.Errorf("failed to query users: %w", err)
+        }
+        defer rows.Close()
+
+        for rows.Next() {
+                var u backtestUser
+                if err := rows.Scan(&u.ID, &u.Username, &u.ReferralCode, &u.ReferredBy, &u.BaseHashPower, &u.ReferralHashBonus, &u.Mining,
+                        &u.GBTCBalance, &u.UnclaimedBalance); err != nil {
+                        return nil, fmt.Errorf("failed to scan user: %w", err)
+                }
+                snapshot.Users = append(snapshot.Users, &u)
+        }
+        return &snapshot, rows.Err()
+}
+
+// loadBacktestFixture reads a snapshot from a JSON file
+func loadBacktestFixture(path string) (*backtestSnapshot, error) {
+        data, err := os.ReadFile(path)
+        if err != nil {
+                return nil, err
+        }
+        var snapshot backtestSnapshot
+        if err := json.Unmarshal(data, &snapshot); err != nil {
+                return nil, fmt.Errorf("fixture %s is not valid JSON: %w", path, err)
+        }
+        for i, u := range snapshot.Users {
+                if u.ID == "" {
+                        u.ID = fmt.Sprint(i)
+                }
+                if u.Username == "" {
+                        u.Username = u.ID
+                }
+        }
+        return &snapshot, nil
+}
+
+// runBacktest mines params.Days days of blocks over the snapshot with the
+// same rules as generateBlock: each block's reward follows the supply
+// schedule and is split between mining users by hash power over the
+// difficulty's target, truncated to GBTC precision, and only the shares paid
+// count as mined. The difficulty retargets every DifficultyInterval blocks
+// like retargetDifficulty; a window already open at the snapshot is averaged
+// over the simulated blocks only. Hash power is base hash power plus each
+// user's stored referral bonus or, with params.ReferralBonus, that share of
+// their active referrals' base hash power, and only changes between days.
+func runBacktest(snapshot *backtestSnapshot, params *backtestParams) *backtestReport {
+        gbtcScale := currencyScales[CurrencyGBTC]
+        report := &backtestReport{Days: params.Days, StartHeight: snapshot.Height}
+        report.Emissions.MinedBefore = snapshot.Mined
+        report.Emissions.MaxSupply = params.Supply.MaxSupply
+        report.Emissions.StartReward = params.Supply.rewardAt(snapshot.Height+1, snapshot.Mined)
+        report.Difficulty.Start = snapshot.Difficulty
+
+        byCode := make(map[string]int)
+        base := make([]decimal.Decimal, len(snapshot.Users))
+        for i, u := range snapshot.Users {
+                if u.ReferralCode != "" {
+                        byCode[u.ReferralCode] = i
+                }
+                base[i] = u.BaseHashPower
+                report.Treasury.Opening = report.Treasury.Opening.Add(u.GBTCBalance).Add(u.UnclaimedBalance)
+        }
+        referrer := make([]int, len(snapshot.Users))
+        for i, u := range snapshot.Users {
+                referrer[i] = -1
+                if j, ok := byCode[u.ReferredBy]; ok && j != i {
+                        referrer[i] = j
+                }
+        }
+
+        earned := make([]decimal.Decimal, len(snapshot.Users))
+        referralEarned := make([]decimal.Decimal, len(snapshot.Users))
+        height, mined := snapshot.Height, snapshot.Mined
+        startEra := params.Supply.halvingEra(height + 1)
+        difficulty, difficultyHashPower := snapshot.Difficulty, snapshot.DifficultyHashPower
+        windowHashPower, windowBlocks := decimal.Zero, int64(0)
+
+        for day := 1; day <= params.Days; day++ {
+                // Hash power for the day: base plus the referral bonus
+                bonus := make([]decimal.Decimal, len(snapshot.Users))
+                for i, u := range snapshot.Users {
+                        if params.ReferralBonus == nil {
+                                bonus[i] = bonus[i].Add(u.ReferralHashBonus)
+                        } else if referrer[i] >= 0 && u.Mining && base[i].IsPositive() {
+                                bonus[referrer[i]] = bonus[referrer[i]].Add(base[i].Mul(*params.ReferralBonus))
+                        }
+                }
+                hashPower := make([]decimal.Decimal, len(snapshot.Users))
+                network := decimal.Zero
+                for i, u := range snapshot.Users {
+                        bonus[i] = bonus[i].Round(hashPowerScale)
+                        hashPower[i] = base[i].Add(bonus[i])
+                        if u.Mining && hashPower[i].IsPositive() {
+                                network = network.Add(hashPower[i])
+                        }
+                }
+
+                // Blocks with the same reward pay the same shares, so the day is
+                // split into runs of equal reward
+                emittedToday := decimal.Zero
+                for block := 0; block < blocksPerDay && network.IsPositive(); {
+                        reward := params.Supply.rewardAt(height+1, mined)
+                        if !reward.IsPositive() {
+                                break
+                        }
+                        // Difficulty is zero, and the target with it, before the first retarget
+                        rewardNetwork := rewardHashPower(network, difficulty.Mul(params.DifficultyBase))
+                        shares := make([]decimal.Decimal, len(snapshot.Users))
+                        paid := decimal.Zero
+                        for i, u := range snapshot.Users {
+                                if u.Mining && hashPower[i].IsPositive() {
+                                        shares[i] = blockShare(reward, hashPower[i], rewardNetwork)
+                                        paid = paid.Add(shares[i])
+                                }
+                        }
+                        // generateBlock mines no block when every share truncates to zero
+                        if !paid.IsPositive() {
+                                break
+                        }
+
+                        count := int64(0)
+                        for block < blocksPerDay && params.Supply.rewardAt(height+1, mined).Equal(reward) {
+                                height++
+                                mined = mined.Add(paid)
+                                block++
+                                count++
+                                if height%params.DifficultyInterval == 0 {
+                                        break
+                                }
+                        }
+                        if !params.Supply.rewardAt(height+1, mined).IsPositive() && report.Emissions.CapReachedAt == nil {
+                                capped := height
+                                report.Emissions.CapReachedAt = &capped
+                        }
+
+                        runReward := reward.Mul(decimal.NewFromInt(count))
+                        emittedToday = emittedToday.Add(runReward)
+                        report.Emissions.Emitted = report.Emissions.Emitted.Add(runReward)
+                        report.Emissions.Distributed = report.Emissions.Distributed.Add(paid.Mul(decimal.NewFromInt(count)))
+                        report.Blocks += count
+
+                        windowHashPower = windowHashPower.Add(network.Mul(decimal.NewFromInt(count)))
+                        windowBlocks += count
+                        if height%params.DifficultyInterval == 0 {
+                                average := windowHashPower.Div(decimal.NewFromInt(windowBlocks))
+                                if difficulty.IsZero() {
+                                        difficulty = nextDifficulty(decimal.NewFromInt(1), params.DifficultyBase, average)
+                                } else {
+                                        difficulty = nextDifficulty(difficulty, difficultyHashPower, average)
+                                }
+                                difficultyHashPower = average.Round(hashPowerScale)
+                                windowHashPower, windowBlocks = decimal.Zero, 0
+                                report.Difficulty.Retargets++
+                        }
+
+                        for i, share := range shares {
+                                if !share.IsPositive() {
+                                        continue
+                                }
+                                earned[i] = earned[i].Add(share.Mul(decimal.NewFromInt(count)))
+                                if bonus[i].IsPositive() {
+                                        fromReferrals := share.Mul(bonus[i]).Div(hashPower[i]).Truncate(gbtcScale)
+                                        referralEarned[i] = referralEarned[i].Add(fromReferrals.Mul(decimal.NewFromInt(count)))
+                                }
+                        }
+                }
+
+                report.Emissions.Daily = append(report.Emissions.Daily, backtestDay{
+                        Day:              day,
+                        Emitted:          emittedToday,
+                        NetworkHashPower: network,
+                        EndReward:        params.Supply.rewardAt(height+1, mined),
+                        EndDifficulty:    difficulty,
+                })
+
+                if params.DailyGrowth.IsPositive() {
+                        growth := decimal.NewFromInt(1).Add(params.DailyGrowth)
+                        for i := range base {
+                                base[i] = base[i].Mul(growth).Round(hashPowerScale)
+                        }
+                }
+        }
+
+        report.EndHeight = height
+        report.Emissions.MinedAfter = mined
+        report.Emissions.EndReward = params.Supply.rewardAt(height+1, mined)
+        report.Emissions.Dust = report.Emissions.Emitted.Sub(report.Emissions.Distributed)
+        report.Emissions.Halvings = params.Supply.halvingEra(height+1) - startEra
+        report.Difficulty.End = difficulty
+
+        summarizeEarnings(report, snapshot, earned)
+
+        var referralTop []*backtestEarner
+        for i, amount := range referralEarned {
+                if amount.IsPositive() {
+                        report.Referrals.Payouts = report.Referrals.Payouts.Add(amount)
+                        referralTop = append(referralTop, &backtestEarner{Username: snapshot.Users[i].Username, Amount: amount})
+                }
+        }
+        report.Referrals.Referrers = len(referralTop)
+        report.Referrals.Top = topEarners(referralTop, 5)
+
+        report.Treasury.Closing = report.Treasury.Opening.Add(report.Emissions.Distributed)
+        report.Treasury.Increase = report.Emissions.Distributed
+        if params.GBTCPrice.IsPositive() {
+                usdt := report.Treasury.Closing.Mul(params.GBTCPrice).Round(currencyScales[CurrencyUSDT])
+                report.Treasury.ClosingUSDT = &usdt
+        }
+        return report
+}
+
+// summarizeEarnings fills in the per-user earnings distribution
+func summarizeEarnings(report *backtestReport, snapshot *backtestSnapshot, earned []decimal.Decimal) {
+        var earners []*backtestEarner
+        total := decimal.Zero
+        for i, amount := range earned {
+                if amount.IsPositive() {
+                        earners = append(earners, &backtestEarner{Username: snapshot.Users[i].Username, Amount: amount})
+                        total = total.Add(amount)
+                }
+        }
+        n := len(earners)
+        report.Earnings.Earners = n
+        if n == 0 {
+                return
+        }
+
+        sort.Slice(earners, func(i, j int) bool { return earners[i].Amount.LessThan(earners[j].Amount) })
+        percentile := func(p int) decimal.Decimal { return earners[(n-1)*p/100].Amount }
+        gbtcScale := currencyScales[CurrencyGBTC]
+
+        report.Earnings.Mean = total.Div(decimal.NewFromInt(int64(n))).Truncate(gbtcScale)
+        report.Earnings.Median = percentile(50)
+        report.Earnings.P10 = percentile(10)
+        report.Earnings.P90 = percentile(90)
+        report.Earnings.Max = earners[n-1].Amount
+
+        top := decimal.Zero
+        for _, e := range earners[n-max(1, n/10):] {
+                top = top.Add(e.Amount)
+        }
+        report.Earnings.TopTenthPct = top.Div(total).Mul(decimal.NewFromInt(100)).Round(2)
+
+        // Gini coefficient over the sorted earnings
+        weighted := decimal.Zero
+        for i, e := range earners {
+                weighted = weighted.Add(e.Amount.Mul(decimal.NewFromInt(int64(2*(i+1) - n - 1))))
+        }
+        report.Earnings.Gini = weighted.Div(total.Mul(decimal.NewFromInt(int64(n)))).Round(4)
+
+        report.Earnings.Top = topEarners(earners, 10)
+}
+
+// topEarners returns the n largest amounts, largest first
+func topEarners(earners []*backtestEarner, n int) []*backtestEarner {
+        sorted := append([]*backtestEarner(nil), earners...)
+        sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Amount.GreaterThan(sorted[j].Amount) })
+        if len(sorted) > n {
+                sorted = sorted[:n]
+        }
+        return sorted
+}
+
+// writeBacktestReport renders the report as aligned text
+func writeBacktestReport(w io.Writer, report *backtestReport) error {
+        tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+        gbtc := func(d decimal.Decimal) string { return formatAmount(d, CurrencyGBTC) }
+
+        fmt.Fprintf(tw, "Simulated %d days, %d blocks (height %d to %d)\n\n", report.Days, report.Blocks,
+                report.StartHeight, report.EndHeight)
+
+        e := report.Emissions
+        fmt.Fprintln(tw, "EMISSIONS")
+        fmt.Fprintf(tw, "  Emitted\t%s GBTC\n", gbtc(e.Emitted))
+        fmt.Fprintf(tw, "  Distributed\t%s GBTC\n", gbtc(e.Distributed))
+        fmt.Fprintf(tw, "  Not minted\t%s GBTC\n", gbtc(e.Dust))
+        fmt.Fprintf(tw, "  Mined supply\t%s -> %s of %s GBTC\n", gbtc(e.MinedBefore), gbtc(e.MinedAfter), gbtc(e.MaxSupply))
+        fmt.Fprintf(tw, "  Block reward\t%s -> %s GBTC\n", gbtc(e.StartReward), gbtc(e.EndReward))
+        fmt.Fprintf(tw, "  Halvings\t%d\n", e.Halvings)
+        fmt.Fprintf(tw, "  Difficulty\t%s -> %s (%d retargets)\n", report.Difficulty.Start.StringFixed(difficultyScale),
+                report.Difficulty.End.StringFixed(difficultyScale), report.Difficulty.Retargets)
+        if e.CapReachedAt != nil {
+                fmt.Fprintf(tw, "  Max supply reached\theight %d\n", *e.CapReachedAt)
+        }
+        fmt.Fprintln(tw)
+
+        fmt.Fprintln(tw, "  Day\tEmitted\tNetwork hash power\tEnd reward\tEnd difficulty")
+        for _, d := range e.Daily {
+                fmt.Fprintf(tw, "  %d\t%s\t%s\t%s\t%s\n", d.Day, gbtc(d.Emitted), formatHashPower(d.NetworkHashPower), gbtc(d.EndReward),
+                        d.EndDifficulty.StringFixed(difficultyScale))
+        }
+        fmt.Fprintln(tw)
+
+        r := report.Earnings
+        fmt.Fprintln(tw, "EARNINGS PER USER")
+        fmt.Fprintf(tw, "  Earners\t%d\n", r.Earners)
+        fmt.Fprintf(tw, "  Mean / median\t%s / %s GBTC\n", gbtc(r.Mean), gbtc(r.Median))
+        fmt.Fprintf(tw, "  P10 / P90 / max\t%s / %s / %s GBTC\n", gbtc(r.P10), gbtc(r.P90), gbtc(r.Max))
+        fmt.Fprintf(tw, "  Top 10%% share\t%s%%\n", r.TopTenthPct.StringFixed(2))
+        fmt.Fprintf(tw, "  Gini\t%s\n", r.Gini.StringFixed(4))
+        for _, earner := range r.Top {
+                fmt.Fprintf(tw, "    %s\t%s GBTC\n", earner.Username, gbtc(earner.Amount))
+        }
+        fmt.Fprintln(tw)
+
+        fmt.Fprintln(tw, "REFERRALS")
+        fmt.Fprintf(tw, "  Payouts\t%s GBTC to %d referrers\n", gbtc(report.Referrals.Payouts), report.Referrals.Referrers)
+        for _, earner := range report.Referrals.Top {
+                fmt.Fprintf(tw, "    %s\t%s GBTC\n", earner.Username, gbtc(earner.Amount))
+        }
+        fmt.Fprintln(tw)
+
+        t := report.Treasury
+        fmt.Fprintln(tw, "TREASURY LIABILITY")
+        fmt.Fprintf(tw, "  Opening\t%s GBTC\n", gbtc(t.Opening))
+        fmt.Fprintf(tw, "  Closing\t%s GBTC (+%s)\n", gbtc(t.Closing), gbtc(t.Increase))
+        if t.ClosingUSDT != nil {
+                fmt.Fprintf(tw, "  Closing value\t%s USDT\n", formatAmount(*t.ClosingUSDT, CurrencyUSDT))
+        }
+        return tw.Flush()
+}
+
+// runSimulateCommand implements `b2b simulate` and returns the exit code
+func runSimulateCommand(args []string, stdout, stderr io.Writer) int {
+        fs := flag.NewFlagSet("b2b simulate", flag.ContinueOnError)
+        fs.SetOutput(stderr)
+        fixture := fs.String("fixture", "", "JSON snapshot to simulate instead of the DATABASE_URL database")
+        days := fs.Int("days", 30, "number of days to simulate")
+        blockReward := fs.String("block-reward", "", "initial block reward (default: current setting)")
+        halvingInterval := fs.Int64("halving-interval", 0, "blocks between halvings (default: current setting)")
+        maxSupply := fs.String("max-supply", "", "max GBTC supply (default: current setting)")
+        referralBonus := fs.String("referral-bonus", "", "referral hash power bonus in percent of active referrals' base hash power (default: each user's current bonus)")
+        growth := fs.String("growth", "0", "daily hash power growth in percent")
+        gbtcPrice := fs.String("gbtc-price", "0", "USDT price of GBTC for the treasury liability")
+        format := fs.String("format", "text", "report format: text or json")
+        if err := fs.Parse(args); err != nil {
+                if errors.Is(err, flag.ErrHelp) {
+                        return 0
+                }
+                return 2
+        }
+
+        fail := func(err error) int {
+                fmt.Fprintf(stderr, "b2b simulate: %v\n", err)
+                return 1
+        }
+        if *days < 1 || *days > 3650 {
+                return fail(errors.New("-days must be between 1 and 3650"))
+        }
+        if *format != "text" && *format != "json" {
+                return fail(errors.New("-format must be text or json"))
+        }
+
+        ctx := context.Background()
+        var snapshot *backtestSnapshot
+        supply := &supplyParams{MaxSupply: defaultMaxSupply, InitialReward: defaultBlockReward, HalvingInterval: defaultHalvingInterval}
+        difficultyInterval, difficultyBase := int64(defaultDifficultyInterval), defaultDifficultyBaseHashPower
+        var err error
+        if *fixture != "" {
+                if snapshot, err = loadBacktestFixture(*fixture); err != nil {
+                        return fail(err)
+                }
+        } else {
+                dbURL := os.Getenv("DATABASE_URL")
+                if dbURL == "" {
+                        return fail(errors.New("DATABASE_URL must be set unless -fixture is given"))
+                }
+                pool, err := pgxpool.Connect(ctx, dbURL)
+                if err != nil {
+                        return fail(fmt.Errorf("failed to connect to database: %w", err))
+                }
+                defer pool.Close()
+                if snapshot, err = loadBacktestSnapshot(ctx, pool); err != nil {
+                        return fail(err)
+                }
+                if supply, err = loadSupplyParams(ctx, pool); err != nil {
+                        return fail(err)
+                }
+                if difficultyInterval, err = getIntSetting(ctx, pool, settingDifficultyInterval, defaultDifficultyInterval); err != nil {
+                        return fail(err)
+                }
+                if difficultyBase, err = getDecimalSetting(ctx, pool, settingDifficultyBaseHashPower, defaultDifficultyBaseHashPower); err != nil {
+                        return fail(err)
+                }
+                if difficultyInterval <= 0 || !difficultyBase.IsPositive() {
+                        return fail(fmt.Errorf("settings %s and %s must be positive", settingDifficultyInterval, settingDifficultyBaseHashPower))
+                }
+        }
+
+        decimalFlag := func(name, value string, target *decimal.Decimal) error {
+                if value == "" {
+                        return nil
+                }
+                d, err := decimal.NewFromString(value)
+                if err != nil || d.IsNegative() {
+                        return fmt.Errorf("-%s must be a non-negative number", name)
+                }
+                *target = d
+                return nil
+        }
+        params := &backtestParams{Supply: *supply, DifficultyInterval: difficultyInterval, DifficultyBase: difficultyBase, Days: *days}
+        var bonusPercent, growthPercent decimal.Decimal
+        for _, f := range []struct {
+                name, value string
+                target      *decimal.Decimal
+        }{
+                {"block-reward", *blockReward, &params.Supply.InitialReward},
+                {"max-supply", *maxSupply, &params.Supply.MaxSupply},
+                {"referral-bonus", *referralBonus, &bonusPercent},
+                {"growth", *growth, &growthPercent},
+                {"gbtc-price", *gbtcPrice, &params.GBTCPrice},
+        } {
+                if err := decimalFlag(f.name, f.value, f.target); err != nil {
+                        return fail(err)
+                }
+        }
+        if *halvingInterval < 0 {
+                return fail(errors.New("-halving-interval must be positive"))
+        }
+        if *halvingInterval > 0 {
+                params.Supply.HalvingInterval = *halvingInterval
+        }
+        if *referralBonus != "" {
+                fraction := bonusPercent.Div(decimal.NewFromInt(100))
+                params.ReferralBonus = &fraction
+        }
+        params.DailyGrowth = growthPercent.Div(decimal.NewFromInt(100))
+
+        report := runBacktest(snapshot, params)
+        if *format == "json" {
+                encoder := json.NewEncoder(stdout)
+                encoder.SetIndent("", "  ")
+                err = encoder.Encode(report)
+        } else {
+                err = writeBacktestReport(stdout, report)
+        }
+        if err != nil {
+                return fail(err)
+        }
+        return 0
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+        "testing"
+
+        "github.com/shopspring/decimal"
+)
+
+func backtestFixtureParams(days int) *backtestParams {
+        return &backtestParams{
+                Supply: supplyParams{
+                        MaxSupply:       decimal.NewFromInt(21000000),
+                        InitialReward:   decimal.NewFromInt(50),
+                        HalvingInterval: 210000,
+                },
+                DifficultyInterval: blocksPerDay,
+                DifficultyBase:     decimal.NewFromInt(1000),
+                Days:               days,
+        }
+}
+
+func TestRunBacktestFixture(t *testing.T) {
+        snapshot, err := loadBacktestFixture("testdata/backtest_snapshot.json")
+        if err != nil {
+                t.Fatal(err)
+        }
+        report := runBacktest(snapshot, backtestFixtureParams(2))
+
+        // The network is 1000 with alice's stored bonus, so every block pays
+        // alice 30.75, bob 15 and dave 4.25 with no dust
+        want := map[string]string{
+                "blocks":       "288",
+                "emitted":      "14400",
+                "distributed":  "14400",
+                "dust":         "0",
+                "minedAfter":   "14400",
+                "network":      "1000",
+                "difficulty":   "1",
+                "retargets":    "2",
+                "referrals":    "216",
+                "closing":      "14412.5",
+                "top earner":   "alice",
+                "top earnings": "8856",
+        }
+        got := map[string]string{
+                "blocks":       decimal.NewFromInt(report.Blocks).String(),
+                "emitted":      report.Emissions.Emitted.String(),
+                "distributed":  report.Emissions.Distributed.String(),
+                "dust":         report.Emissions.Dust.String(),
+                "minedAfter":   report.Emissions.MinedAfter.String(),
+                "network":      report.Emissions.Daily[0].NetworkHashPower.String(),
+                "difficulty":   report.Difficulty.End.String(),
+                "retargets":    decimal.NewFromInt(int64(report.Difficulty.Retargets)).String(),
+                "referrals":    report.Referrals.Payouts.String(),
+                "closing":      report.Treasury.Closing.String(),
+                "top earner":   report.Earnings.Top[0].Username,
+                "top earnings": report.Earnings.Top[0].Amount.String(),
+        }
+        for key, w := range want {
+                if got[key] != w {
+                        t.Errorf("%s = %s, want %s", key, got[key], w)
+                }
+        }
+        if report.Earnings.Earners != 3 {
+                t.Errorf("earners = %d, want 3", report.Earnings.Earners)
+        }
+}
+
+func TestRunBacktestDynamicReferralBonus(t *testing.T) {
+        snapshot, err := loadBacktestFixture("testdata/backtest_snapshot.json")
+        if err != nil {
+                t.Fatal(err)
+        }
+        params := backtestFixtureParams(1)
+        bonus := decimal.RequireFromString("0.1")
+        params.ReferralBonus = &bonus
+        report := runBacktest(snapshot, params)
+
+        // alice's stored bonus is replaced by 10% of bob's base hash power
+        if network := report.Emissions.Daily[0].NetworkHashPower; !network.Equal(decimal.NewFromInt(1015)) {
+                t.Errorf("network hash power = %s, want 1015", network)
+        }
+        if payouts := report.Referrals.Payouts; !payouts.Equal(decimal.RequireFromString("212.80788144")) {
+                t.Errorf("referral payouts = %s, want 212.80788144", payouts)
+        }
+}
+
+func TestRunBacktestFollowsDifficulty(t *testing.T) {
+        snapshot, err := loadBacktestFixture("testdata/backtest_snapshot.json")
+        if err != nil {
+                t.Fatal(err)
+        }
+        // A difficulty of 2 targets twice the network's hash power, so the first
+        // day pays half of each reward and the retarget restores the full reward
+        snapshot.Difficulty = decimal.NewFromInt(2)
+        snapshot.DifficultyHashPower = decimal.NewFromInt(2000)
+        report := runBacktest(snapshot, backtestFixtureParams(2))
+
+        for name, pair := range map[string][2]decimal.Decimal{
+                "day 1 difficulty": {report.Emissions.Daily[0].EndDifficulty, decimal.NewFromInt(1)},
+                "distributed":      {report.Emissions.Distributed, decimal.NewFromInt(10800)},
+                "not minted":       {report.Emissions.Dust, decimal.NewFromInt(3600)},
+                "mined after":      {report.Emissions.MinedAfter, decimal.NewFromInt(10800)},
+        } {
+                if !pair[0].Equal(pair[1]) {
+                        t.Errorf("%s = %s, want %s", name, pair[0], pair[1])
+                }
+        }
+}
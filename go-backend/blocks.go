@@ -0,0 +1,403 @@
+package main
+
+import (
+        "context"
+        "fmt"
+        "log"
+        "time"
+
+        "github.com/shopspring/decimal"
+)
+
+// Settings keys shared with server/mining.ts
+const (
+        settingBlockReward      = "blockReward"
+        settingBlockNumber      = "blockNumber"
+        settingTotalBlockHeight = "totalBlockHeight"
+        settingLastResetDate    = "lastResetDate"
+        settingClaimWindowHours = "claimWindowHours"
+)
+
+const (
+        // blocksPerDay is the number of 10 minute blocks mined per day
+        blocksPerDay = 144
+
+        // blockInterval is the target time between blocks
+        blockInterval = 10 * time.Minute
+
+        defaultClaimWindowHours = 24
+)
+
+var defaultBlockReward = decimal.NewFromInt(50)
+
+// eligibleMinerCondition selects the users (aliased u) who share a block's
+// reward. Users must have hash power, have started mining, not be paused by an
+// admin and not have been deactivated for missing claims.
+const eligibleMinerCondition = `u.hash_power > 0 AND u.has_started_mining = true AND u.mining_paused_at IS NULL
+        AND NOT EXISTS (SELECT 1 FROM miner_activity ma WHERE ma.user_id = u.id AND ma.is_active = false)`
+
+// MiningBlock represents the mining_blocks table. Number is the daily block
+// number, which resets at midnight UTC; Height never resets. Reward is what
+// the block paid out, which counts towards the mined supply, and
+// TotalHashPower is the eligible hash power it was split between.
+type MiningBlock struct {
+        ID             string          `json:"id" db:"id"`
+        Number         int             `json:"blockNumber" db:"block_number"`
+        Height         int64           `json:"height" db:"height"`
+        Reward         decimal.Decimal `json:"reward" db:"reward"`
+        TotalHashPower decimal.Decimal `json:"totalHashPower" db:"total_hash_power"`
+        Participants   int64           `json:"participants"`
+        Timestamp      time.Time       `json:"timestamp" db:"timestamp"`
+}
+
+// minerHashPower is an eligible miner's share of the network
+type minerHashPower struct {
+        UserID    string
+        HashPower decimal.Decimal
+}
+
+// rewardHashPower is the hash power a block's reward is divided by: the
+// eligible hash power, but never less than the target the difficulty sets.
+// Rewards per unit of hash power fall as the network outgrows the
+// difficulty, and the part of a reward a shrinking network does not earn is
+// not minted.
+func rewardHashPower(eligibleHashPower, targetHashPower decimal.Decimal) decimal.Decimal {
+        return decimal.Max(eligibleHashPower, targetHashPower)
+}
+
+// blockShare is what hashPower earns from one block divided by
+// networkHashPower. Shares are truncated to GBTC precision; dust below one
+// unit is not paid.
+func blockShare(reward, hashPower, networkHashPower decimal.Decimal) decimal.Decimal {
+        if !networkHashPower.IsPositive() {
+                return decimal.Zero
+        }
+        return reward.Mul(hashPower).Div(networkHashPower).Truncate(currencyScales[CurrencyGBTC])
+}
+
+// estimateDailyEarnings is what hashPower earns from a day of blocks at the
+// given reward and network hash power
+func estimateDailyEarnings(reward, hashPower, networkHashPower decimal.Decimal) decimal.Decimal {
+        return blockShare(reward, hashPower, networkHashPower).Mul(decimal.NewFromInt(blocksPerDay))
+}
+
+// splitBlockReward divides a block's reward between miners by hash power
+// over rewardHashPower. Miners whose share truncates to zero are left out.
+func splitBlockReward(reward decimal.Decimal, miners []minerHashPower, targetHashPower decimal.Decimal) map[string]decimal.Decimal {
+        eligibleHashPower := decimal.Zero
+        for _, m := range miners {
+                eligibleHashPower = eligibleHashPower.Add(m.HashPower)
+        }
+        networkHashPower := rewardHashPower(eligibleHashPower, targetHashPower)
+        shares := make(map[string]decimal.Decimal)
+        for _, m := range miners {
+                if share := blockShare(reward, m.HashPower, networkHashPower); share.IsPositive() {
+                        shares[m.UserID] = share
+                }
+        }
+        return shares
+}
+
+// eligibleNetworkHashPower is the hash power block rewards are currently
+// split between
+func eligibleNetworkHashPower(ctx context.Context, q rowQuerier) (decimal.Decimal, error) {
+        var hashPower decimal.Decimal
+        err := q.QueryRow(ctx, `
+                SELECT COALESCE(SUM(u.hash_power) FILTER (WHERE `+eligibleMinerCondition+`), 0)
+                FROM users u`).Scan(&hashPower)
+        if err != nil {
+                return decimal.Zero, fmt.Errorf("failed to get eligible hash power: %w", err)
+        }
+        return hashPower, nil
+}
+
+// randomTxHashSQL generates a 0x-prefixed 64 character hex hash in SQL for
+// set-based inserts
+const randomTxHashSQL = `'0x' || md5(random()::text || clock_timestamp()::text) || md5(random()::text)`
+
+// generateBlock mines the next block at the given time and splits its reward
+// between eligible miners by hash power as unclaimed rewards, priced against
+// the current difficulty's target hash power. It returns nil
+// when no block is mined because there are no eligible miners or no reward.
+// Truncation dust is never minted, so only the shares paid count as mined.
+func generateBlock(ctx context.Context, at time.Time) (*MiningBlock, error) {
+        tx, err := db.Begin(ctx)
+        if err != nil {
+                return nil, fmt.Errorf("failed to begin transaction: %w", err)
+        }
+        defer tx.Rollback(ctx)
+
+        params, err := loadSupplyParams(ctx, tx)
+        if err != nil {
+                return nil, err
+        }
+        mined, err := totalMinedSupply(ctx, tx)
+        if err != nil {
+                return nil, err
+        }
+        number, err := getIntSetting(ctx, tx, settingBlockNumber, 1)
+        if err != nil {
+                return nil, err
+        }
+        height, err := getIntSetting(ctx, tx, settingTotalBlockHeight, 0)
+        if err != nil {
+                return nil, err
+        }
+        claimWindow, err := getIntSetting(ctx, tx, settingClaimWindowHours, defaultClaimWindowHours)
+        if err != nil {
+                return nil, err
+        }
+        target, err := targetHashPower(ctx, tx)
+        if err != nil {
+                return nil, err
+        }
+
+        rows, err := tx.Query(ctx, "SELECT u.id, u.hash_power FROM users u WHERE "+eligibleMinerCondition)
+        if err != nil {
+                return nil, fmt.Errorf("failed to query eligible miners: %w", err)
+        }
+        var miners []minerHashPower
+        eligibleHashPower := decimal.Zero
+        for rows.Next() {
+                var m minerHashPower
+                if err := rows.Scan(&m.UserID, &m.HashPower); err != nil {
+                        rows.Close()
+                        return nil, fmt.Errorf("failed to scan eligible miner: %w", err)
+                }
+                miners = append(miners, m)
+                eligibleHashPower = eligibleHashPower.Add(m.HashPower)
+        }
+        rows.Close()
+        if err := rows.Err(); err != nil {
+                return nil, err
+        }
+        // No block is mined once the max supply has been emitted
+        reward := params.rewardAt(height+1, mined)
+        if !eligibleHashPower.IsPositive() || !reward.IsPositive() {
+                return nil, nil
+        }
+        shares := splitBlockReward(reward, miners, target)
+        paid := decimal.Zero
+        for _, share := range shares {
+                paid = paid.Add(share)
+        }
+        if !paid.IsPositive() {
+                return nil, nil
+        }
+
+        block := MiningBlock{
+                Number:         int(number),
+                Height:         height + 1,
+                Reward:         paid,
+                TotalHashPower: eligibleHashPower,
+                Timestamp:      at,
+        }
+        err = tx.QueryRow(ctx, `
+                INSERT INTO mining_blocks (block_number, height, reward, total_hash_power, timestamp)
+                VALUES ($1, $2, $3, $4, $5)
+                RETURNING id`,
+                block.Number, block.Height, paid.String(), eligibleHashPower.String(), at,
+        ).Scan(&block.ID)
+        if err != nil {
+                return nil, fmt.Errorf("failed to record block: %w", err)
+        }
+
+        userIDs := make([]string, 0, len(shares))
+        amounts := make([]string, 0, len(shares))
+        for userID, share := range shares {
+                userIDs = append(userIDs, userID)
+                amounts = append(amounts, share.String())
+        }
+        tag, err := tx.Exec(ctx, `
+                INSERT INTO unclaimed_blocks (user_id, block_number, block_height, tx_hash, reward, expires_at, created_at)
+                SELECT s.user_id::uuid, $1, $2, `+randomTxHashSQL+`, s.reward::numeric, $5, $6
+                FROM unnest($3::text[], $4::text[]) AS s(user_id, reward)`,
+                block.Number, block.Height, userIDs, amounts,
+                at.Add(time.Duration(claimWindow)*time.Hour), at)
+        if err != nil {
+                return nil, fmt.Errorf("failed to distribute rewards: %w", err)
+        }
+        block.Participants = tag.RowsAffected()
+
+        _, err = tx.Exec(ctx, `
+                UPDATE users
+                SET unclaimed_balance = unclaimed_balance + b.reward
+                FROM unclaimed_blocks b
+                WHERE b.user_id = users.id AND b.block_height = $1`, block.Height)
+        if err != nil {
+                return nil, fmt.Errorf("failed to credit unclaimed rewards: %w", err)
+        }
+
+        if err := recordBlockParticipation(ctx, tx, block.Height, at); err != nil {
+                return nil, err
+        }
+        if err := recordBlockMiningStats(ctx, tx, block.Height, at); err != nil {
+                return nil, err
+        }
+
+        if err := setSystemSetting(ctx, tx, settingBlockNumber, fmt.Sprint(number+1)); err != nil {
+                return nil, err
+        }
+        if err := setSystemSetting(ctx, tx, settingTotalBlockHeight, fmt.Sprint(block.Height)); err != nil {
+                return nil, err
+        }
+
+        // blockReward mirrors the next block's reward for the TypeScript server
+        nextReward := params.rewardAt(block.Height+1, mined.Add(paid))
+        if err := setSystemSetting(ctx, tx, settingBlockReward, nextReward.String()); err != nil {
+                return nil, err
+        }
+
+        // mining_stats holds a single row of network totals
+        _, err = tx.Exec(ctx, `
+                WITH updated AS (
+                        UPDATE mining_stats
+                        SET total_hash_power = $1, active_miners = $2, total_blocks_mined = total_blocks_mined + 1,
+                            last_block_time = $3, updated_at = $3
+                        RETURNING id
+                )
+                INSERT INTO mining_stats (total_hash_power, active_miners, total_blocks_mined, last_block_time, updated_at)
+                SELECT $1, $2, 1, $3, $3
+                WHERE NOT EXISTS (SELECT 1 FROM updated)`,
+                eligibleHashPower.String(), block.Participants, at)
+        if err != nil {
+                return nil, fmt.Errorf("failed to update mining stats: %w", err)
+        }
+
+        if _, err := retargetDifficulty(ctx, tx, block.Height, at); err != nil {
+                return nil, err
+        }
+
+        err = publishEvent(ctx, tx, eventBlock, "", map[string]interface{}{
+                "height":         block.Height,
+                "blockNumber":    block.Number,
+                "reward":         formatAmount(block.Reward, CurrencyGBTC),
+                "totalHashPower": formatHashPower(block.TotalHashPower),
+                "participants":   block.Participants,
+                "timestamp":      block.Timestamp,
+        })
+        if err != nil {
+                return nil, err
+        }
+
+        if err := tx.Commit(ctx); err != nil {
+                return nil, fmt.Errorf("failed to commit block: %w", err)
+        }
+        return &block, nil
+}
+
+// expireUnclaimedRewards marks rewards whose claim window has passed as
+// expired and removes them from the owners' unclaimed balances. It returns
+// the number of rewards expired.
+func expireUnclaimedRewards(ctx context.Context, now time.Time) (int64, error) {
+        tag, err := db.Exec(ctx, `
+                WITH expired AS (
+                        UPDATE unclaimed_blocks
+                        SET expired = true
+                        WHERE claimed = false AND expired = false AND expires_at <= $1
+                        RETURNING user_id, reward
+                ), totals AS (
+                        SELECT user_id, SUM(reward) AS total FROM expired GROUP BY user_id
+                )
+                UPDATE users
+                SET unclaimed_balance = GREATEST(unclaimed_balance - totals.total, 0)
+                FROM totals
+                WHERE users.id = totals.user_id`, now)
+        if err != nil {
+                return 0, fmt.Errorf("failed to expire unclaimed rewards: %w", err)
+        }
+        return tag.RowsAffected(), nil
+}
+
+// mineBlock is the scheduled block job: it mines a block for the scheduled
+// time and then expires rewards that were not claimed in time.
+func mineBlock(ctx context.Context, scheduledFor time.Time) error {
+        block, err := generateBlock(ctx, scheduledFor)
+        if err != nil {
+                return err
+        }
+        if block != nil {
+                log.Printf("Block %d mined: %s GBTC shared by %d miners", block.Height,
+                        formatAmount(block.Reward, CurrencyGBTC), block.Participants)
+        }
+
+        now := clock.Now()
+        if _, err := expireUnclaimedRewards(ctx, now); err != nil {
+                return err
+        }
+        deactivated, err := updateMissedClaims(ctx, now)
+        if err != nil {
+                return err
+        }
+        if deactivated > 0 {
+                log.Printf("Deactivated %d miners for missed claims", deactivated)
+        }
+        return nil
+}
+
+// dailyReset restarts the daily block number at midnight UTC
+func dailyReset(ctx context.Context, scheduledFor time.Time) error {
+        tx, err := db.Begin(ctx)
+        if err != nil {
+                return fmt.Errorf("failed to begin transaction: %w", err)
+        }
+        defer tx.Rollback(ctx)
+
+        if err := setSystemSetting(ctx, tx, settingBlockNumber, "1"); err != nil {
+                return err
+        }
+        if err := setSystemSetting(ctx, tx, settingLastResetDate, scheduledFor.UTC().Format("2006-01-02")); err != nil {
+                return err
+        }
+        return tx.Commit(ctx)
+}
+
+// claimRewards moves the user's unclaimed balance to their GBTC balance and
+// marks their unexpired rewards as claimed. It returns the number of block
+// rewards and the amount claimed.
+func claimRewards(ctx context.Context, userID string) (int64, decimal.Decimal, error) {
+        tx, err := db.Begin(ctx)
+        if err != nil {
+                return 0, decimal.Zero, fmt.Errorf("failed to begin transaction: %w", err)
+        }
+        defer tx.Rollback(ctx)
+
+        var unclaimed decimal.Decimal
+        err = tx.QueryRow(ctx, "SELECT unclaimed_balance FROM users WHERE id = $1 FOR UPDATE", userID).Scan(&unclaimed)
+        if err != nil {
+                return 0, decimal.Zero, fmt.Errorf("failed to lock user: %w", err)
+        }
+        if !unclaimed.IsPositive() {
+                return 0, decimal.Zero, newAPIError(ErrCodeInvalidState, "No rewards to claim")
+        }
+
+        now := clock.Now()
+        tag, err := tx.Exec(ctx, `
+                UPDATE unclaimed_blocks
+                SET claimed = true, claimed_at = $2
+                WHERE user_id = $1 AND claimed = false AND expired = false`,
+                userID, now)
+        if err != nil {
+                return 0, decimal.Zero, fmt.Errorf("failed to claim blocks: %w", err)
+        }
+
+        _, err = tx.Exec(ctx, `
+                UPDATE users
+                SET gbtc_balance = gbtc_balance + unclaimed_balance, unclaimed_balance = 0
+                WHERE id = $1`, userID)
+        if err != nil {
+                return 0, decimal.Zero, fmt.Errorf("failed to credit rewards: %w", err)
+        }
+
+        if err := recordClaimActivity(ctx, tx, userID, now); err != nil {
+                return 0, decimal.Zero, err
+        }
+        if err := recordClaimMiningStats(ctx, tx, userID, unclaimed, now); err != nil {
+                return 0, decimal.Zero, err
+        }
+
+        if err := tx.Commit(ctx); err != nil {
+                return 0, decimal.Zero, fmt.Errorf("failed to commit claim: %w", err)
+        }
+        return tag.RowsAffected(), unclaimed, nil
+}
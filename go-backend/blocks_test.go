@@ -0,0 +1,84 @@
+package main
+
+import (
+        "testing"
+
+        "github.com/shopspring/decimal"
+)
+
+func TestEstimateDailyEarningsMatchesBlockSplit(t *testing.T) {
+        reward := decimal.RequireFromString("6.25")
+        miners := []minerHashPower{
+                {UserID: "a", HashPower: decimal.RequireFromString("1000.00")},
+                {UserID: "b", HashPower: decimal.RequireFromString("333.33")},
+                {UserID: "c", HashPower: decimal.RequireFromString("7.77")},
+                {UserID: "d", HashPower: decimal.RequireFromString("0.01")},
+        }
+        eligible := decimal.Zero
+        for _, m := range miners {
+                eligible = eligible.Add(m.HashPower)
+        }
+
+        shares := splitBlockReward(reward, miners, decimal.Zero)
+        paid := decimal.Zero
+        for _, m := range miners {
+                share := shares[m.UserID]
+                paid = paid.Add(share)
+
+                estimate := estimateDailyEarnings(reward, m.HashPower, eligible)
+                if want := share.Mul(decimal.NewFromInt(blocksPerDay)); !estimate.Equal(want) {
+                        t.Errorf("miner %s: estimated %s a day, a day of blocks pays %s", m.UserID, estimate, want)
+                }
+        }
+        if paid.GreaterThan(reward) {
+                t.Errorf("paid %s, more than the %s block reward", paid, reward)
+        }
+        if dust := reward.Sub(paid); dust.GreaterThanOrEqual(decimal.New(int64(len(miners)), -8)) {
+                t.Errorf("dust %s is more than one unit per miner", dust)
+        }
+}
+
+func TestSplitBlockRewardDropsZeroShares(t *testing.T) {
+        shares := splitBlockReward(decimal.RequireFromString("0.00000010"), []minerHashPower{
+                {UserID: "whale", HashPower: decimal.NewFromInt(1000000)},
+                {UserID: "dust", HashPower: decimal.RequireFromString("0.01")},
+        }, decimal.Zero)
+        if _, ok := shares["dust"]; ok {
+                t.Errorf("share truncating to zero was paid: %s", shares["dust"])
+        }
+        if !shares["whale"].Equal(decimal.RequireFromString("0.00000009")) {
+                t.Errorf("whale share = %s, want 0.00000009", shares["whale"])
+        }
+        if got := estimateDailyEarnings(decimal.NewFromInt(50), decimal.NewFromInt(10), decimal.Zero); !got.IsZero() {
+                t.Errorf("estimate without eligible hash power = %s, want 0", got)
+        }
+}
+
+func TestSplitBlockRewardFollowsDifficulty(t *testing.T) {
+        reward := decimal.NewFromInt(50)
+        miners := []minerHashPower{
+                {UserID: "a", HashPower: decimal.NewFromInt(300)},
+                {UserID: "b", HashPower: decimal.NewFromInt(200)},
+        }
+
+        // Below the target the network earns its share of the reward and
+        // the rest is not minted
+        shares := splitBlockReward(reward, miners, decimal.NewFromInt(1000))
+        if !shares["a"].Equal(decimal.NewFromInt(15)) || !shares["b"].Equal(decimal.NewFromInt(10)) {
+                t.Errorf("shares below target = %v, want a 15 and b 10", shares)
+        }
+
+        // Above the target the whole reward is split by live hash power
+        shares = splitBlockReward(reward, miners, decimal.NewFromInt(250))
+        if !shares["a"].Equal(decimal.NewFromInt(30)) || !shares["b"].Equal(decimal.NewFromInt(20)) {
+                t.Errorf("shares above target = %v, want a 30 and b 20", shares)
+        }
+
+        // A unit of hash power earns less as the network grows past the target
+        target := decimal.NewFromInt(100)
+        small := estimateDailyEarnings(reward, decimal.NewFromInt(1), rewardHashPower(decimal.NewFromInt(50), target))
+        large := estimateDailyEarnings(reward, decimal.NewFromInt(1), rewardHashPower(decimal.NewFromInt(400), target))
+        if !small.Equal(decimal.NewFromInt(72)) || !large.Equal(decimal.NewFromInt(18)) {
+                t.Errorf("daily earnings per hash = %s and %s, want 72 and 18", small, large)
+        }
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+        "context"
+        "fmt"
+        "log"
+        "net/http"
+        "time"
+
+        "github.com/go-chi/chi/v5"
+        "github.com/jackc/pgx/v4"
+        "github.com/shopspring/decimal"
+)
+
+// Contract statuses stored in hash_power_contracts.status
+const (
+        contractStatusActive  = "active"
+        contractStatusExpired = "expired"
+)
+
+// contractExpiryBatchSize caps the contracts settled per pass
+const contractExpiryBatchSize = 500
+
+// contractColumns lists the hash_power_contracts columns in the order scanContract expects
+const contractColumns = `
+        id, user_id, hash_power, usdt_cost, unit_price, discount_percent, duration_days,
+        auto_renew, renewal_count, status, starts_at, expires_at, created_at`
+
+func scanContract(row pgx.Row) (*HashPowerContract, error) {
+        var c HashPowerContract
+        err := row.Scan(
+                &c.ID, &c.UserID, &c.HashPower, &c.USDTCost, &c.UnitPrice, &c.DiscountPercent, &c.DurationDays,
+                &c.AutoRenew, &c.RenewalCount, &c.Status, &c.StartsAt, &c.ExpiresAt, &c.CreatedAt,
+        )
+        if err != nil {
+                return nil, err
+        }
+        return &c, nil
+}
+
+// getActiveContracts returns a user's active contracts, soonest expiry first
+func getActiveContracts(ctx context.Context, userID string) ([]*HashPowerContract, error) {
+        rows, err := db.Query(ctx, `
+                SELECT `+contractColumns+`
+                FROM hash_power_contracts
+                WHERE user_id = $1 AND status = $2
+                ORDER BY expires_at ASC NULLS LAST, created_at ASC`,
+                userID, contractStatusActive)
+        if err != nil {
+                return nil, fmt.Errorf("failed to query contracts: %w", err)
+        }
+        defer rows.Close()
+
+        var contracts []*HashPowerContract
+        for rows.Next() {
+                c, err := scanContract(rows)
+                if err != nil {
+                        return nil, fmt.Errorf("failed to scan contract: %w", err)
+                }
+                contracts = append(contracts, c)
+        }
+        return contracts, rows.Err()
+}
+
+// dailyEarningsPerHash estimates the GBTC one unit of hash power earns per
+// day at the current block reward, eligible network hash power and
+// difficulty, the same split generateBlock pays, so the estimate falls as
+// the network grows.
+func dailyEarningsPerHash(ctx context.Context) (decimal.Decimal, error) {
+        reward, err := currentBlockReward(ctx, db)
+        if err != nil {
+                return decimal.Zero, err
+        }
+
+        eligibleHashPower, err := eligibleNetworkHashPower(ctx, db)
+        if err != nil {
+                return decimal.Zero, err
+        }
+        target, err := targetHashPower(ctx, db)
+        if err != nil {
+                return decimal.Zero, err
+        }
+        networkHashPower := rewardHashPower(eligibleHashPower, target)
+        if !networkHashPower.IsPositive() {
+                return decimal.Zero, nil
+        }
+
+        return reward.Mul(decimal.NewFromInt(blocksPerDay)).Div(networkHashPower), nil
+}
+
+// settleExpiredContracts renews or expires every active contract whose
+// expiry has passed and reports how many of each it handled.
+func settleExpiredContracts(ctx context.Context, now time.Time) (expired, renewed int, err error) {
+        rows, err := db.Query(ctx, `
+                SELECT id FROM hash_power_contracts
+                WHERE status = $1 AND expires_at <= $2
+                ORDER BY expires_at ASC
+                LIMIT $3`,
+                contractStatusActive, now, contractExpiryBatchSize)
+        if err != nil {
+                return 0, 0, fmt.Errorf("failed to query expired contracts: %w", err)
+        }
+
+        var ids []string
+        for rows.Next() {
+                var id string
+                if err := rows.Scan(&id); err != nil {
+                        rows.Close()
+                        return 0, 0, fmt.Errorf("failed to scan contract id: %w", err)
+                }
+                ids = append(ids, id)
+        }
+        rows.Close()
+        if err := rows.Err(); err != nil {
+                return 0, 0, err
+        }
+
+        for _, id := range ids {
+                wasRenewed, handled, err := settleContract(ctx, id, now)
+                if err != nil {
+                        return expired, renewed, fmt.Errorf("contract %s: %w", id, err)
+                }
+                if !handled {
+                        continue
+                }
+                if wasRenewed {
+                        renewed++
+                } else {
+                        expired++
+                }
+        }
+        return expired, renewed, nil
+}
+
+// settleContract renews an expired contract from the owner's USDT balance if
+// auto-renew is on and affordable, otherwise it removes the contract's hash
+// power from the owner. Removal never drops the owner below the hashrate
+// locked by active stakes: the contract stays active and is retried on later
+// runs until the stake unlocks or is cancelled. handled is false if another
+// worker got there first or the contract is held for a stake.
+func settleContract(ctx context.Context, contractID string, now time.Time) (renewed, handled bool, err error) {
+        tx, err := db.Begin(ctx)
+        if err != nil {
+                return false, false, fmt.Errorf("failed to begin transaction: %w", err)
+        }
+        defer tx.Rollback(ctx)
+
+        c, err := scanContract(tx.QueryRow(ctx, `
+                SELECT `+contractColumns+`
+                FROM hash_power_contracts
+                WHERE id = $1 AND status = $2 AND expires_at <= $3
+                FOR UPDATE SKIP LOCKED`,
+                contractID, contractStatusActive, now))
+        if err == pgx.ErrNoRows {
+                return false, false, nil
+        }
+        if err != nil {
+                return false, false, fmt.Errorf("failed to lock contract: %w", err)
+        }
+
+        var usdtBalance, baseHashPower, referralBonus decimal.Decimal
+        var isFrozen, isBanned bool
+        err = tx.QueryRow(ctx, `
+                SELECT usdt_balance, base_hash_power, referral_hash_bonus, is_frozen, is_banned
+                FROM users WHERE id = $1 FOR UPDATE`, c.UserID).
+                Scan(&usdtBalance, &baseHashPower, &referralBonus, &isFrozen, &isBanned)
+        if err != nil {
+                return false, false, fmt.Errorf("failed to lock user: %w", err)
+        }
+
+        if c.AutoRenew && c.DurationDays != nil && !isFrozen && !isBanned && usdtBalance.GreaterThanOrEqual(c.USDTCost) {
+                // Renewals keep the hash power and the price locked in at purchase
+                newExpiry := c.ExpiresAt.AddDate(0, 0, *c.DurationDays)
+                if !newExpiry.After(now) {
+                        // Lapsed for over a full term, e.g. while no instance
+                        // was running; start the new term now instead of
+                        // charging for time that has already passed
+                        newExpiry = now.AddDate(0, 0, *c.DurationDays)
+                }
+                if _, err := tx.Exec(ctx, "UPDATE users SET usdt_balance = usdt_balance - $1 WHERE id = $2",
+                        c.USDTCost.String(), c.UserID); err != nil {
+                        return false, false, fmt.Errorf("failed to charge renewal: %w", err)
+                }
+                if _, err := tx.Exec(ctx, `
+                        UPDATE hash_power_contracts
+                        SET expires_at = $1, renewal_count = renewal_count + 1
+                        WHERE id = $2`,
+                        newExpiry, c.ID); err != nil {
+                        return false, false, fmt.Errorf("failed to renew contract: %w", err)
+                }
+                if _, err := tx.Exec(ctx, `
+                        INSERT INTO hash_power_contract_renewals (contract_id, user_id, usdt_cost, hash_power, expires_at, renewed_at)
+                        VALUES ($1, $2, $3, $4, $5, $6)`,
+                        c.ID, c.UserID, c.USDTCost.String(), c.HashPower.String(), newExpiry, now); err != nil {
+                        return false, false, fmt.Errorf("failed to record renewal: %w", err)
+                }
+                renewed = true
+        } else {
+                // Stakes only lock hashrate that outlasts them (see createStake),
+                // but a stake whose final reward is still unpaid can be active
+                // past the expiry of a rental that ran to its unlock date
+                locked, err := lockedHashrate(ctx, tx, c.UserID)
+                if err != nil {
+                        return false, false, err
+                }
+                if decimal.Max(baseHashPower.Sub(c.HashPower), decimal.Zero).Add(referralBonus).LessThan(locked) {
+                        return false, false, nil
+                }
+                if _, err := tx.Exec(ctx, `
+                        UPDATE users
+                        SET base_hash_power = GREATEST(base_hash_power - $1, 0),
+                            hash_power = GREATEST(base_hash_power - $1, 0) + referral_hash_bonus
+                        WHERE id = $2`,
+                        c.HashPower.String(), c.UserID); err != nil {
+                        return false, false, fmt.Errorf("failed to remove hash power: %w", err)
+                }
+                if _, err := tx.Exec(ctx, "UPDATE hash_power_contracts SET status = $1 WHERE id = $2",
+                        contractStatusExpired, c.ID); err != nil {
+                        return false, false, fmt.Errorf("failed to expire contract: %w", err)
+                }
+        }
+
+        if err := tx.Commit(ctx); err != nil {
+                return false, false, fmt.Errorf("failed to commit settlement: %w", err)
+        }
+        return renewed, true, nil
+}
+
+// runContractExpiry is the scheduled job settling expired contracts
+func runContractExpiry(ctx context.Context, _ time.Time) error {
+        expired, renewed, err := settleExpiredContracts(ctx, clock.Now())
+        if err != nil {
+                return err
+        }
+        if expired > 0 || renewed > 0 {
+                log.Printf("Contracts settled: %d expired, %d renewed", expired, renewed)
+        }
+        return nil
+}
+
+// List active contracts endpoint
+func handleGetContracts(w http.ResponseWriter, r *http.Request) {
+        user := getUserFromContext(r.Context())
+        if user == nil {
+                writeErrorResponse(w, r, ErrCodeUnauthorized, "Unauthorized")
+                return
+        }
+
+        contracts, err := getActiveContracts(r.Context(), user.ID)
+        if err != nil {
+                writeErrorResponse(w, r, ErrCodeInternal, "Failed to load contracts")
+                return
+        }
+
+        perHash, err := dailyEarningsPerHash(r.Context())
+        if err != nil {
+                writeErrorResponse(w, r, ErrCodeInternal, "Failed to estimate earnings")
+                return
+        }
+
+        now := clock.Now()
+        items := make([]map[string]interface{}, 0, len(contracts))
+        for _, c := range contracts {
+                dailyEarnings := perHash.Mul(c.HashPower)
+
+                var secondsRemaining *int64
+                var projectedEarnings *string
+                if c.ExpiresAt != nil {
+                        remaining := c.ExpiresAt.Sub(now)
+                        if remaining < 0 {
+                                remaining = 0
+                        }
+                        seconds := int64(remaining.Seconds())
+                        secondsRemaining = &seconds
+
+                        days := decimal.NewFromFloat(remaining.Hours() / 24)
+                        projected := formatAmount(dailyEarnings.Mul(days), CurrencyGBTC)
+                        projectedEarnings = &projected
+                }
+
+                items = append(items, map[string]interface{}{
+                        "id":                c.ID,
+                        "hashPower":         formatHashPower(c.HashPower),
+                        "usdtCost":          formatAmount(c.USDTCost, CurrencyUSDT),
+                        "unitPrice":         c.UnitPrice.String(),
+                        "discountPercent":   c.DiscountPercent.StringFixed(2),
+                        "durationDays":      c.DurationDays,
+                        "autoRenew":         c.AutoRenew,
+                        "renewalCount":      c.RenewalCount,
+                        "startsAt":          c.StartsAt,
+                        "expiresAt":         c.ExpiresAt,
+                        "secondsRemaining":  secondsRemaining,
+                        "dailyEarnings":     formatAmount(dailyEarnings, CurrencyGBTC),
+                        "projectedEarnings": projectedEarnings,
+                })
+        }
+
+        writeJSONResponse(w, http.StatusOK, map[string]interface{}{"contracts": items})
+}
+
+type ContractAutoRenewRequest struct {
+        AutoRenew bool `json:"autoRenew"`
+}
+
+// Toggle contract auto-renew endpoint
+func handleSetContractAutoRenew(w http.ResponseWriter, r *http.Request) {
+        user := getUserFromContext(r.Context())
+        if user == nil {
+                writeErrorResponse(w, r, ErrCodeUnauthorized, "Unauthorized")
+                return
+        }
+
+        contractID := chi.URLParam(r, "id")
+        if !isUUID(contractID) {
+                writeErrorResponse(w, r, ErrCodeNotFound, "Active rental contract not found")
+                return
+        }
+
+        var req ContractAutoRenewRequest
+        if err := decodeAndValidate(w, r, &req); err != nil {
+                writeAPIError(w, r, err)
+                return
+        }
+
+        tag, err := db.Exec(r.Context(), `
+                UPDATE hash_power_contracts
+                SET auto_renew = $1
+                WHERE id = $2 AND user_id = $3 AND status = $4 AND duration_days IS NOT NULL`,
+                req.AutoRenew, contractID, user.ID, contractStatusActive)
+        if err != nil {
+                writeErrorResponse(w, r, ErrCodeInternal, "Failed to update contract")
+                return
+        }
+        if tag.RowsAffected() == 0 {
+                writeErrorResponse(w, r, ErrCodeNotFound, "Active rental contract not found")
+                return
+        }
+
+        writeJSONResponse(w, http.StatusOK, map[string]interface{}{
+                "message":   "Contract updated",
+                "autoRenew": req.AutoRenew,
+        })
+}
@@ -0,0 +1,404 @@
+package main
+
+import (
+        "context"
+        "crypto/hmac"
+        "crypto/rand"
+        "crypto/sha256"
+        "encoding/base64"
+        "encoding/hex"
+        "encoding/json"
+        "fmt"
+        "net/http"
+        "strings"
+        "time"
+
+        "github.com/jackc/pgx/v4"
+        "github.com/shopspring/decimal"
+)
+
+// Settings keys used for currency conversion
+const (
+        settingConversionFee      = "conversionFeePercent"
+        settingConversionQuoteTTL = "conversionQuoteTtlSeconds"
+        settingGBTCRate           = "gbtcUsdtRate"
+)
+
+var (
+        defaultConversionFee      = decimal.RequireFromString("0.01")
+        defaultConversionQuoteTTL = decimal.NewFromInt(30)
+)
+
+// quoteSigningKey signs conversion quotes; set from the environment in main
+var quoteSigningKey []byte
+
+// ConversionQuote is a priced, signed offer to convert between currencies.
+// The client returns the signed token to execute it before ExpiresAt.
+type ConversionQuote struct {
+        ID           string          `json:"id"`
+        UserID       string          `json:"userId"`
+        FromCurrency Currency        `json:"fromCurrency"`
+        ToCurrency   Currency        `json:"toCurrency"`
+        FromAmount   decimal.Decimal `json:"fromAmount"`
+        ToAmount     decimal.Decimal `json:"toAmount"`
+        Rate         decimal.Decimal `json:"rate"`
+        Fee          decimal.Decimal `json:"fee"`
+        FeePercent   decimal.Decimal `json:"feePercent"`
+        ExpiresAt    time.Time       `json:"expiresAt"`
+}
+
+// Conversion represents the conversions table
+type Conversion struct {
+        ID           string          `json:"id" db:"id"`
+        UserID       string          `json:"userId" db:"user_id"`
+        QuoteID      string          `json:"quoteId" db:"quote_id"`
+        FromCurrency string          `json:"fromCurrency" db:"from_currency"`
+        ToCurrency   string          `json:"toCurrency" db:"to_currency"`
+        FromAmount   decimal.Decimal `json:"fromAmount" db:"from_amount"`
+        ToAmount     decimal.Decimal `json:"toAmount" db:"to_amount"`
+        Rate         decimal.Decimal `json:"rate" db:"rate"`
+        Fee          decimal.Decimal `json:"fee" db:"fee"`
+        CreatedAt    time.Time       `json:"createdAt" db:"created_at"`
+}
+
+type ConversionQuoteRequest struct {
+        FromCurrency string `json:"fromCurrency" validate:"required,oneof=USDT BTC GBTC"`
+        ToCurrency   string `json:"toCurrency" validate:"required,oneof=USDT BTC GBTC"`
+        Amount       string `json:"amount" validate:"required,decimal=8"`
+}
+
+type ConvertRequest struct {
+        Quote string `json:"quote" validate:"required"`
+}
+
+// usdtValue returns the USDT price of one unit of currency
+func usdtValue(ctx context.Context, q rowQuerier, currency Currency) (decimal.Decimal, error) {
+        switch currency {
+        case CurrencyUSDT:
+                return decimal.NewFromInt(1), nil
+        case CurrencyBTC:
+                quote, err := getFreshBTCPrice(ctx, q)
+                if err != nil {
+                        return decimal.Zero, err
+                }
+                return quote.Price, nil
+        case CurrencyGBTC:
+                rate, err := getDecimalSetting(ctx, q, settingGBTCRate, decimal.Zero)
+                if err != nil {
+                        return decimal.Zero, err
+                }
+                if !rate.IsPositive() {
+                        return decimal.Zero, newAPIError(ErrCodePriceUnavailable, "GBTC conversions are not enabled")
+                }
+                return rate, nil
+        }
+        return decimal.Zero, fmt.Errorf("unknown currency %q", currency)
+}
+
+// quoteConversion prices converting amount of from into to. The fee is a
+// percentage spread taken from the converted amount.
+func quoteConversion(ctx context.Context, userID string, from, to Currency, amount decimal.Decimal) (*ConversionQuote, error) {
+        if from == to {
+                return nil, newAPIError(ErrCodeValidation, "Cannot convert a currency to itself")
+        }
+
+        fromValue, err := usdtValue(ctx, db, from)
+        if err != nil {
+                return nil, err
+        }
+        toValue, err := usdtValue(ctx, db, to)
+        if err != nil {
+                return nil, err
+        }
+
+        feePercent, err := getDecimalSetting(ctx, db, settingConversionFee, defaultConversionFee)
+        if err != nil {
+                return nil, err
+        }
+        ttl, err := getDecimalSetting(ctx, db, settingConversionQuoteTTL, defaultConversionQuoteTTL)
+        if err != nil {
+                return nil, err
+        }
+
+        toScale, _ := scaleOf(to)
+        rate := fromValue.Div(toValue)
+        gross := amount.Mul(rate)
+        fee := gross.Mul(feePercent).Div(decimal.NewFromInt(100)).RoundUp(toScale)
+        net := gross.Sub(fee).Truncate(toScale)
+        if !net.IsPositive() {
+                return nil, newAPIError(ErrCodeValidation, "Amount is too small to convert")
+        }
+
+        id := make([]byte, 16)
+        if _, err := rand.Read(id); err != nil {
+                return nil, fmt.Errorf("failed to generate quote id: %w", err)
+        }
+
+        return &ConversionQuote{
+                ID:           hex.EncodeToString(id),
+                UserID:       userID,
+                FromCurrency: from,
+                ToCurrency:   to,
+                FromAmount:   amount,
+                ToAmount:     net,
+                Rate:         rate.Truncate(12),
+                Fee:          fee,
+                FeePercent:   feePercent,
+                ExpiresAt:    clock.Now().Add(time.Duration(ttl.IntPart()) * time.Second),
+        }, nil
+}
+
+// signQuote encodes a quote as payload.signature using HMAC-SHA256
+func signQuote(quote *ConversionQuote) (string, error) {
+        payload, err := json.Marshal(quote)
+        if err != nil {
+                return "", fmt.Errorf("failed to encode quote: %w", err)
+        }
+        mac := hmac.New(sha256.New, quoteSigningKey)
+        mac.Write(payload)
+        return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// verifyQuote checks a signed quote's signature and returns its contents
+func verifyQuote(token string) (*ConversionQuote, error) {
+        invalid := newAPIError(ErrCodeValidation, "Invalid conversion quote")
+
+        encodedPayload, encodedSig, ok := strings.Cut(token, ".")
+        if !ok {
+                return nil, invalid
+        }
+        payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+        if err != nil {
+                return nil, invalid
+        }
+        sig, err := base64.RawURLEncoding.DecodeString(encodedSig)
+        if err != nil {
+                return nil, invalid
+        }
+
+        mac := hmac.New(sha256.New, quoteSigningKey)
+        mac.Write(payload)
+        if !hmac.Equal(sig, mac.Sum(nil)) {
+                return nil, invalid
+        }
+
+        var quote ConversionQuote
+        if err := json.Unmarshal(payload, &quote); err != nil {
+                return nil, invalid
+        }
+        return &quote, nil
+}
+
+// executeConversion applies a verified quote to the user's balances and
+// records it. Each quote can be executed at most once.
+func executeConversion(ctx context.Context, userID string, quote *ConversionQuote) (*Conversion, error) {
+        if quote.UserID != userID {
+                return nil, newAPIError(ErrCodeForbidden, "Quote was issued to another user")
+        }
+        if clock.Now().After(quote.ExpiresAt) {
+                return nil, newAPIError(ErrCodeInvalidState, "Quote has expired; request a new one")
+        }
+
+        fromColumn, err := balanceColumn(quote.FromCurrency)
+        if err != nil {
+                return nil, err
+        }
+        toColumn, err := balanceColumn(quote.ToCurrency)
+        if err != nil {
+                return nil, err
+        }
+
+        tx, err := db.Begin(ctx)
+        if err != nil {
+                return nil, fmt.Errorf("failed to begin transaction: %w", err)
+        }
+        defer tx.Rollback(ctx)
+
+        var balance decimal.Decimal
+        err = tx.QueryRow(ctx, "SELECT "+fromColumn+" FROM users WHERE id = $1 FOR UPDATE", userID).Scan(&balance)
+        if err == pgx.ErrNoRows {
+                return nil, newAPIError(ErrCodeNotFound, "User not found")
+        }
+        if err != nil {
+                return nil, fmt.Errorf("failed to lock user: %w", err)
+        }
+        if balance.LessThan(quote.FromAmount) {
+                return nil, newAPIError(ErrCodeInsufficientFunds, fmt.Sprintf("Insufficient %s balance", quote.FromCurrency))
+        }
+
+        conversion := Conversion{
+                UserID:       userID,
+                QuoteID:      quote.ID,
+                FromCurrency: string(quote.FromCurrency),
+                ToCurrency:   string(quote.ToCurrency),
+                FromAmount:   quote.FromAmount,
+                ToAmount:     quote.ToAmount,
+                Rate:         quote.Rate,
+                Fee:          quote.Fee,
+        }
+        err = tx.QueryRow(ctx, `
+                INSERT INTO conversions (user_id, quote_id, from_currency, to_currency, from_amount, to_amount, rate, fee, created_at)
+                VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+                ON CONFLICT (quote_id) DO NOTHING
+                RETURNING id, created_at`,
+                userID, quote.ID, conversion.FromCurrency, conversion.ToCurrency, quote.FromAmount.String(),
+                quote.ToAmount.String(), quote.Rate.String(), quote.Fee.String(), clock.Now(),
+        ).Scan(&conversion.ID, &conversion.CreatedAt)
+        if err == pgx.ErrNoRows {
+                return nil, newAPIError(ErrCodeConflict, "Quote has already been used")
+        }
+        if err != nil {
+                return nil, fmt.Errorf("failed to record conversion: %w", err)
+        }
+
+        _, err = tx.Exec(ctx,
+                "UPDATE users SET "+fromColumn+" = "+fromColumn+" - $1, "+toColumn+" = "+toColumn+" + $2 WHERE id = $3",
+                quote.FromAmount.String(), quote.ToAmount.String(), userID)
+        if err != nil {
+                return nil, fmt.Errorf("failed to update balances: %w", err)
+        }
+
+        if err := tx.Commit(ctx); err != nil {
+                return nil, fmt.Errorf("failed to commit conversion: %w", err)
+        }
+        return &conversion, nil
+}
+
+// Conversion quote endpoint
+func handleConversionQuote(w http.ResponseWriter, r *http.Request) {
+        user := getUserFromContext(r.Context())
+        if user == nil {
+                writeErrorResponse(w, r, ErrCodeUnauthorized, "Unauthorized")
+                return
+        }
+
+        var req ConversionQuoteRequest
+        if err := decodeAndValidate(w, r, &req); err != nil {
+                writeAPIError(w, r, err)
+                return
+        }
+
+        from, err := parseCurrency(req.FromCurrency)
+        if err != nil {
+                writeAPIError(w, r, err)
+                return
+        }
+        to, err := parseCurrency(req.ToCurrency)
+        if err != nil {
+                writeAPIError(w, r, err)
+                return
+        }
+        amount, err := parseAmount(req.Amount, from)
+        if err != nil {
+                writeAPIError(w, r, err)
+                return
+        }
+
+        quote, err := quoteConversion(r.Context(), user.ID, from, to, amount)
+        if err != nil {
+                writeAPIError(w, r, err)
+                return
+        }
+        token, err := signQuote(quote)
+        if err != nil {
+                writeAPIError(w, r, err)
+                return
+        }
+
+        writeJSONResponse(w, http.StatusOK, map[string]interface{}{
+                "quote":        token,
+                "quoteId":      quote.ID,
+                "fromCurrency": quote.FromCurrency,
+                "toCurrency":   quote.ToCurrency,
+                "fromAmount":   formatAmount(quote.FromAmount, quote.FromCurrency),
+                "toAmount":     formatAmount(quote.ToAmount, quote.ToCurrency),
+                "rate":         quote.Rate.String(),
+                "fee":          formatAmount(quote.Fee, quote.ToCurrency),
+                "feePercent":   quote.FeePercent.String(),
+                "expiresAt":    quote.ExpiresAt,
+        })
+}
+
+// Execute conversion endpoint
+func handleConvert(w http.ResponseWriter, r *http.Request) {
+        user := getUserFromContext(r.Context())
+        if user == nil {
+                writeErrorResponse(w, r, ErrCodeUnauthorized, "Unauthorized")
+                return
+        }
+
+        var req ConvertRequest
+        if err := decodeAndValidate(w, r, &req); err != nil {
+                writeAPIError(w, r, err)
+                return
+        }
+
+        quote, err := verifyQuote(req.Quote)
+        if err != nil {
+                writeAPIError(w, r, err)
+                return
+        }
+
+        conversion, err := executeConversion(r.Context(), user.ID, quote)
+        if err != nil {
+                writeAPIError(w, r, err)
+                return
+        }
+
+        writeJSONResponse(w, http.StatusOK, map[string]interface{}{
+                "message":    "Conversion successful",
+                "conversion": conversionResponse(conversion),
+        })
+}
+
+// Conversion history endpoint
+func handleGetConversions(w http.ResponseWriter, r *http.Request) {
+        user := getUserFromContext(r.Context())
+        if user == nil {
+                writeErrorResponse(w, r, ErrCodeUnauthorized, "Unauthorized")
+                return
+        }
+
+        rows, err := db.Query(r.Context(), `
+                SELECT id, user_id, quote_id, from_currency, to_currency, from_amount, to_amount, rate, fee, created_at
+                FROM conversions
+                WHERE user_id = $1
+                ORDER BY created_at DESC
+                LIMIT 100`, user.ID)
+        if err != nil {
+                writeErrorResponse(w, r, ErrCodeInternal, "Failed to load conversions")
+                return
+        }
+        defer rows.Close()
+
+        conversions := make([]map[string]interface{}, 0)
+        for rows.Next() {
+                var c Conversion
+                if err := rows.Scan(&c.ID, &c.UserID, &c.QuoteID, &c.FromCurrency, &c.ToCurrency,
+                        &c.FromAmount, &c.ToAmount, &c.Rate, &c.Fee, &c.CreatedAt); err != nil {
+                        writeErrorResponse(w, r, ErrCodeInternal, "Failed to load conversions")
+                        return
+                }
+                conversions = append(conversions, conversionResponse(&c))
+        }
+        if rows.Err() != nil {
+                writeErrorResponse(w, r, ErrCodeInternal, "Failed to load conversions")
+                return
+        }
+
+        writeJSONResponse(w, http.StatusOK, conversions)
+}
+
+func conversionResponse(c *Conversion) map[string]interface{} {
+        return map[string]interface{}{
+                "id":           c.ID,
+                "quoteId":      c.QuoteID,
+                "fromCurrency": c.FromCurrency,
+                "toCurrency":   c.ToCurrency,
+                "fromAmount":   formatAmount(c.FromAmount, Currency(c.FromCurrency)),
+                "toAmount":     formatAmount(c.ToAmount, Currency(c.ToCurrency)),
+                "rate":         c.Rate.String(),
+                "fee":          formatAmount(c.Fee, Currency(c.ToCurrency)),
+                "createdAt":    c.CreatedAt,
+        }
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+        "encoding/base64"
+        "strings"
+        "testing"
+        "time"
+
+        "github.com/shopspring/decimal"
+)
+
+func testQuote() *ConversionQuote {
+        return &ConversionQuote{
+                ID:           "0123456789abcdef0123456789abcdef",
+                UserID:       "user-1",
+                FromCurrency: CurrencyUSDT,
+                ToCurrency:   CurrencyBTC,
+                FromAmount:   decimal.RequireFromString("1000"),
+                ToAmount:     decimal.RequireFromString("0.01052631"),
+                Rate:         decimal.RequireFromString("0.000010526315"),
+                Fee:          decimal.RequireFromString("0.00000011"),
+                FeePercent:   decimal.RequireFromString("0.01"),
+                ExpiresAt:    time.Date(2025, 3, 1, 12, 0, 30, 0, time.UTC),
+        }
+}
+
+func TestSignQuoteRoundTrip(t *testing.T) {
+        defer func(key []byte) { quoteSigningKey = key }(quoteSigningKey)
+        quoteSigningKey = []byte("test signing key")
+
+        quote := testQuote()
+        token, err := signQuote(quote)
+        if err != nil {
+                t.Fatalf("signQuote: %v", err)
+        }
+        got, err := verifyQuote(token)
+        if err != nil {
+                t.Fatalf("verifyQuote: %v", err)
+        }
+        if got.ID != quote.ID || got.UserID != quote.UserID || got.FromCurrency != quote.FromCurrency ||
+                got.ToCurrency != quote.ToCurrency || !got.FromAmount.Equal(quote.FromAmount) ||
+                !got.ToAmount.Equal(quote.ToAmount) || !got.Rate.Equal(quote.Rate) || !got.Fee.Equal(quote.Fee) ||
+                !got.ExpiresAt.Equal(quote.ExpiresAt) {
+                t.Errorf("verified quote = %+v, want %+v", got, quote)
+        }
+}
+
+func TestVerifyQuoteRejectsTampering(t *testing.T) {
+        defer func(key []byte) { quoteSigningKey = key }(quoteSigningKey)
+        quoteSigningKey = []byte("test signing key")
+
+        token, err := signQuote(testQuote())
+        if err != nil {
+                t.Fatalf("signQuote: %v", err)
+        }
+        payload, sig, _ := strings.Cut(token, ".")
+
+        raw, _ := base64.RawURLEncoding.DecodeString(payload)
+        inflated := strings.Replace(string(raw), `"toAmount":"0.01052631"`, `"toAmount":"1.01052631"`, 1)
+        if inflated == string(raw) {
+                t.Fatal("quote payload does not contain the expected toAmount")
+        }
+
+        tests := map[string]string{
+                "edited payload":   base64.RawURLEncoding.EncodeToString([]byte(inflated)) + "." + sig,
+                "edited signature": payload + "." + base64.RawURLEncoding.EncodeToString([]byte("forged")),
+                "no signature":     payload,
+                "bad encoding":     payload + ".***",
+                "empty":            "",
+        }
+        for name, token := range tests {
+                t.Run(name, func(t *testing.T) {
+                        _, err := verifyQuote(token)
+                        apiErr, ok := err.(*APIError)
+                        if !ok || apiErr.Code != ErrCodeValidation {
+                                t.Errorf("err = %v, want %s", err, ErrCodeValidation)
+                        }
+                })
+        }
+
+        t.Run("other key", func(t *testing.T) {
+                quoteSigningKey = []byte("another key")
+                if _, err := verifyQuote(token); err == nil {
+                        t.Error("verified a quote signed with a different key")
+                }
+        })
+}
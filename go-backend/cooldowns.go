@@ -0,0 +1,288 @@
+package main
+
+import (
+        "context"
+        "encoding/json"
+        "fmt"
+        "math"
+        "net/http"
+        "sort"
+        "time"
+
+        "github.com/shopspring/decimal"
+)
+
+// fundingKind distinguishes deposits from withdrawals for cooldowns and caps
+type fundingKind string
+
+const (
+        fundingDeposit    fundingKind = "deposit"
+        fundingWithdrawal fundingKind = "withdrawal"
+)
+
+// fundingTables maps each funding kind to the table its requests live in
+var fundingTables = map[fundingKind]string{
+        fundingDeposit:    "deposits",
+        fundingWithdrawal: "withdrawals",
+}
+
+// Settings keys holding the JSON funding policy of each kind
+var fundingPolicySettings = map[fundingKind]string{
+        fundingDeposit:    "depositLimits",
+        fundingWithdrawal: "withdrawalLimits",
+}
+
+// fundingPolicy is the cooldown window and amount caps applied to one class
+// of users. A currency missing from Daily or Monthly has no cap.
+type fundingPolicy struct {
+        CooldownHours float64                      `json:"cooldownHours"`
+        Daily         map[Currency]decimal.Decimal `json:"daily"`
+        Monthly       map[Currency]decimal.Decimal `json:"monthly"`
+}
+
+// fundingPolicies holds the policy of each KYC tier, keyed by tier name
+type fundingPolicies map[string]fundingPolicy
+
+// defaultFundingPolicies is used when a kind's limits setting has not been configured
+var defaultFundingPolicies = map[fundingKind]fundingPolicies{
+        fundingDeposit: {
+                "unverified": {
+                        CooldownHours: 1,
+                        Daily:         map[Currency]decimal.Decimal{CurrencyUSDT: decimal.NewFromInt(5000), CurrencyBTC: decimal.RequireFromString("0.1")},
+                        Monthly:       map[Currency]decimal.Decimal{CurrencyUSDT: decimal.NewFromInt(20000), CurrencyBTC: decimal.RequireFromString("0.5")},
+                },
+                "basic": {
+                        Daily:   map[Currency]decimal.Decimal{CurrencyUSDT: decimal.NewFromInt(50000), CurrencyBTC: decimal.NewFromInt(1)},
+                        Monthly: map[Currency]decimal.Decimal{CurrencyUSDT: decimal.NewFromInt(500000), CurrencyBTC: decimal.NewFromInt(10)},
+                },
+                "advanced": {
+                        Daily:   map[Currency]decimal.Decimal{CurrencyUSDT: decimal.NewFromInt(250000), CurrencyBTC: decimal.NewFromInt(5)},
+                        Monthly: map[Currency]decimal.Decimal{CurrencyUSDT: decimal.NewFromInt(2500000), CurrencyBTC: decimal.NewFromInt(50)},
+                },
+        },
+        fundingWithdrawal: {
+                "unverified": {
+                        CooldownHours: 24,
+                        Daily: map[Currency]decimal.Decimal{CurrencyUSDT: decimal.NewFromInt(500), CurrencyBTC: decimal.RequireFromString("0.01"),
+                                CurrencyGBTC: decimal.NewFromInt(1)},
+                        Monthly: map[Currency]decimal.Decimal{CurrencyUSDT: decimal.NewFromInt(2000), CurrencyBTC: decimal.RequireFromString("0.05"),
+                                CurrencyGBTC: decimal.NewFromInt(5)},
+                },
+                "basic": {
+                        CooldownHours: 4,
+                        Daily: map[Currency]decimal.Decimal{CurrencyUSDT: decimal.NewFromInt(10000), CurrencyBTC: decimal.RequireFromString("0.5"),
+                                CurrencyGBTC: decimal.NewFromInt(50)},
+                        Monthly: map[Currency]decimal.Decimal{CurrencyUSDT: decimal.NewFromInt(100000), CurrencyBTC: decimal.NewFromInt(5),
+                                CurrencyGBTC: decimal.NewFromInt(500)},
+                },
+                "advanced": {
+                        CooldownHours: 1,
+                        Daily: map[Currency]decimal.Decimal{CurrencyUSDT: decimal.NewFromInt(50000), CurrencyBTC: decimal.NewFromInt(2),
+                                CurrencyGBTC: decimal.NewFromInt(250)},
+                        Monthly: map[Currency]decimal.Decimal{CurrencyUSDT: decimal.NewFromInt(500000), CurrencyBTC: decimal.NewFromInt(20),
+                                CurrencyGBTC: decimal.NewFromInt(2500)},
+                },
+        },
+}
+
+// loadFundingPolicy returns the policy of a funding kind for a KYC tier. A
+// tier without a configured policy falls back to the next lower tier.
+func loadFundingPolicy(ctx context.Context, q rowQuerier, kind fundingKind, tier int) (*fundingPolicy, error) {
+        policies := defaultFundingPolicies[kind]
+
+        key := fundingPolicySettings[kind]
+        value, ok, err := getSystemSetting(ctx, q, key)
+        if err != nil {
+                return nil, err
+        }
+        if ok {
+                var configured fundingPolicies
+                if err := json.Unmarshal([]byte(value), &configured); err != nil {
+                        return nil, fmt.Errorf("setting %s is not valid JSON: %w", key, err)
+                }
+                policies = configured
+        }
+
+        policy, ok := policyForTier(policies, tier)
+        if !ok {
+                return nil, fmt.Errorf("setting %s has no policy for KYC tier %s", key, kycTierName(tier))
+        }
+        return &policy, nil
+}
+
+// fundingUsage is how much of a currency a user has requested in the
+// current UTC day and month, excluding rejected requests
+type fundingUsage struct {
+        LastRequestAt *time.Time
+        Daily         decimal.Decimal
+        Monthly       decimal.Decimal
+}
+
+func getFundingUsage(ctx context.Context, q rowQuerier, kind fundingKind, userID string, currency Currency, now time.Time) (*fundingUsage, error) {
+        dayStart := now.Truncate(24 * time.Hour)
+        monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+        var usage fundingUsage
+        err := q.QueryRow(ctx, fmt.Sprintf(`
+                SELECT MAX(created_at),
+                       COALESCE(SUM(amount) FILTER (WHERE currency = $2 AND created_at >= $3), 0),
+                       COALESCE(SUM(amount) FILTER (WHERE currency = $2 AND created_at >= $4), 0)
+                FROM %s
+                WHERE user_id = $1 AND status <> 'rejected'`, fundingTables[kind]),
+                userID, string(currency), dayStart, monthStart,
+        ).Scan(&usage.LastRequestAt, &usage.Daily, &usage.Monthly)
+        if err != nil {
+                return nil, fmt.Errorf("failed to get %s usage: %w", kind, err)
+        }
+        return &usage, nil
+}
+
+// cooldownRemaining returns how long the user must still wait before the
+// next request, or zero if the cooldown has passed
+func (p *fundingPolicy) cooldownRemaining(lastRequestAt *time.Time, now time.Time) time.Duration {
+        if lastRequestAt == nil || p.CooldownHours <= 0 {
+                return 0
+        }
+        window := time.Duration(p.CooldownHours * float64(time.Hour))
+        remaining := lastRequestAt.Add(window).Sub(now)
+        if remaining < 0 {
+                return 0
+        }
+        return remaining
+}
+
+// hoursRemaining rounds a duration up to hundredths of an hour
+func hoursRemaining(d time.Duration) float64 {
+        return math.Ceil(d.Hours()*100) / 100
+}
+
+// checkFundingAllowed enforces the cooldown and the daily and monthly caps
+// of a new deposit or withdrawal. The caller must hold the user's row lock so
+// concurrent requests cannot both pass.
+func checkFundingAllowed(ctx context.Context, q rowQuerier, kind fundingKind, userID string, tier int, currency Currency, amount decimal.Decimal, now time.Time) error {
+        policy, err := loadFundingPolicy(ctx, q, kind, tier)
+        if err != nil {
+                return err
+        }
+        usage, err := getFundingUsage(ctx, q, kind, userID, currency, now)
+        if err != nil {
+                return err
+        }
+
+        if remaining := policy.cooldownRemaining(usage.LastRequestAt, now); remaining > 0 {
+                return newAPIError(ErrCodeCooldownActive, fmt.Sprintf(
+                        "Please wait %.2f hours before making another %s", hoursRemaining(remaining), kind))
+        }
+
+        if limit, ok := policy.Daily[currency]; ok && usage.Daily.Add(amount).GreaterThan(limit) {
+                return newAPIError(ErrCodeLimitExceeded, fmt.Sprintf("Daily %s limit exceeded; %s %s remaining today",
+                        kind, formatAmount(decimal.Max(limit.Sub(usage.Daily), decimal.Zero), currency), currency))
+        }
+        if limit, ok := policy.Monthly[currency]; ok && usage.Monthly.Add(amount).GreaterThan(limit) {
+                return newAPIError(ErrCodeLimitExceeded, fmt.Sprintf("Monthly %s limit exceeded; %s %s remaining this month",
+                        kind, formatAmount(decimal.Max(limit.Sub(usage.Monthly), decimal.Zero), currency), currency))
+        }
+        return nil
+}
+
+// fundingStatus builds the cooldown and remaining allowance of every capped
+// currency for the cooldowns endpoint
+func fundingStatus(ctx context.Context, kind fundingKind, userID string, tier int, now time.Time) (map[string]interface{}, error) {
+        policy, err := loadFundingPolicy(ctx, db, kind, tier)
+        if err != nil {
+                return nil, err
+        }
+
+        currencies := make([]Currency, 0)
+        seen := make(map[Currency]bool)
+        for _, caps := range []map[Currency]decimal.Decimal{policy.Daily, policy.Monthly} {
+                for currency := range caps {
+                        if !seen[currency] {
+                                seen[currency] = true
+                                currencies = append(currencies, currency)
+                        }
+                }
+        }
+        sort.Slice(currencies, func(i, j int) bool { return currencies[i] < currencies[j] })
+
+        var lastRequestAt *time.Time
+        allowances := make(map[string]interface{}, len(currencies))
+        for _, currency := range currencies {
+                usage, err := getFundingUsage(ctx, db, kind, userID, currency, now)
+                if err != nil {
+                        return nil, err
+                }
+                lastRequestAt = usage.LastRequestAt
+
+                allowance := map[string]interface{}{
+                        "dailyLimit":       nil,
+                        "dailyRemaining":   nil,
+                        "monthlyLimit":     nil,
+                        "monthlyRemaining": nil,
+                }
+                if limit, ok := policy.Daily[currency]; ok {
+                        allowance["dailyLimit"] = formatAmount(limit, currency)
+                        allowance["dailyRemaining"] = formatAmount(decimal.Max(limit.Sub(usage.Daily), decimal.Zero), currency)
+                }
+                if limit, ok := policy.Monthly[currency]; ok {
+                        allowance["monthlyLimit"] = formatAmount(limit, currency)
+                        allowance["monthlyRemaining"] = formatAmount(decimal.Max(limit.Sub(usage.Monthly), decimal.Zero), currency)
+                }
+                allowances[string(currency)] = allowance
+        }
+        if len(currencies) == 0 {
+                usage, err := getFundingUsage(ctx, db, kind, userID, CurrencyUSDT, now)
+                if err != nil {
+                        return nil, err
+                }
+                lastRequestAt = usage.LastRequestAt
+        }
+
+        remaining := policy.cooldownRemaining(lastRequestAt, now)
+        var nextAllowedAt *time.Time
+        if remaining > 0 {
+                t := now.Add(remaining)
+                nextAllowedAt = &t
+        }
+
+        canKey := "canDeposit"
+        if kind == fundingWithdrawal {
+                canKey = "canWithdraw"
+        }
+        return map[string]interface{}{
+                canKey:           remaining == 0,
+                "hoursRemaining": hoursRemaining(remaining),
+                "cooldownHours":  policy.CooldownHours,
+                "nextAllowedAt":  nextAllowedAt,
+                "allowances":     allowances,
+        }, nil
+}
+
+// Cooldowns endpoint
+func handleGetCooldowns(w http.ResponseWriter, r *http.Request) {
+        user := getUserFromContext(r.Context())
+        if user == nil {
+                writeErrorResponse(w, r, ErrCodeUnauthorized, "Unauthorized")
+                return
+        }
+
+        now := clock.Now()
+        tier := user.kycTier()
+        deposit, err := fundingStatus(r.Context(), fundingDeposit, user.ID, tier, now)
+        if err != nil {
+                writeErrorResponse(w, r, ErrCodeInternal, "Failed to load cooldowns")
+                return
+        }
+        withdrawal, err := fundingStatus(r.Context(), fundingWithdrawal, user.ID, tier, now)
+        if err != nil {
+                writeErrorResponse(w, r, ErrCodeInternal, "Failed to load cooldowns")
+                return
+        }
+
+        writeJSONResponse(w, http.StatusOK, map[string]interface{}{
+                "kycVerified": user.KYCVerified,
+                "kycTier":     kycTierName(tier),
+                "deposit":     deposit,
+                "withdrawal":  withdrawal,
+        })
+}
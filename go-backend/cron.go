@@ -0,0 +1,182 @@
+package main
+
+import (
+        "fmt"
+        "strconv"
+        "strings"
+        "time"
+)
+
+// cronSchedule computes the run times of a job
+type cronSchedule interface {
+        // Next returns the first run time strictly after t, or the zero time if
+        // there is none within five years
+        Next(t time.Time) time.Time
+}
+
+// everySchedule runs at fixed intervals aligned to the Unix epoch
+type everySchedule struct {
+        interval time.Duration
+}
+
+func (s everySchedule) Next(t time.Time) time.Time {
+        return t.UTC().Truncate(s.interval).Add(s.interval)
+}
+
+// fieldSchedule is a standard five-field cron expression evaluated in UTC
+type fieldSchedule struct {
+        minute, hour, dom, month, dow uint64
+        domStar, dowStar              bool
+}
+
+// cronField describes the range of one field of a cron expression
+type cronField struct {
+        name     string
+        min, max int
+}
+
+var cronFields = []cronField{
+        {"minute", 0, 59},
+        {"hour", 0, 23},
+        {"day of month", 1, 31},
+        {"month", 1, 12},
+        {"day of week", 0, 7},
+}
+
+// cronAliases maps the supported @ shorthands to their expressions
+var cronAliases = map[string]string{
+        "@hourly":   "0 * * * *",
+        "@daily":    "0 0 * * *",
+        "@midnight": "0 0 * * *",
+        "@weekly":   "0 0 * * 0",
+        "@monthly":  "0 0 1 * *",
+}
+
+// parseCronSchedule parses a five-field cron expression (minute hour
+// day-of-month month day-of-week), one of the @ aliases, or "@every <duration>".
+func parseCronSchedule(spec string) (cronSchedule, error) {
+        spec = strings.TrimSpace(spec)
+        if rest, ok := strings.CutPrefix(spec, "@every "); ok {
+                interval, err := time.ParseDuration(strings.TrimSpace(rest))
+                if err != nil || interval < time.Second {
+                        return nil, fmt.Errorf("invalid interval in %q", spec)
+                }
+                return everySchedule{interval: interval}, nil
+        }
+        if expr, ok := cronAliases[spec]; ok {
+                spec = expr
+        }
+
+        fields := strings.Fields(spec)
+        if len(fields) != len(cronFields) {
+                return nil, fmt.Errorf("cron expression %q must have %d fields", spec, len(cronFields))
+        }
+
+        var bits [5]uint64
+        for i, field := range fields {
+                b, err := parseCronField(field, cronFields[i])
+                if err != nil {
+                        return nil, fmt.Errorf("cron expression %q: %w", spec, err)
+                }
+                bits[i] = b
+        }
+
+        // Sunday may be written as 0 or 7
+        if bits[4]&(1<<7) != 0 {
+                bits[4] = bits[4]&^(1<<7) | 1
+        }
+
+        return &fieldSchedule{
+                minute:  bits[0],
+                hour:    bits[1],
+                dom:     bits[2],
+                month:   bits[3],
+                dow:     bits[4],
+                domStar: fields[2] == "*",
+                dowStar: fields[4] == "*",
+        }, nil
+}
+
+// parseCronField parses a comma-separated list of values, ranges and steps
+// into a bit set
+func parseCronField(field string, f cronField) (uint64, error) {
+        var bits uint64
+        for _, part := range strings.Split(field, ",") {
+                rangePart, stepPart, hasStep := strings.Cut(part, "/")
+                step := 1
+                if hasStep {
+                        n, err := strconv.Atoi(stepPart)
+                        if err != nil || n < 1 {
+                                return 0, fmt.Errorf("invalid step %q in %s", stepPart, f.name)
+                        }
+                        step = n
+                }
+
+                lo, hi := f.min, f.max
+                switch {
+                case rangePart == "*":
+                case strings.Contains(rangePart, "-"):
+                        a, b, _ := strings.Cut(rangePart, "-")
+                        var err1, err2 error
+                        lo, err1 = strconv.Atoi(a)
+                        hi, err2 = strconv.Atoi(b)
+                        if err1 != nil || err2 != nil {
+                                return 0, fmt.Errorf("invalid range %q in %s", rangePart, f.name)
+                        }
+                default:
+                        n, err := strconv.Atoi(rangePart)
+                        if err != nil {
+                                return 0, fmt.Errorf("invalid value %q in %s", rangePart, f.name)
+                        }
+                        lo = n
+                        if !hasStep {
+                                hi = n
+                        }
+                }
+                if lo < f.min || hi > f.max || lo > hi {
+                        return 0, fmt.Errorf("%s must be between %d and %d", f.name, f.min, f.max)
+                }
+
+                for v := lo; v <= hi; v += step {
+                        bits |= 1 << uint(v)
+                }
+        }
+        return bits, nil
+}
+
+// dayMatches applies the cron rule that when both day fields are
+// restricted, a day matching either one is a match
+func (s *fieldSchedule) dayMatches(t time.Time) bool {
+        domMatch := s.dom&(1<<uint(t.Day())) != 0
+        dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+        if s.domStar || s.dowStar {
+                return domMatch && dowMatch
+        }
+        return domMatch || dowMatch
+}
+
+func (s *fieldSchedule) Next(t time.Time) time.Time {
+        t = t.UTC().Truncate(time.Minute).Add(time.Minute)
+        limit := t.AddDate(5, 0, 0)
+
+        for t.Before(limit) {
+                if s.month&(1<<uint(t.Month())) == 0 {
+                        t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+                        continue
+                }
+                if !s.dayMatches(t) {
+                        t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+                        continue
+                }
+                if s.hour&(1<<uint(t.Hour())) == 0 {
+                        t = t.Truncate(time.Hour).Add(time.Hour)
+                        continue
+                }
+                if s.minute&(1<<uint(t.Minute())) == 0 {
+                        t = t.Add(time.Minute)
+                        continue
+                }
+                return t
+        }
+        return time.Time{}
+}
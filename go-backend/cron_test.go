@@ -0,0 +1,119 @@
+package main
+
+import (
+        "strings"
+        "testing"
+        "time"
+)
+
+func TestParseCronScheduleErrors(t *testing.T) {
+        tests := map[string]string{
+                "":             "must have 5 fields",
+                "* * * *":      "must have 5 fields",
+                "* * * * * *":  "must have 5 fields",
+                "60 * * * *":   "minute must be between 0 and 59",
+                "* 24 * * *":   "hour must be between 0 and 23",
+                "* * 0 * *":    "day of month must be between 1 and 31",
+                "* * * 13 *":   "month must be between 1 and 12",
+                "* * * * 8":    "day of week must be between 0 and 7",
+                "5-1 * * * *":  "minute must be between 0 and 59",
+                "*/0 * * * *":  `invalid step "0" in minute`,
+                "*/x * * * *":  `invalid step "x" in minute`,
+                "a * * * *":    `invalid value "a" in minute`,
+                "1-b * * * *":  `invalid range "1-b" in minute`,
+                "@yearly":      "must have 5 fields",
+                "@every 10":    "invalid interval",
+                "@every 500ms": "invalid interval",
+                "@every -1m":   "invalid interval",
+        }
+        for spec, want := range tests {
+                t.Run(spec, func(t *testing.T) {
+                        _, err := parseCronSchedule(spec)
+                        if err == nil || !strings.Contains(err.Error(), want) {
+                                t.Errorf("err = %v, want it to contain %q", err, want)
+                        }
+                })
+        }
+}
+
+func TestCronScheduleNext(t *testing.T) {
+        date := func(s string) time.Time {
+                t, err := time.Parse("2006-01-02 15:04", s)
+                if err != nil {
+                        panic(err)
+                }
+                return t
+        }
+        tests := []struct {
+                spec string
+                from string
+                want string
+        }{
+                // Minute steps, strictly after from
+                {"*/10 * * * *", "2025-03-01 00:00", "2025-03-01 00:10"},
+                {"*/10 * * * *", "2025-03-01 00:09", "2025-03-01 00:10"},
+                {"*/10 * * * *", "2025-03-01 23:55", "2025-03-02 00:00"},
+                {"* * * * *", "2025-03-01 10:15", "2025-03-01 10:16"},
+                // Ranges, lists and stepped ranges
+                {"0 9-17 * * *", "2025-03-01 17:00", "2025-03-02 09:00"},
+                {"15,45 * * * *", "2025-03-01 10:20", "2025-03-01 10:45"},
+                {"0 8-20/6 * * *", "2025-03-01 14:00", "2025-03-01 20:00"},
+                {"30 5/12 * * *", "2025-03-01 06:00", "2025-03-01 17:30"},
+                // Day, month and year boundaries
+                {"0 0 * * *", "2025-12-31 23:59", "2026-01-01 00:00"},
+                {"0 0 1 * *", "2025-01-31 12:00", "2025-02-01 00:00"},
+                {"0 0 31 * *", "2025-04-01 00:00", "2025-05-31 00:00"},
+                {"0 0 29 2 *", "2025-03-01 00:00", "2028-02-29 00:00"},
+                {"0 12 * 6 *", "2025-06-30 12:00", "2026-06-01 12:00"},
+                // Day of week, with Sunday as 0 or 7
+                {"0 0 * * 1", "2025-03-01 00:00", "2025-03-03 00:00"},
+                {"0 0 * * 0", "2025-03-01 00:00", "2025-03-02 00:00"},
+                {"0 0 * * 7", "2025-03-01 00:00", "2025-03-02 00:00"},
+                {"0 0 * * 5-7", "2025-03-03 00:00", "2025-03-07 00:00"},
+                {"@weekly", "2025-03-02 00:00", "2025-03-09 00:00"},
+                // Both day fields restricted: either may match
+                {"0 0 13 * 5", "2025-03-01 00:00", "2025-03-07 00:00"},
+                {"0 0 13 * 5", "2025-06-07 00:00", "2025-06-13 00:00"},
+                {"0 0 1 * 1", "2025-03-25 00:00", "2025-03-31 00:00"},
+                // One field restricted: the other must match too
+                {"0 0 * 2 1", "2025-02-25 00:00", "2026-02-02 00:00"},
+                // Aliases and intervals aligned to the epoch
+                {"@daily", "2025-03-01 00:00", "2025-03-02 00:00"},
+                {"@monthly", "2025-03-15 00:00", "2025-04-01 00:00"},
+                {"@every 5m", "2025-03-01 10:02", "2025-03-01 10:05"},
+                {"@every 5m", "2025-03-01 10:05", "2025-03-01 10:10"},
+                {"@every 1h", "2025-03-01 23:30", "2025-03-02 00:00"},
+        }
+        for _, tt := range tests {
+                t.Run(tt.spec+" after "+tt.from, func(t *testing.T) {
+                        schedule, err := parseCronSchedule(tt.spec)
+                        if err != nil {
+                                t.Fatalf("parseCronSchedule: %v", err)
+                        }
+                        if got := schedule.Next(date(tt.from)); !got.Equal(date(tt.want)) {
+                                t.Errorf("Next = %s, want %s", got.Format("2006-01-02 15:04 Mon"), tt.want)
+                        }
+                })
+        }
+}
+
+func TestCronScheduleNextInUTC(t *testing.T) {
+        schedule, err := parseCronSchedule("0 0 * * *")
+        if err != nil {
+                t.Fatal(err)
+        }
+        from := time.Date(2025, 3, 1, 23, 30, 0, 0, time.FixedZone("UTC-5", -5*3600))
+        if got, want := schedule.Next(from), time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+                t.Errorf("Next = %s, want %s", got, want)
+        }
+}
+
+func TestCronScheduleNextNeverMatches(t *testing.T) {
+        schedule, err := parseCronSchedule("0 0 31 2 *")
+        if err != nil {
+                t.Fatal(err)
+        }
+        if got := schedule.Next(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)); !got.IsZero() {
+                t.Errorf("Next = %s, want the zero time", got)
+        }
+}
@@ -0,0 +1,408 @@
+package main
+
+import (
+        "context"
+        "crypto/rand"
+        "fmt"
+        "math/big"
+        "net/http"
+        "strconv"
+        "strings"
+        "time"
+
+        "github.com/go-chi/chi/v5"
+        "github.com/jackc/pgx/v4"
+)
+
+// depositAddressNetworks lists the networks admins can set a deposit
+// address for, in display order
+var depositAddressNetworks = []string{"BSC", "ETH", "TRC20", "APTOS", "BTC"}
+
+// legacyDepositAddressSettings maps networks to the settings keys the
+// TypeScript server reads its USDT and BTC deposit addresses from. Go keeps
+// them in step with deposit_addresses and falls back to them for networks
+// that have never been rotated.
+var legacyDepositAddressSettings = map[string]string{
+        "TRC20": "USDT_DEPOSIT_ADDRESS",
+        "BTC":   "BTC_DEPOSIT_ADDRESS",
+}
+
+// depositMemoAttempts bounds retries when a generated memo is taken
+const depositMemoAttempts = 5
+
+// Senders may keep using an address they saved after it is rotated out, so
+// deposits to a retired address are still matched for a grace period
+const (
+        settingRetiredAddressGrace     = "retiredDepositAddressGraceDays"
+        defaultRetiredAddressGraceDays = 30
+)
+
+// DepositAddress represents the deposit_addresses table. The active address
+// of a network is the one not yet retired.
+type DepositAddress struct {
+        ID           string     `json:"id" db:"id"`
+        Network      string     `json:"network" db:"network"`
+        Currency     string     `json:"currency" db:"currency"`
+        Address      string     `json:"address" db:"address"`
+        MemoRequired bool       `json:"memoRequired" db:"memo_required"`
+        Reason       *string    `json:"reason" db:"reason"`
+        CreatedBy    *string    `json:"createdBy" db:"created_by"`
+        CreatedAt    time.Time  `json:"createdAt" db:"created_at"`
+        RetiredBy    *string    `json:"retiredBy" db:"retired_by"`
+        RetiredAt    *time.Time `json:"retiredAt" db:"retired_at"`
+}
+
+type DepositAddressRequest struct {
+        Network      string `json:"network" validate:"required,network"`
+        Address      string `json:"address" validate:"required,max=128"`
+        MemoRequired bool   `json:"memoRequired"`
+        Reason       string `json:"reason" validate:"max=500"`
+}
+
+const depositAddressColumns = `id, network, currency, address, memo_required, reason, created_by, created_at,
+        retired_by, retired_at`
+
+func scanDepositAddress(row pgx.Row) (*DepositAddress, error) {
+        var a DepositAddress
+        err := row.Scan(&a.ID, &a.Network, &a.Currency, &a.Address, &a.MemoRequired, &a.Reason, &a.CreatedBy, &a.CreatedAt,
+                &a.RetiredBy, &a.RetiredAt)
+        if err != nil {
+                return nil, err
+        }
+        return &a, nil
+}
+
+// retiredDepositAddress returns the most recently retired address of network
+// equal to address if it was retired within the grace period, or nil
+func retiredDepositAddress(ctx context.Context, q rowQuerier, network, address string) (*DepositAddress, error) {
+        graceDays, err := getIntSetting(ctx, q, settingRetiredAddressGrace, defaultRetiredAddressGraceDays)
+        if err != nil {
+                return nil, err
+        }
+        a, err := scanDepositAddress(q.QueryRow(ctx, `
+                SELECT `+depositAddressColumns+`
+                FROM deposit_addresses
+                WHERE network = $1 AND lower(address) = lower($2) AND retired_at > $3
+                ORDER BY retired_at DESC
+                LIMIT 1`,
+                network, address, clock.Now().AddDate(0, 0, -int(graceDays))))
+        if err == pgx.ErrNoRows {
+                return nil, nil
+        }
+        if err != nil {
+                return nil, fmt.Errorf("failed to look up retired deposit address: %w", err)
+        }
+        return a, nil
+}
+
+// isDepositAddressNetwork reports whether admins can set an address for network
+func isDepositAddressNetwork(network string) bool {
+        for _, n := range depositAddressNetworks {
+                if n == network {
+                        return true
+                }
+        }
+        return false
+}
+
+// activeDepositAddresses returns the current address of every configured
+// network, keyed by network
+func activeDepositAddresses(ctx context.Context) (map[string]*DepositAddress, error) {
+        rows, err := db.Query(ctx, "SELECT "+depositAddressColumns+" FROM deposit_addresses WHERE retired_at IS NULL")
+        if err != nil {
+                return nil, fmt.Errorf("failed to query deposit addresses: %w", err)
+        }
+        defer rows.Close()
+
+        active := make(map[string]*DepositAddress)
+        for rows.Next() {
+                a, err := scanDepositAddress(rows)
+                if err != nil {
+                        return nil, fmt.Errorf("failed to scan deposit address: %w", err)
+                }
+                active[a.Network] = a
+        }
+        if err := rows.Err(); err != nil {
+                return nil, err
+        }
+
+        for network, key := range legacyDepositAddressSettings {
+                if active[network] != nil {
+                        continue
+                }
+                value, ok, err := getSystemSetting(ctx, db, key)
+                if err != nil {
+                        return nil, err
+                }
+                if ok && strings.TrimSpace(value) != "" {
+                        active[network] = &DepositAddress{Network: network, Currency: string(networkCurrencies[network]),
+                                Address: strings.TrimSpace(value)}
+                }
+        }
+        return active, nil
+}
+
+// rotateDepositAddress makes address the active deposit address of network,
+// retiring the previous one
+func rotateDepositAddress(ctx context.Context, adminID string, req *DepositAddressRequest) (*DepositAddress, error) {
+        network := strings.ToUpper(req.Network)
+        address := strings.TrimSpace(req.Address)
+        fieldError := func(field, msg string) error {
+                return &APIError{Code: ErrCodeValidation, Message: "Validation failed", Fields: []FieldError{{Field: field, Message: msg}}}
+        }
+        if !isDepositAddressNetwork(network) {
+                return nil, fieldError("network", "must be one of: "+strings.Join(depositAddressNetworks, ", "))
+        }
+        if msg := validateAddress(network, address); msg != "" {
+                return nil, fieldError("address", msg)
+        }
+        var reason *string
+        if r := strings.TrimSpace(req.Reason); r != "" {
+                reason = &r
+        }
+
+        tx, err := db.Begin(ctx)
+        if err != nil {
+                return nil, fmt.Errorf("failed to begin transaction: %w", err)
+        }
+        defer tx.Rollback(ctx)
+
+        now := clock.Now()
+        current, err := scanDepositAddress(tx.QueryRow(ctx, "SELECT "+depositAddressColumns+`
+                FROM deposit_addresses WHERE network = $1 AND retired_at IS NULL FOR UPDATE`, network))
+        if err != nil && err != pgx.ErrNoRows {
+                return nil, fmt.Errorf("failed to lock deposit address: %w", err)
+        }
+        if current != nil {
+                if current.Address == address && current.MemoRequired == req.MemoRequired {
+                        return nil, newAPIError(ErrCodeInvalidState, "This is already the active "+network+" deposit address")
+                }
+                _, err = tx.Exec(ctx, "UPDATE deposit_addresses SET retired_at = $2, retired_by = $3 WHERE id = $1",
+                        current.ID, now, adminID)
+                if err != nil {
+                        return nil, fmt.Errorf("failed to retire deposit address: %w", err)
+                }
+        }
+
+        created, err := scanDepositAddress(tx.QueryRow(ctx, `
+                INSERT INTO deposit_addresses (network, currency, address, memo_required, reason, created_by, created_at)
+                VALUES ($1, $2, $3, $4, $5, $6, $7)
+                ON CONFLICT (network) WHERE retired_at IS NULL DO NOTHING
+                RETURNING `+depositAddressColumns,
+                network, string(networkCurrencies[network]), address, req.MemoRequired, reason, adminID, now))
+        if err == pgx.ErrNoRows {
+                return nil, newAPIError(ErrCodeConflict, "The "+network+" deposit address was changed concurrently")
+        }
+        if err != nil {
+                return nil, fmt.Errorf("failed to record deposit address: %w", err)
+        }
+
+        if key, ok := legacyDepositAddressSettings[network]; ok {
+                if err := setSystemSetting(ctx, tx, key, address); err != nil {
+                        return nil, err
+                }
+        }
+
+        if err := tx.Commit(ctx); err != nil {
+                return nil, fmt.Errorf("failed to commit deposit address: %w", err)
+        }
+        return created, nil
+}
+
+// listDepositAddressHistory returns the addresses a network has used,
+// newest first
+func listDepositAddressHistory(ctx context.Context, network string, limit int) ([]*DepositAddress, error) {
+        rows, err := db.Query(ctx, "SELECT "+depositAddressColumns+`
+                FROM deposit_addresses WHERE network = $1
+                ORDER BY created_at DESC, id
+                LIMIT $2`, network, limit)
+        if err != nil {
+                return nil, fmt.Errorf("failed to query deposit address history: %w", err)
+        }
+        defer rows.Close()
+
+        history := []*DepositAddress{}
+        for rows.Next() {
+                a, err := scanDepositAddress(rows)
+                if err != nil {
+                        return nil, fmt.Errorf("failed to scan deposit address: %w", err)
+                }
+                history = append(history, a)
+        }
+        return history, rows.Err()
+}
+
+// generateDepositMemo returns a random 9 digit deposit memo
+func generateDepositMemo() (string, error) {
+        n, err := rand.Int(rand.Reader, big.NewInt(900000000))
+        if err != nil {
+                return "", fmt.Errorf("failed to generate deposit memo: %w", err)
+        }
+        return strconv.FormatInt(n.Int64()+100000000, 10), nil
+}
+
+// getDepositMemo returns the user's deposit memo, assigning one on first
+// use. Deposits to a network that requires a memo are matched to the user
+// by it.
+func getDepositMemo(ctx context.Context, userID string) (string, error) {
+        for attempt := 0; attempt < depositMemoAttempts; attempt++ {
+                var memo string
+                err := db.QueryRow(ctx, "SELECT memo FROM deposit_memos WHERE user_id = $1", userID).Scan(&memo)
+                if err == nil {
+                        return memo, nil
+                }
+                if err != pgx.ErrNoRows {
+                        return "", fmt.Errorf("failed to get deposit memo: %w", err)
+                }
+
+                if memo, err = generateDepositMemo(); err != nil {
+                        return "", err
+                }
+                // Either a concurrent request assigned the user a memo or the memo
+                // is taken; both are resolved by trying again
+                err = db.QueryRow(ctx, `
+                        INSERT INTO deposit_memos (user_id, memo, created_at) VALUES ($1, $2, $3)
+                        ON CONFLICT DO NOTHING
+                        RETURNING memo`, userID, memo, clock.Now()).Scan(&memo)
+                if err == nil {
+                        return memo, nil
+                }
+                if err != pgx.ErrNoRows {
+                        return "", fmt.Errorf("failed to assign deposit memo: %w", err)
+                }
+        }
+        return "", fmt.Errorf("failed to assign a unique deposit memo after %d attempts", depositMemoAttempts)
+}
+
+// Deposit addresses endpoint. Lists the address of every configured
+// network: the user's own derived address where an xpub is configured,
+// otherwise the shared address with the user's memo where one is required.
+// usdt and btc keep the TypeScript server's response shape.
+func handleGetDepositAddresses(w http.ResponseWriter, r *http.Request) {
+        user := getUserFromContext(r.Context())
+        if user == nil {
+                writeErrorResponse(w, r, ErrCodeUnauthorized, "Unauthorized")
+                return
+        }
+
+        active, err := activeDepositAddresses(r.Context())
+        if err != nil {
+                writeErrorResponse(w, r, ErrCodeInternal, "Failed to load deposit addresses")
+                return
+        }
+
+        derived, err := userDepositAddresses(r.Context(), user.ID)
+        if err != nil {
+                writeErrorResponse(w, r, ErrCodeInternal, "Failed to load deposit addresses")
+                return
+        }
+
+        var memo string
+        byNetwork := make(map[string]string)
+        addresses := []map[string]interface{}{}
+        for _, network := range depositAddressNetworks {
+                entry := map[string]interface{}{
+                        "network":  network,
+                        "currency": networkCurrencies[network],
+                }
+                if d := derived[hdDepositChains[network]]; d != nil {
+                        entry["address"] = d.Address
+                        entry["personal"] = true
+                        byNetwork[network] = d.Address
+                } else if a := active[network]; a != nil {
+                        entry["address"] = a.Address
+                        entry["personal"] = false
+                        byNetwork[network] = a.Address
+                        if a.MemoRequired {
+                                if memo == "" {
+                                        if memo, err = getDepositMemo(r.Context(), user.ID); err != nil {
+                                                writeErrorResponse(w, r, ErrCodeInternal, "Failed to load deposit memo")
+                                                return
+                                        }
+                                }
+                                entry["memo"] = memo
+                        }
+                } else {
+                        continue
+                }
+                addresses = append(addresses, entry)
+        }
+
+        legacy := func(network string) *string {
+                if address, ok := byNetwork[network]; ok {
+                        return &address
+                }
+                return nil
+        }
+        writeJSONResponse(w, http.StatusOK, map[string]interface{}{
+                "addresses": addresses,
+                "usdt":      legacy("TRC20"),
+                "btc":       legacy("BTC"),
+        })
+}
+
+// Admin deposit addresses endpoint
+func handleAdminDepositAddresses(w http.ResponseWriter, r *http.Request) {
+        active, err := activeDepositAddresses(r.Context())
+        if err != nil {
+                writeErrorResponse(w, r, ErrCodeInternal, "Failed to load deposit addresses")
+                return
+        }
+
+        addresses := make([]map[string]interface{}, 0, len(depositAddressNetworks))
+        for _, network := range depositAddressNetworks {
+                addresses = append(addresses, map[string]interface{}{
+                        "network":  network,
+                        "currency": networkCurrencies[network],
+                        "active":   active[network],
+                })
+        }
+
+        writeJSONResponse(w, http.StatusOK, map[string]interface{}{"addresses": addresses})
+}
+
+// Admin rotate deposit address endpoint
+func handleAdminRotateDepositAddress(w http.ResponseWriter, r *http.Request) {
+        admin := getUserFromContext(r.Context())
+
+        var req DepositAddressRequest
+        if err := decodeAndValidate(w, r, &req); err != nil {
+                writeAPIError(w, r, err)
+                return
+        }
+
+        address, err := rotateDepositAddress(r.Context(), admin.ID, &req)
+        if err != nil {
+                writeAPIError(w, r, err)
+                return
+        }
+
+        writeJSONResponse(w, http.StatusOK, map[string]interface{}{"address": address})
+}
+
+// Admin deposit address history endpoint
+func handleAdminDepositAddressHistory(w http.ResponseWriter, r *http.Request) {
+        network := strings.ToUpper(chi.URLParam(r, "network"))
+        if !isDepositAddressNetwork(network) {
+                writeErrorResponse(w, r, ErrCodeNotFound, "Network not found")
+                return
+        }
+
+        limit := 50
+        if v := r.URL.Query().Get("limit"); v != "" {
+                n, err := strconv.Atoi(v)
+                if err != nil || n < 1 || n > 500 {
+                        writeErrorResponse(w, r, ErrCodeValidation, "limit must be between 1 and 500")
+                        return
+                }
+                limit = n
+        }
+
+        history, err := listDepositAddressHistory(r.Context(), network, limit)
+        if err != nil {
+                writeErrorResponse(w, r, ErrCodeInternal, "Failed to load deposit address history")
+                return
+        }
+
+        writeJSONResponse(w, http.StatusOK, map[string]interface{}{"history": history})
+}
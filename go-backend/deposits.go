@@ -0,0 +1,487 @@
+package main
+
+import (
+        "context"
+        "fmt"
+        "net/http"
+        "strings"
+        "time"
+
+        "github.com/jackc/pgx/v4"
+        "github.com/shopspring/decimal"
+)
+
+// networkCurrencies maps each network to the currency moved over it
+var networkCurrencies = map[string]Currency{
+        "BSC":   CurrencyUSDT,
+        "ETH":   CurrencyUSDT,
+        "ERC20": CurrencyUSDT,
+        "TRC20": CurrencyUSDT,
+        "APTOS": CurrencyUSDT,
+        "BTC":   CurrencyBTC,
+        "GBTC":  CurrencyGBTC,
+}
+
+// Withdrawal represents the withdrawals table
+type Withdrawal struct {
+        ID        string          `json:"id" db:"id"`
+        UserID    string          `json:"userId" db:"user_id"`
+        Amount    decimal.Decimal `json:"amount" db:"amount"`
+        Address   string          `json:"address" db:"address"`
+        Network   string          `json:"network" db:"network"`
+        Currency  string          `json:"currency" db:"currency"`
+        Status    string          `json:"status" db:"status"`
+        TxHash    *string         `json:"txHash" db:"tx_hash"`
+        CreatedAt time.Time       `json:"createdAt" db:"created_at"`
+}
+
+type DepositRequest struct {
+        Network string `json:"network" validate:"required,network"`
+        TxHash  string `json:"txHash" validate:"required,txhash"`
+        Amount  string `json:"amount" validate:"required,decimal=8"`
+}
+
+// DetectedDepositRequest reports an incoming transfer the deposit watcher
+// saw on chain. Memo is the tag sent with transfers to a shared address.
+type DetectedDepositRequest struct {
+        Network string `json:"network" validate:"required,network"`
+        TxHash  string `json:"txHash" validate:"required,txhash"`
+        Address string `json:"address" validate:"required,max=128"`
+        Memo    string `json:"memo" validate:"max=64"`
+        Amount  string `json:"amount" validate:"required,decimal=8"`
+}
+
+type WithdrawalRequest struct {
+        Network string `json:"network" validate:"required,network"`
+        Address string `json:"address" validate:"required,max=128"`
+        Amount  string `json:"amount" validate:"required,decimal=8"`
+}
+
+// depositCurrency returns the currency deposited over network
+func depositCurrency(network string) (Currency, error) {
+        network = strings.ToUpper(network)
+        currency, ok := networkCurrencies[network]
+        if !ok || currency == CurrencyGBTC {
+                return "", newAPIError(ErrCodeValidation, "Deposits are not supported on "+network)
+        }
+        return currency, nil
+}
+
+// withdrawalCurrency returns the currency withdrawn over network
+func withdrawalCurrency(network string) (Currency, error) {
+        network = strings.ToUpper(network)
+        currency, ok := networkCurrencies[network]
+        if !ok {
+                return "", newAPIError(ErrCodeValidation, "Withdrawals are not supported on "+network)
+        }
+        return currency, nil
+}
+
+// lockUserForFunding locks the user's row and returns their KYC tier
+func lockUserForFunding(ctx context.Context, tx pgx.Tx, userID string) (int, error) {
+        var tier int
+        var kycVerified bool
+        err := tx.QueryRow(ctx, "SELECT kyc_tier, kyc_verified FROM users WHERE id = $1 FOR UPDATE", userID).
+                Scan(&tier, &kycVerified)
+        if err == pgx.ErrNoRows {
+                return 0, newAPIError(ErrCodeNotFound, "User not found")
+        }
+        if err != nil {
+                return 0, fmt.Errorf("failed to lock user: %w", err)
+        }
+        return effectiveKYCTier(tier, kycVerified), nil
+}
+
+// createDeposit records a pending deposit after checking the user's deposit
+// cooldown and caps
+func createDeposit(ctx context.Context, userID, network, txHash string, amount decimal.Decimal) (*Deposit, error) {
+        network = strings.ToUpper(network)
+        currency, err := depositCurrency(network)
+        if err != nil {
+                return nil, err
+        }
+
+        tx, err := db.Begin(ctx)
+        if err != nil {
+                return nil, fmt.Errorf("failed to begin transaction: %w", err)
+        }
+        defer tx.Rollback(ctx)
+
+        tier, err := lockUserForFunding(ctx, tx, userID)
+        if err != nil {
+                return nil, err
+        }
+
+        now := clock.Now()
+        if err := checkFundingAllowed(ctx, tx, fundingDeposit, userID, tier, currency, amount, now); err != nil {
+                return nil, err
+        }
+
+        deposit := Deposit{UserID: userID, Network: network, TxHash: txHash, Amount: amount, Currency: string(currency),
+                Status: "pending", CreatedAt: now, UpdatedAt: now}
+        if err := insertDeposit(ctx, tx, &deposit); err != nil {
+                return nil, err
+        }
+
+        if err := tx.Commit(ctx); err != nil {
+                return nil, fmt.Errorf("failed to commit deposit: %w", err)
+        }
+        return &deposit, nil
+}
+
+// insertDeposit records a pending deposit and announces it to the user
+func insertDeposit(ctx context.Context, tx pgx.Tx, deposit *Deposit) error {
+        err := tx.QueryRow(ctx, `
+                INSERT INTO deposits (user_id, network, tx_hash, amount, currency, address, status, created_at, updated_at)
+                VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $8)
+                ON CONFLICT (tx_hash) DO NOTHING
+                RETURNING id`,
+                deposit.UserID, deposit.Network, deposit.TxHash, deposit.Amount.String(), deposit.Currency, deposit.Address,
+                deposit.Status, deposit.CreatedAt,
+        ).Scan(&deposit.ID)
+        if err == pgx.ErrNoRows {
+                return newAPIError(ErrCodeConflict, "This transaction hash has already been submitted")
+        }
+        if err != nil {
+                return fmt.Errorf("failed to record deposit: %w", err)
+        }
+        return publishEvent(ctx, tx, eventDeposit, deposit.UserID, depositResponse(deposit))
+}
+
+// findDepositOwner matches an incoming transfer to a user, by the derived
+// address it was sent to or, for a shared address that requires one, by
+// its memo. Shared addresses retired within the grace period still match.
+func findDepositOwner(ctx context.Context, q rowQuerier, network, address, memo string) (string, error) {
+        userID, ok, err := findDerivedAddressOwner(ctx, q, network, address)
+        if err != nil || ok {
+                return userID, err
+        }
+
+        active, err := activeDepositAddresses(ctx)
+        if err != nil {
+                return "", err
+        }
+        shared := active[network]
+        if shared == nil || !strings.EqualFold(shared.Address, address) {
+                if shared, err = retiredDepositAddress(ctx, q, network, address); err != nil {
+                        return "", err
+                }
+        }
+        if shared == nil {
+                return "", newAPIError(ErrCodeNotFound, "No user owns this deposit address")
+        }
+        if !shared.MemoRequired || memo == "" {
+                return "", newAPIError(ErrCodeInvalidState, "Deposits to the shared "+network+" address must be matched manually")
+        }
+
+        err = q.QueryRow(ctx, "SELECT user_id FROM deposit_memos WHERE memo = $1", memo).Scan(&userID)
+        if err == pgx.ErrNoRows {
+                return "", newAPIError(ErrCodeNotFound, "No user owns this deposit memo")
+        }
+        if err != nil {
+                return "", fmt.Errorf("failed to look up deposit memo: %w", err)
+        }
+        return userID, nil
+}
+
+// recordDetectedDeposit records a transfer seen on chain as a pending
+// deposit of the user it was sent to. The funds have already arrived, so
+// cooldowns and caps do not apply; an admin still approves the deposit.
+func recordDetectedDeposit(ctx context.Context, req *DetectedDepositRequest, amount decimal.Decimal) (*Deposit, error) {
+        network := strings.ToUpper(req.Network)
+        currency, err := depositCurrency(network)
+        if err != nil {
+                return nil, err
+        }
+        address := strings.TrimSpace(req.Address)
+
+        tx, err := db.Begin(ctx)
+        if err != nil {
+                return nil, fmt.Errorf("failed to begin transaction: %w", err)
+        }
+        defer tx.Rollback(ctx)
+
+        userID, err := findDepositOwner(ctx, tx, network, address, strings.TrimSpace(req.Memo))
+        if err != nil {
+                return nil, err
+        }
+
+        now := clock.Now()
+        deposit := Deposit{UserID: userID, Network: network, TxHash: req.TxHash, Amount: amount, Currency: string(currency),
+                Address: &address, Status: "pending", CreatedAt: now, UpdatedAt: now}
+        if err := insertDeposit(ctx, tx, &deposit); err != nil {
+                return nil, err
+        }
+
+        if err := tx.Commit(ctx); err != nil {
+                return nil, fmt.Errorf("failed to commit deposit: %w", err)
+        }
+        return &deposit, nil
+}
+
+// createWithdrawal records a pending withdrawal after checking the user's
+// withdrawal cooldown and caps. Balances are debited when an admin approves
+// it, so the amount must fit within the balance not held by other pending
+// withdrawals.
+func createWithdrawal(ctx context.Context, userID, network, address string, amount decimal.Decimal) (*Withdrawal, error) {
+        network = strings.ToUpper(network)
+        currency, err := withdrawalCurrency(network)
+        if err != nil {
+                return nil, err
+        }
+
+        tx, err := db.Begin(ctx)
+        if err != nil {
+                return nil, fmt.Errorf("failed to begin transaction: %w", err)
+        }
+        defer tx.Rollback(ctx)
+
+        tier, err := lockUserForFunding(ctx, tx, userID)
+        if err != nil {
+                return nil, err
+        }
+
+        column, err := balanceColumn(currency)
+        if err != nil {
+                return nil, err
+        }
+        var balance, pending decimal.Decimal
+        err = tx.QueryRow(ctx, fmt.Sprintf(`
+                SELECT u.%s,
+                       (SELECT COALESCE(SUM(amount), 0) FROM withdrawals
+                        WHERE user_id = u.id AND currency = $2 AND status = 'pending')
+                FROM users u WHERE u.id = $1`, column),
+                userID, string(currency)).Scan(&balance, &pending)
+        if err != nil {
+                return nil, fmt.Errorf("failed to get balance: %w", err)
+        }
+        if balance.Sub(pending).LessThan(amount) {
+                return nil, newAPIError(ErrCodeInsufficientFunds, fmt.Sprintf("Insufficient %s balance", currency))
+        }
+
+        now := clock.Now()
+        if err := checkFundingAllowed(ctx, tx, fundingWithdrawal, userID, tier, currency, amount, now); err != nil {
+                return nil, err
+        }
+
+        withdrawal := Withdrawal{UserID: userID, Amount: amount, Address: address, Network: network,
+                Currency: string(currency), Status: "pending", CreatedAt: now}
+        err = tx.QueryRow(ctx, `
+                INSERT INTO withdrawals (user_id, amount, address, network, currency, status, created_at)
+                VALUES ($1, $2, $3, $4, $5, $6, $7)
+                RETURNING id`,
+                userID, amount.String(), address, network, withdrawal.Currency, withdrawal.Status, now,
+        ).Scan(&withdrawal.ID)
+        if err != nil {
+                return nil, fmt.Errorf("failed to record withdrawal: %w", err)
+        }
+        if err := publishEvent(ctx, tx, eventWithdrawal, userID, withdrawalResponse(&withdrawal)); err != nil {
+                return nil, err
+        }
+
+        if err := tx.Commit(ctx); err != nil {
+                return nil, fmt.Errorf("failed to commit withdrawal: %w", err)
+        }
+        return &withdrawal, nil
+}
+
+func depositResponse(d *Deposit) map[string]interface{} {
+        return map[string]interface{}{
+                "id":        d.ID,
+                "network":   d.Network,
+                "txHash":    d.TxHash,
+                "amount":    formatAmount(d.Amount, Currency(d.Currency)),
+                "currency":  d.Currency,
+                "address":   d.Address,
+                "status":    d.Status,
+                "adminNote": d.AdminNote,
+                "createdAt": d.CreatedAt,
+                "updatedAt": d.UpdatedAt,
+        }
+}
+
+func withdrawalResponse(wd *Withdrawal) map[string]interface{} {
+        return map[string]interface{}{
+                "id":        wd.ID,
+                "network":   wd.Network,
+                "address":   wd.Address,
+                "amount":    formatAmount(wd.Amount, Currency(wd.Currency)),
+                "currency":  wd.Currency,
+                "status":    wd.Status,
+                "txHash":    wd.TxHash,
+                "createdAt": wd.CreatedAt,
+        }
+}
+
+// Create deposit endpoint
+func handleCreateDeposit(w http.ResponseWriter, r *http.Request) {
+        user := getUserFromContext(r.Context())
+        if user == nil {
+                writeErrorResponse(w, r, ErrCodeUnauthorized, "Unauthorized")
+                return
+        }
+
+        var req DepositRequest
+        if err := decodeAndValidate(w, r, &req); err != nil {
+                writeAPIError(w, r, err)
+                return
+        }
+
+        currency, err := depositCurrency(req.Network)
+        if err != nil {
+                writeAPIError(w, r, err)
+                return
+        }
+        amount, err := parseAmount(req.Amount, currency)
+        if err != nil {
+                writeAPIError(w, r, err)
+                return
+        }
+
+        deposit, err := createDeposit(r.Context(), user.ID, req.Network, req.TxHash, amount)
+        if err != nil {
+                writeAPIError(w, r, err)
+                return
+        }
+
+        writeJSONResponse(w, http.StatusCreated, depositResponse(deposit))
+}
+
+// Admin detected deposit endpoint. Called by the deposit watcher for each
+// incoming transfer to a platform address.
+func handleAdminDetectedDeposit(w http.ResponseWriter, r *http.Request) {
+        var req DetectedDepositRequest
+        if err := decodeAndValidate(w, r, &req); err != nil {
+                writeAPIError(w, r, err)
+                return
+        }
+
+        currency, err := depositCurrency(req.Network)
+        if err != nil {
+                writeAPIError(w, r, err)
+                return
+        }
+        amount, err := parseAmount(req.Amount, currency)
+        if err != nil {
+                writeAPIError(w, r, err)
+                return
+        }
+
+        deposit, err := recordDetectedDeposit(r.Context(), &req, amount)
+        if err != nil {
+                writeAPIError(w, r, err)
+                return
+        }
+
+        writeJSONResponse(w, http.StatusCreated, depositResponse(deposit))
+}
+
+// Deposit history endpoint
+func handleGetDeposits(w http.ResponseWriter, r *http.Request) {
+        user := getUserFromContext(r.Context())
+        if user == nil {
+                writeErrorResponse(w, r, ErrCodeUnauthorized, "Unauthorized")
+                return
+        }
+
+        rows, err := db.Query(r.Context(), `
+                SELECT id, user_id, network, tx_hash, amount, currency, address, status, admin_note, created_at, updated_at
+                FROM deposits
+                WHERE user_id = $1
+                ORDER BY created_at DESC
+                LIMIT 100`, user.ID)
+        if err != nil {
+                writeErrorResponse(w, r, ErrCodeInternal, "Failed to load deposits")
+                return
+        }
+        defer rows.Close()
+
+        deposits := make([]map[string]interface{}, 0)
+        for rows.Next() {
+                var d Deposit
+                if err := rows.Scan(&d.ID, &d.UserID, &d.Network, &d.TxHash, &d.Amount, &d.Currency, &d.Address, &d.Status,
+                        &d.AdminNote, &d.CreatedAt, &d.UpdatedAt); err != nil {
+                        writeErrorResponse(w, r, ErrCodeInternal, "Failed to load deposits")
+                        return
+                }
+                deposits = append(deposits, depositResponse(&d))
+        }
+        if rows.Err() != nil {
+                writeErrorResponse(w, r, ErrCodeInternal, "Failed to load deposits")
+                return
+        }
+
+        writeJSONResponse(w, http.StatusOK, map[string]interface{}{"deposits": deposits})
+}
+
+// Create withdrawal endpoint
+func handleCreateWithdrawal(w http.ResponseWriter, r *http.Request) {
+        user := getUserFromContext(r.Context())
+        if user == nil {
+                writeErrorResponse(w, r, ErrCodeUnauthorized, "Unauthorized")
+                return
+        }
+
+        var req WithdrawalRequest
+        if err := decodeAndValidate(w, r, &req); err != nil {
+                writeAPIError(w, r, err)
+                return
+        }
+
+        currency, err := withdrawalCurrency(req.Network)
+        if err != nil {
+                writeAPIError(w, r, err)
+                return
+        }
+        amount, err := parseAmount(req.Amount, currency)
+        if err != nil {
+                writeAPIError(w, r, err)
+                return
+        }
+
+        withdrawal, err := createWithdrawal(r.Context(), user.ID, req.Network, strings.TrimSpace(req.Address), amount)
+        if err != nil {
+                writeAPIError(w, r, err)
+                return
+        }
+
+        writeJSONResponse(w, http.StatusCreated, withdrawalResponse(withdrawal))
+}
+
+// Withdrawal history endpoint
+func handleGetWithdrawals(w http.ResponseWriter, r *http.Request) {
+        user := getUserFromContext(r.Context())
+        if user == nil {
+                writeErrorResponse(w, r, ErrCodeUnauthorized, "Unauthorized")
+                return
+        }
+
+        rows, err := db.Query(r.Context(), `
+                SELECT id, user_id, amount, address, network, currency, status, tx_hash, created_at
+                FROM withdrawals
+                WHERE user_id = $1
+                ORDER BY created_at DESC
+                LIMIT 100`, user.ID)
+        if err != nil {
+                writeErrorResponse(w, r, ErrCodeInternal, "Failed to load withdrawals")
+                return
+        }
+        defer rows.Close()
+
+        withdrawals := make([]map[string]interface{}, 0)
+        for rows.Next() {
+                var wd Withdrawal
+                if err := rows.Scan(&wd.ID, &wd.UserID, &wd.Amount, &wd.Address, &wd.Network, &wd.Currency, &wd.Status,
+                        &wd.TxHash, &wd.CreatedAt); err != nil {
+                        writeErrorResponse(w, r, ErrCodeInternal, "Failed to load withdrawals")
+                        return
+                }
+                withdrawals = append(withdrawals, withdrawalResponse(&wd))
+        }
+        if rows.Err() != nil {
+                writeErrorResponse(w, r, ErrCodeInternal, "Failed to load withdrawals")
+                return
+        }
+
+        writeJSONResponse(w, http.StatusOK, map[string]interface{}{"withdrawals": withdrawals})
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+        "context"
+        "fmt"
+        "net/http"
+        "strconv"
+        "time"
+
+        "github.com/jackc/pgx/v4"
+        "github.com/shopspring/decimal"
+)
+
+// Settings keys for difficulty retargeting
+const (
+        settingDifficultyInterval      = "difficultyAdjustmentInterval"
+        settingDifficultyBaseHashPower = "difficultyBaseHashPower"
+)
+
+const (
+        // defaultDifficultyInterval retargets once a day at 10 minute blocks
+        defaultDifficultyInterval = blocksPerDay
+
+        // maxDifficultyAdjustment bounds a single retarget to a 4x change either
+        // way, like Bitcoin
+        maxDifficultyAdjustment = 4
+
+        // difficultyScale matches mining_stats.current_difficulty numeric(10, 2)
+        difficultyScale = 2
+)
+
+var (
+        // defaultDifficultyBaseHashPower is the network hash power at difficulty 1
+        defaultDifficultyBaseHashPower = decimal.NewFromInt(1000)
+
+        minDifficulty = decimal.New(1, -difficultyScale)
+        maxDifficulty = decimal.RequireFromString("99999999.99")
+)
+
+// DifficultyAdjustment represents the difficulty_adjustments table. Each row
+// is one retarget; TotalHashPower is the average over the window it covers.
+type DifficultyAdjustment struct {
+        ID                 string          `json:"id" db:"id"`
+        Height             int64           `json:"height" db:"height"`
+        PreviousDifficulty decimal.Decimal `json:"previousDifficulty" db:"previous_difficulty"`
+        Difficulty         decimal.Decimal `json:"difficulty" db:"difficulty"`
+        TotalHashPower     decimal.Decimal `json:"totalHashPower" db:"total_hash_power"`
+        AvgBlockTime       int             `json:"avgBlockTime" db:"avg_block_time"`
+        RewardPerHash      decimal.Decimal `json:"rewardPerHash" db:"reward_per_hash"`
+        AdjustedAt         time.Time       `json:"adjustedAt" db:"adjusted_at"`
+}
+
+// nextDifficulty scales the previous difficulty by the growth in hash power
+// since the last retarget, bounded by maxDifficultyAdjustment
+func nextDifficulty(previous, previousHashPower, hashPower decimal.Decimal) decimal.Decimal {
+        if !previousHashPower.IsPositive() {
+                return previous
+        }
+        factor := hashPower.Div(previousHashPower)
+        factor = decimal.Max(factor, decimal.NewFromInt(1).Div(decimal.NewFromInt(maxDifficultyAdjustment)))
+        factor = decimal.Min(factor, decimal.NewFromInt(maxDifficultyAdjustment))
+
+        next := previous.Mul(factor).Round(difficultyScale)
+        return decimal.Min(decimal.Max(next, minDifficulty), maxDifficulty)
+}
+
+// latestDifficultyAdjustment returns the most recent retarget, or nil when
+// the network has never retargeted
+func latestDifficultyAdjustment(ctx context.Context, q rowQuerier) (*DifficultyAdjustment, error) {
+        var a DifficultyAdjustment
+        err := q.QueryRow(ctx, `
+                SELECT id, height, previous_difficulty, difficulty, total_hash_power, avg_block_time, reward_per_hash, adjusted_at
+                FROM difficulty_adjustments
+                ORDER BY height DESC
+                LIMIT 1`).Scan(&a.ID, &a.Height, &a.PreviousDifficulty, &a.Difficulty, &a.TotalHashPower,
+                &a.AvgBlockTime, &a.RewardPerHash, &a.AdjustedAt)
+        if err == pgx.ErrNoRows {
+                return nil, nil
+        }
+        if err != nil {
+                return nil, fmt.Errorf("failed to get difficulty adjustment: %w", err)
+        }
+        return &a, nil
+}
+
+// retargetDifficulty runs at the end of every adjustment window. It sets the
+// new difficulty from the window's average hash power, measures the average
+// block time over the window and records both in the history. It returns nil
+// when height does not close a window. The difficulty times the base hash
+// power is the target hash power the next window's block rewards are divided
+// by, so rewards per unit of hash power fall as the network grows.
+func retargetDifficulty(ctx context.Context, tx pgx.Tx, height int64, at time.Time) (*DifficultyAdjustment, error) {
+        interval, err := getIntSetting(ctx, tx, settingDifficultyInterval, defaultDifficultyInterval)
+        if err != nil {
+                return nil, err
+        }
+        if interval <= 0 {
+                return nil, fmt.Errorf("setting %s must be positive", settingDifficultyInterval)
+        }
+        if height%interval != 0 {
+                return nil, nil
+        }
+
+        base, err := getDecimalSetting(ctx, tx, settingDifficultyBaseHashPower, defaultDifficultyBaseHashPower)
+        if err != nil {
+                return nil, err
+        }
+        if !base.IsPositive() {
+                return nil, fmt.Errorf("setting %s must be positive", settingDifficultyBaseHashPower)
+        }
+
+        // The window includes the block closing the previous one so that its
+        // interval counts towards the average block time
+        var windowHashPower decimal.Decimal
+        var avgBlockTime *float64
+        err = tx.QueryRow(ctx, `
+                SELECT COALESCE(AVG(total_hash_power) FILTER (WHERE height > $1 - $2), 0),
+                       EXTRACT(EPOCH FROM MAX(timestamp) - MIN(timestamp)) / NULLIF(COUNT(*) - 1, 0)
+                FROM mining_blocks
+                WHERE height BETWEEN $1 - $2 AND $1`, height, interval).Scan(&windowHashPower, &avgBlockTime)
+        if err != nil {
+                return nil, fmt.Errorf("failed to measure difficulty window: %w", err)
+        }
+
+        previous, err := latestDifficultyAdjustment(ctx, tx)
+        if err != nil {
+                return nil, err
+        }
+
+        adjustment := DifficultyAdjustment{Height: height, TotalHashPower: windowHashPower.Round(hashPowerScale), AdjustedAt: at}
+        if previous == nil {
+                // The first retarget measures the network against the base hash power
+                adjustment.PreviousDifficulty = decimal.NewFromInt(1)
+                adjustment.Difficulty = nextDifficulty(adjustment.PreviousDifficulty, base, windowHashPower)
+        } else {
+                adjustment.PreviousDifficulty = previous.Difficulty
+                adjustment.Difficulty = nextDifficulty(previous.Difficulty, previous.TotalHashPower, windowHashPower)
+        }
+
+        adjustment.AvgBlockTime = int(blockInterval / time.Second)
+        if avgBlockTime != nil {
+                adjustment.AvgBlockTime = int(*avgBlockTime + 0.5)
+        }
+
+        params, err := loadSupplyParams(ctx, tx)
+        if err != nil {
+                return nil, err
+        }
+        mined, err := totalMinedSupply(ctx, tx)
+        if err != nil {
+                return nil, err
+        }
+        // A unit of hash power earns at most the block reward over the target
+        // hash power, and less once the network outgrows it
+        adjustment.RewardPerHash = params.rewardAt(height+1, mined).
+                Div(adjustment.Difficulty.Mul(base)).Truncate(currencyScales[CurrencyGBTC])
+
+        err = tx.QueryRow(ctx, `
+                INSERT INTO difficulty_adjustments
+                        (height, previous_difficulty, difficulty, total_hash_power, avg_block_time, reward_per_hash, adjusted_at)
+                VALUES ($1, $2, $3, $4, $5, $6, $7)
+                RETURNING id`,
+                adjustment.Height, adjustment.PreviousDifficulty.String(), adjustment.Difficulty.String(),
+                adjustment.TotalHashPower.String(), adjustment.AvgBlockTime, adjustment.RewardPerHash.String(), at,
+        ).Scan(&adjustment.ID)
+        if err != nil {
+                return nil, fmt.Errorf("failed to record difficulty adjustment: %w", err)
+        }
+
+        _, err = tx.Exec(ctx, "UPDATE mining_stats SET current_difficulty = $1, avg_block_time = $2, updated_at = $3",
+                adjustment.Difficulty.String(), adjustment.AvgBlockTime, at)
+        if err != nil {
+                return nil, fmt.Errorf("failed to update difficulty: %w", err)
+        }
+        return &adjustment, nil
+}
+
+// targetHashPower returns the network hash power the current difficulty
+// prices block rewards against, or zero before the first retarget
+func targetHashPower(ctx context.Context, q rowQuerier) (decimal.Decimal, error) {
+        latest, err := latestDifficultyAdjustment(ctx, q)
+        if err != nil || latest == nil {
+                return decimal.Zero, err
+        }
+        base, err := getDecimalSetting(ctx, q, settingDifficultyBaseHashPower, defaultDifficultyBaseHashPower)
+        if err != nil {
+                return decimal.Zero, err
+        }
+        return latest.Difficulty.Mul(base), nil
+}
+
+// listDifficultyAdjustments returns the most recent retargets, oldest first
+func listDifficultyAdjustments(ctx context.Context, limit int) ([]*DifficultyAdjustment, error) {
+        rows, err := db.Query(ctx, `
+                SELECT id, height, previous_difficulty, difficulty, total_hash_power, avg_block_time, reward_per_hash, adjusted_at
+                FROM (
+                        SELECT * FROM difficulty_adjustments ORDER BY height DESC LIMIT $1
+                ) recent
+                ORDER BY height ASC`, limit)
+        if err != nil {
+                return nil, fmt.Errorf("failed to query difficulty adjustments: %w", err)
+        }
+        defer rows.Close()
+
+        adjustments := make([]*DifficultyAdjustment, 0)
+        for rows.Next() {
+                var a DifficultyAdjustment
+                if err := rows.Scan(&a.ID, &a.Height, &a.PreviousDifficulty, &a.Difficulty, &a.TotalHashPower,
+                        &a.AvgBlockTime, &a.RewardPerHash, &a.AdjustedAt); err != nil {
+                        return nil, fmt.Errorf("failed to scan difficulty adjustment: %w", err)
+                }
+                adjustments = append(adjustments, &a)
+        }
+        return adjustments, rows.Err()
+}
+
+// Difficulty endpoint with the current difficulty and retarget history
+func handleDifficulty(w http.ResponseWriter, r *http.Request) {
+        limit := 100
+        if v := r.URL.Query().Get("limit"); v != "" {
+                n, err := strconv.Atoi(v)
+                if err != nil || n < 1 || n > 1000 {
+                        writeErrorResponse(w, r, ErrCodeValidation, "limit must be between 1 and 1000")
+                        return
+                }
+                limit = n
+        }
+
+        ctx := r.Context()
+        interval, err := getIntSetting(ctx, db, settingDifficultyInterval, defaultDifficultyInterval)
+        if err != nil || interval <= 0 {
+                writeErrorResponse(w, r, ErrCodeInternal, "Failed to load difficulty")
+                return
+        }
+        height, err := getIntSetting(ctx, db, settingTotalBlockHeight, 0)
+        if err != nil {
+                writeErrorResponse(w, r, ErrCodeInternal, "Failed to load difficulty")
+                return
+        }
+
+        difficulty := decimal.NewFromInt(1)
+        avgBlockTime := int(blockInterval / time.Second)
+        var rewardPerHash *string
+        history, err := listDifficultyAdjustments(ctx, limit)
+        if err != nil {
+                writeErrorResponse(w, r, ErrCodeInternal, "Failed to load difficulty")
+                return
+        }
+        if len(history) > 0 {
+                latest := history[len(history)-1]
+                difficulty = latest.Difficulty
+                avgBlockTime = latest.AvgBlockTime
+                s := formatAmount(latest.RewardPerHash, CurrencyGBTC)
+                rewardPerHash = &s
+        }
+
+        nextAdjustment := (height/interval + 1) * interval
+        points := make([]map[string]interface{}, 0, len(history))
+        for _, a := range history {
+                points = append(points, map[string]interface{}{
+                        "height":             a.Height,
+                        "previousDifficulty": a.PreviousDifficulty.StringFixed(difficultyScale),
+                        "difficulty":         a.Difficulty.StringFixed(difficultyScale),
+                        "totalHashPower":     formatHashPower(a.TotalHashPower),
+                        "avgBlockTime":       a.AvgBlockTime,
+                        "rewardPerHash":      formatAmount(a.RewardPerHash, CurrencyGBTC),
+                        "adjustedAt":         a.AdjustedAt,
+                })
+        }
+
+        writeJSONResponse(w, http.StatusOK, map[string]interface{}{
+                "current": map[string]interface{}{
+                        "difficulty":            difficulty.StringFixed(difficultyScale),
+                        "avgBlockTime":          avgBlockTime,
+                        "rewardPerHash":         rewardPerHash,
+                        "adjustmentInterval":    interval,
+                        "nextAdjustmentHeight":  nextAdjustment,
+                        "blocksUntilAdjustment": nextAdjustment - height,
+                },
+                "history": points,
+        })
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+        "testing"
+
+        "github.com/shopspring/decimal"
+)
+
+func TestNextDifficulty(t *testing.T) {
+        tests := []struct {
+                name                               string
+                previous, previousHashPower, power string
+                want                               string
+        }{
+                {"first window keeps difficulty", "1", "0", "500", "1"},
+                {"steady hash power", "2", "100", "100", "2"},
+                {"growth scales up", "2", "100", "150", "3"},
+                {"decline scales down", "2", "100", "50", "1"},
+                {"growth capped at 4x", "2", "100", "1000", "8"},
+                {"decline capped at 1/4", "2", "100", "1", "0.5"},
+                {"rounded to two places", "1", "3", "1", "0.33"},
+                {"floored at minimum", "0.01", "100", "10", "0.01"},
+                {"capped at maximum", "50000000", "100", "300", "99999999.99"},
+        }
+        for _, tt := range tests {
+                got := nextDifficulty(
+                        decimal.RequireFromString(tt.previous),
+                        decimal.RequireFromString(tt.previousHashPower),
+                        decimal.RequireFromString(tt.power),
+                )
+                if !got.Equal(decimal.RequireFromString(tt.want)) {
+                        t.Errorf("%s: nextDifficulty = %s, want %s", tt.name, got, tt.want)
+                }
+        }
+}
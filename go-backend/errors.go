@@ -0,0 +1,133 @@
+package main
+
+import (
+        "encoding/json"
+        "errors"
+        "net/http"
+        "sort"
+
+        "github.com/go-chi/chi/v5/middleware"
+)
+
+// ErrorCode is a stable, machine-readable identifier for an API failure.
+// Clients should switch on the code rather than on the human message.
+type ErrorCode string
+
+const (
+        ErrCodeBadRequest         ErrorCode = "BAD_REQUEST"
+        ErrCodeValidation         ErrorCode = "VALIDATION_FAILED"
+        ErrCodeUnauthorized       ErrorCode = "UNAUTHORIZED"
+        ErrCodeInvalidCredentials ErrorCode = "INVALID_CREDENTIALS"
+        ErrCodeForbidden          ErrorCode = "FORBIDDEN"
+        ErrCodeAccountFrozen      ErrorCode = "ACCOUNT_FROZEN"
+        ErrCodeAccountBanned      ErrorCode = "ACCOUNT_BANNED"
+        ErrCodeNotFound           ErrorCode = "NOT_FOUND"
+        ErrCodeConflict           ErrorCode = "CONFLICT"
+        ErrCodeInsufficientFunds  ErrorCode = "INSUFFICIENT_FUNDS"
+        ErrCodeInvalidState       ErrorCode = "INVALID_STATE"
+        ErrCodePriceUnavailable   ErrorCode = "PRICE_UNAVAILABLE"
+        ErrCodeCooldownActive     ErrorCode = "COOLDOWN_ACTIVE"
+        ErrCodeLimitExceeded      ErrorCode = "LIMIT_EXCEEDED"
+        ErrCodeInternal           ErrorCode = "INTERNAL_ERROR"
+)
+
+// errorCatalogEntry describes an error code for the /api/errors catalog
+type errorCatalogEntry struct {
+        Code        ErrorCode `json:"code"`
+        Status      int       `json:"status"`
+        Description string    `json:"description"`
+}
+
+// errorCatalog maps every error code to its HTTP status and description
+var errorCatalog = map[ErrorCode]errorCatalogEntry{
+        ErrCodeBadRequest:         {ErrCodeBadRequest, http.StatusBadRequest, "The request body could not be parsed"},
+        ErrCodeValidation:         {ErrCodeValidation, http.StatusBadRequest, "One or more fields failed validation; see fields"},
+        ErrCodeUnauthorized:       {ErrCodeUnauthorized, http.StatusUnauthorized, "Authentication is required or the session is invalid"},
+        ErrCodeInvalidCredentials: {ErrCodeInvalidCredentials, http.StatusUnauthorized, "Username or access key is incorrect"},
+        ErrCodeForbidden:          {ErrCodeForbidden, http.StatusForbidden, "The caller is not allowed to perform this action"},
+        ErrCodeAccountFrozen:      {ErrCodeAccountFrozen, http.StatusForbidden, "The account is frozen"},
+        ErrCodeAccountBanned:      {ErrCodeAccountBanned, http.StatusForbidden, "The account is banned"},
+        ErrCodeNotFound:           {ErrCodeNotFound, http.StatusNotFound, "The requested resource does not exist"},
+        ErrCodeConflict:           {ErrCodeConflict, http.StatusConflict, "The resource already exists or conflicts with current state"},
+        ErrCodeInsufficientFunds:  {ErrCodeInsufficientFunds, http.StatusBadRequest, "The balance is too low for this operation"},
+        ErrCodeInvalidState:       {ErrCodeInvalidState, http.StatusBadRequest, "The operation is not allowed in the current state"},
+        ErrCodePriceUnavailable:   {ErrCodePriceUnavailable, http.StatusServiceUnavailable, "No sufficiently recent BTC price is available"},
+        ErrCodeCooldownActive:     {ErrCodeCooldownActive, http.StatusTooManyRequests, "A cooldown is active; retry after the remaining wait"},
+        ErrCodeLimitExceeded:      {ErrCodeLimitExceeded, http.StatusBadRequest, "The amount exceeds a daily or monthly limit"},
+        ErrCodeInternal:           {ErrCodeInternal, http.StatusInternalServerError, "An unexpected server error occurred"},
+}
+
+// FieldError describes a validation failure on a single request field
+type FieldError struct {
+        Field   string `json:"field"`
+        Message string `json:"message"`
+}
+
+// APIError is an error that carries an error code and can be written
+// directly to the client with writeAPIError.
+type APIError struct {
+        Code    ErrorCode
+        Message string
+        Fields  []FieldError
+}
+
+func (e *APIError) Error() string {
+        return string(e.Code) + ": " + e.Message
+}
+
+// newAPIError creates an APIError with the given code and message
+func newAPIError(code ErrorCode, message string) *APIError {
+        return &APIError{Code: code, Message: message}
+}
+
+// statusForCode returns the HTTP status registered for an error code
+func statusForCode(code ErrorCode) int {
+        if entry, ok := errorCatalog[code]; ok {
+                return entry.Status
+        }
+        return http.StatusInternalServerError
+}
+
+// writeErrorResponse writes a structured error with the given code and message
+func writeErrorResponse(w http.ResponseWriter, r *http.Request, code ErrorCode, message string) {
+        writeAPIError(w, r, newAPIError(code, message))
+}
+
+// writeAPIError writes err to the client. Errors that are not an APIError
+// are reported as INTERNAL_ERROR without leaking their details.
+func writeAPIError(w http.ResponseWriter, r *http.Request, err error) {
+        var apiErr *APIError
+        if !errors.As(err, &apiErr) {
+                apiErr = newAPIError(ErrCodeInternal, "Internal server error")
+        }
+
+        resp := ErrorResponse{
+                Code:      apiErr.Code,
+                Message:   apiErr.Message,
+                Fields:    apiErr.Fields,
+                RequestID: middleware.GetReqID(r.Context()),
+        }
+
+        w.Header().Set("Content-Type", "application/json")
+        w.WriteHeader(statusForCode(apiErr.Code))
+        json.NewEncoder(w).Encode(resp)
+}
+
+// requestIDMiddleware assigns a request ID and echoes it in the X-Request-Id header
+func requestIDMiddleware(next http.Handler) http.Handler {
+        return middleware.RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+                w.Header().Set("X-Request-Id", middleware.GetReqID(r.Context()))
+                next.ServeHTTP(w, r)
+        }))
+}
+
+// Error catalog endpoint
+func handleErrorCatalog(w http.ResponseWriter, r *http.Request) {
+        entries := make([]errorCatalogEntry, 0, len(errorCatalog))
+        for _, entry := range errorCatalog {
+                entries = append(entries, entry)
+        }
+        sort.Slice(entries, func(i, j int) bool { return entries[i].Code < entries[j].Code })
+
+        writeJSONResponse(w, http.StatusOK, map[string]interface{}{"errors": entries})
+}
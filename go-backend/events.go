@@ -0,0 +1,351 @@
+package main
+
+import (
+        "context"
+        "encoding/json"
+        "fmt"
+        "log"
+        "net/http"
+        "sync"
+        "time"
+
+        "github.com/shopspring/decimal"
+)
+
+// eventsChannel is the Postgres NOTIFY channel events are published on, so
+// every Go instance and the TypeScript server share one stream
+const eventsChannel = "b2b_events"
+
+// Event types pushed to clients
+const (
+        eventBlock      = "block"
+        eventReward     = "reward"
+        eventDeposit    = "deposit"
+        eventWithdrawal = "withdrawal"
+        eventPrice      = "price"
+
+        // eventSetting announces a settings change; it is consumed by the
+        // hub and never forwarded to streams
+        eventSetting = "setting"
+)
+
+const (
+        // eventBufferSize is how many events a stream may fall behind before it
+        // is dropped; the client reconnects and refreshes with the REST API
+        eventBufferSize = 64
+
+        eventHeartbeatInterval = 15 * time.Second
+        eventWriteTimeout      = 10 * time.Second
+        eventListenRetry       = 5 * time.Second
+
+        // maxNotifyPayload is just under Postgres' 8000 byte NOTIFY limit
+        maxNotifyPayload = 7900
+)
+
+// Event is a notification on eventsChannel. Events with a UserID are only
+// delivered to that user's streams.
+type Event struct {
+        Type   string          `json:"type"`
+        UserID string          `json:"userId,omitempty"`
+        Data   json.RawMessage `json:"data"`
+}
+
+// publishEvent sends an event on eventsChannel. Inside a transaction the
+// event is only delivered if the transaction commits.
+func publishEvent(ctx context.Context, q rowQuerier, eventType, userID string, data interface{}) error {
+        raw, err := json.Marshal(data)
+        if err != nil {
+                return fmt.Errorf("failed to encode %s event: %w", eventType, err)
+        }
+        payload, err := json.Marshal(Event{Type: eventType, UserID: userID, Data: raw})
+        if err != nil {
+                return fmt.Errorf("failed to encode %s event: %w", eventType, err)
+        }
+        if len(payload) > maxNotifyPayload {
+                return fmt.Errorf("%s event is %d bytes, over the notify limit", eventType, len(payload))
+        }
+
+        var sent int
+        err = q.QueryRow(ctx, "WITH n AS (SELECT pg_notify($1, $2)) SELECT COUNT(*) FROM n", eventsChannel, string(payload)).Scan(&sent)
+        if err != nil {
+                return fmt.Errorf("failed to publish %s event: %w", eventType, err)
+        }
+        return nil
+}
+
+// eventSubscriber is one open stream
+type eventSubscriber struct {
+        userID  string
+        events  chan *Event
+        dropped chan struct{}
+        once    sync.Once
+}
+
+// drop signals the stream that it fell behind and must close
+func (s *eventSubscriber) drop() {
+        s.once.Do(func() { close(s.dropped) })
+}
+
+// EventHub listens on eventsChannel and fans events out to the streams
+// connected to this instance
+type EventHub struct {
+        mu          sync.RWMutex
+        subscribers map[*eventSubscriber]struct{}
+}
+
+var eventHub = &EventHub{subscribers: make(map[*eventSubscriber]struct{})}
+
+// Subscribe opens a stream for the user
+func (h *EventHub) Subscribe(userID string) *eventSubscriber {
+        s := &eventSubscriber{
+                userID:  userID,
+                events:  make(chan *Event, eventBufferSize),
+                dropped: make(chan struct{}),
+        }
+        h.mu.Lock()
+        h.subscribers[s] = struct{}{}
+        h.mu.Unlock()
+        return s
+}
+
+// Unsubscribe closes a stream
+func (h *EventHub) Unsubscribe(s *eventSubscriber) {
+        h.mu.Lock()
+        delete(h.subscribers, s)
+        h.mu.Unlock()
+}
+
+// Start listens for events until ctx is cancelled, reconnecting when the
+// listening connection fails
+func (h *EventHub) Start(ctx context.Context) {
+        go func() {
+                for ctx.Err() == nil {
+                        if err := h.listen(ctx); err != nil && ctx.Err() == nil {
+                                log.Printf("Event listener failed, retrying in %s: %v", eventListenRetry, err)
+                                select {
+                                case <-ctx.Done():
+                                case <-time.After(eventListenRetry):
+                                }
+                        }
+                }
+        }()
+}
+
+// listen holds a pool connection for LISTEN and dispatches notifications
+func (h *EventHub) listen(ctx context.Context) error {
+        conn, err := db.Acquire(ctx)
+        if err != nil {
+                return fmt.Errorf("failed to acquire connection: %w", err)
+        }
+        defer conn.Release()
+        // Return the connection to the pool without the subscription
+        defer func() {
+                cleanup, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+                defer cancel()
+                conn.Exec(cleanup, "UNLISTEN *")
+        }()
+
+        if _, err := conn.Exec(ctx, "LISTEN "+eventsChannel); err != nil {
+                return fmt.Errorf("failed to listen: %w", err)
+        }
+        // Settings changes are only seen while listening, so cache them
+        // only while listening
+        settingCache.setEnabled(true)
+        defer settingCache.setEnabled(false)
+
+        for {
+                notification, err := conn.Conn().WaitForNotification(ctx)
+                if err != nil {
+                        return err
+                }
+                var event Event
+                if err := json.Unmarshal([]byte(notification.Payload), &event); err != nil {
+                        log.Printf("Ignoring malformed event: %v", err)
+                        continue
+                }
+                h.dispatch(ctx, &event)
+        }
+}
+
+// dispatch delivers an event to the matching streams. A block event is
+// followed by a reward event for each connected user paid by the block.
+func (h *EventHub) dispatch(ctx context.Context, event *Event) {
+        if event.Type == eventSetting {
+                var setting struct {
+                        Key string `json:"key"`
+                }
+                if err := json.Unmarshal(event.Data, &setting); err == nil {
+                        settingCache.invalidate(setting.Key)
+                }
+                return
+        }
+
+        h.mu.RLock()
+        users := make(map[string]bool)
+        for s := range h.subscribers {
+                if event.UserID == "" || event.UserID == s.userID {
+                        h.deliver(s, event)
+                }
+                users[s.userID] = true
+        }
+        h.mu.RUnlock()
+
+        if event.Type == eventBlock && len(users) > 0 {
+                var block struct {
+                        Height int64 `json:"height"`
+                }
+                if err := json.Unmarshal(event.Data, &block); err != nil {
+                        return
+                }
+                ids := make([]string, 0, len(users))
+                for id := range users {
+                        ids = append(ids, id)
+                }
+                rewards, err := h.blockRewards(ctx, block.Height, ids)
+                if err != nil {
+                        log.Printf("Failed to load rewards for block %d: %v", block.Height, err)
+                        return
+                }
+                for _, reward := range rewards {
+                        h.dispatch(ctx, reward)
+                }
+        }
+}
+
+// blockRewards builds the reward events of a block for the given users
+func (h *EventHub) blockRewards(ctx context.Context, height int64, userIDs []string) ([]*Event, error) {
+        rows, err := db.Query(ctx, `
+                SELECT user_id, reward, tx_hash, expires_at FROM unclaimed_blocks
+                WHERE block_height = $1 AND user_id = ANY($2)`, height, userIDs)
+        if err != nil {
+                return nil, err
+        }
+        defer rows.Close()
+
+        var events []*Event
+        for rows.Next() {
+                var userID, txHash string
+                var reward decimal.Decimal
+                var expiresAt time.Time
+                if err := rows.Scan(&userID, &reward, &txHash, &expiresAt); err != nil {
+                        return nil, err
+                }
+                data, err := json.Marshal(map[string]interface{}{
+                        "height":    height,
+                        "reward":    formatAmount(reward, CurrencyGBTC),
+                        "txHash":    txHash,
+                        "expiresAt": expiresAt,
+                })
+                if err != nil {
+                        return nil, err
+                }
+                events = append(events, &Event{Type: eventReward, UserID: userID, Data: data})
+        }
+        return events, rows.Err()
+}
+
+// deliver queues an event without blocking; a stream whose buffer is full
+// is dropped
+func (h *EventHub) deliver(s *eventSubscriber, event *Event) {
+        select {
+        case s.events <- event:
+        default:
+                s.drop()
+        }
+}
+
+// writeSSE writes one server-sent event, or a comment when eventType is
+// empty, and flushes it
+func writeSSE(w http.ResponseWriter, rc *http.ResponseController, eventType string, data []byte) error {
+        rc.SetWriteDeadline(time.Now().Add(eventWriteTimeout))
+        var err error
+        if eventType == "" {
+                _, err = fmt.Fprintf(w, ": %s\n\n", data)
+        } else {
+                _, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", eventType, data)
+        }
+        if err != nil {
+                return err
+        }
+        return rc.Flush()
+}
+
+// streamAccessRevoked re-checks a stream's session and user, returning the
+// error to end the stream with once they may no longer receive events. A
+// failed lookup keeps the stream open until the next check.
+func streamAccessRevoked(r *http.Request, userID string) *APIError {
+        session, _ := store.Get(r, "session")
+        if sessionExpired(session) {
+                return newAPIError(ErrCodeUnauthorized, "Session expired")
+        }
+
+        user, err := getUserByID(r.Context(), userID)
+        if err != nil {
+                log.Printf("Event stream: failed to re-check user %s: %v", userID, err)
+                return nil
+        }
+        switch {
+        case user == nil:
+                return newAPIError(ErrCodeUnauthorized, "Invalid session")
+        case user.IsBanned:
+                return newAPIError(ErrCodeAccountBanned, "Account is banned")
+        case user.IsFrozen:
+                return newAPIError(ErrCodeAccountFrozen, "Account is frozen")
+        }
+        return nil
+}
+
+// Event stream endpoint. Streams server-sent events for new blocks, the
+// user's rewards, deposit and withdrawal status changes and BTC prices. The
+// user is re-checked on every heartbeat and the stream closed with a
+// "revoked" event once they are banned, frozen or their session expires.
+func handleEventStream(w http.ResponseWriter, r *http.Request) {
+        user := getUserFromContext(r.Context())
+        if user == nil {
+                writeErrorResponse(w, r, ErrCodeUnauthorized, "Unauthorized")
+                return
+        }
+
+        rc := http.NewResponseController(w)
+        w.Header().Set("Content-Type", "text/event-stream")
+        w.Header().Set("Cache-Control", "no-cache")
+        w.Header().Set("Connection", "keep-alive")
+        w.Header().Set("X-Accel-Buffering", "no")
+        w.WriteHeader(http.StatusOK)
+
+        sub := eventHub.Subscribe(user.ID)
+        defer eventHub.Unsubscribe(sub)
+
+        // Ask clients to wait a little before reconnecting after a drop
+        if _, err := fmt.Fprint(w, "retry: 5000\n\n"); err != nil {
+                return
+        }
+        if err := writeSSE(w, rc, "", []byte("connected")); err != nil {
+                return
+        }
+
+        heartbeat := time.NewTicker(eventHeartbeatInterval)
+        defer heartbeat.Stop()
+        for {
+                select {
+                case <-r.Context().Done():
+                        return
+                case <-sub.dropped:
+                        writeSSE(w, rc, "overflow", []byte(`{"message":"Stream fell behind; reconnect and refresh"}`))
+                        return
+                case <-heartbeat.C:
+                        if apiErr := streamAccessRevoked(r, user.ID); apiErr != nil {
+                                data, _ := json.Marshal(map[string]string{"code": string(apiErr.Code), "message": apiErr.Message})
+                                writeSSE(w, rc, "revoked", data)
+                                return
+                        }
+                        if err := writeSSE(w, rc, "", []byte("heartbeat")); err != nil {
+                                return
+                        }
+                case event := <-sub.events:
+                        if err := writeSSE(w, rc, event.Type, event.Data); err != nil {
+                                return
+                        }
+                }
+        }
+}
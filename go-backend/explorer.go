@@ -0,0 +1,222 @@
+package main
+
+import (
+        "context"
+        "fmt"
+        "net/http"
+        "strconv"
+        "strings"
+        "time"
+
+        "github.com/go-chi/chi/v5"
+        "github.com/jackc/pgx/v4"
+        "github.com/shopspring/decimal"
+)
+
+// rewardStatusSQL derives a reward's state (claimed, expired or unclaimed)
+// from unclaimed_blocks columns, given the placeholder holding the current time
+func rewardStatusSQL(now string) string {
+        return `CASE WHEN b.claimed THEN 'claimed' WHEN b.expired OR b.expires_at <= ` + now +
+                ` THEN 'expired' ELSE 'unclaimed' END`
+}
+
+// explorerBlockColumns lists the mining_blocks columns scanned by
+// scanExplorerBlock, with the participant count from unclaimed_blocks
+const explorerBlockColumns = `m.id, m.block_number, m.height, m.reward, m.total_hash_power, m.timestamp,
+        (SELECT COUNT(*) FROM unclaimed_blocks b WHERE b.block_height = m.height)`
+
+func scanExplorerBlock(row pgx.Row) (*MiningBlock, error) {
+        var b MiningBlock
+        err := row.Scan(&b.ID, &b.Number, &b.Height, &b.Reward, &b.TotalHashPower, &b.Timestamp, &b.Participants)
+        if err != nil {
+                return nil, err
+        }
+        return &b, nil
+}
+
+func explorerBlockResponse(b *MiningBlock) map[string]interface{} {
+        return map[string]interface{}{
+                "height":         b.Height,
+                "blockNumber":    b.Number,
+                "reward":         formatAmount(b.Reward, CurrencyGBTC),
+                "totalHashPower": formatHashPower(b.TotalHashPower),
+                "participants":   b.Participants,
+                "timestamp":      b.Timestamp,
+        }
+}
+
+// getBlockShares returns the reward shares of a block. Admins see every
+// share; other users only see their own, and anonymous callers none.
+func getBlockShares(ctx context.Context, height int64, viewer *User) ([]map[string]interface{}, error) {
+        if viewer == nil {
+                return nil, nil
+        }
+
+        rows, err := db.Query(ctx, `
+                SELECT b.user_id, u.username, b.reward, b.tx_hash, `+rewardStatusSQL("$4")+`
+                FROM unclaimed_blocks b
+                JOIN users u ON u.id = b.user_id
+                WHERE b.block_height = $1 AND ($2 OR b.user_id = $3)
+                ORDER BY b.reward DESC, u.username`, height, viewer.IsAdmin, viewer.ID, clock.Now())
+        if err != nil {
+                return nil, fmt.Errorf("failed to query block shares: %w", err)
+        }
+        defer rows.Close()
+
+        shares := make([]map[string]interface{}, 0)
+        for rows.Next() {
+                var userID, username, txHash, status string
+                var reward decimal.Decimal
+                if err := rows.Scan(&userID, &username, &reward, &txHash, &status); err != nil {
+                        return nil, fmt.Errorf("failed to scan block share: %w", err)
+                }
+                shares = append(shares, map[string]interface{}{
+                        "userId":   userID,
+                        "username": username,
+                        "reward":   formatAmount(reward, CurrencyGBTC),
+                        "txHash":   txHash,
+                        "status":   status,
+                })
+        }
+        return shares, rows.Err()
+}
+
+// Block list endpoint, newest first. Pass before=<height> to page back.
+func handleListBlocks(w http.ResponseWriter, r *http.Request) {
+        limit := 20
+        if v := r.URL.Query().Get("limit"); v != "" {
+                n, err := strconv.Atoi(v)
+                if err != nil || n < 1 || n > 100 {
+                        writeErrorResponse(w, r, ErrCodeValidation, "limit must be between 1 and 100")
+                        return
+                }
+                limit = n
+        }
+        var before *int64
+        if v := r.URL.Query().Get("before"); v != "" {
+                n, err := strconv.ParseInt(v, 10, 64)
+                if err != nil || n < 1 {
+                        writeErrorResponse(w, r, ErrCodeValidation, "before must be a positive block height")
+                        return
+                }
+                before = &n
+        }
+
+        // Blocks mined before heights were tracked are not listed
+        rows, err := db.Query(r.Context(), `
+                SELECT `+explorerBlockColumns+`
+                FROM mining_blocks m
+                WHERE m.height IS NOT NULL AND ($1::bigint IS NULL OR m.height < $1)
+                ORDER BY m.height DESC
+                LIMIT $2`, before, limit)
+        if err != nil {
+                writeErrorResponse(w, r, ErrCodeInternal, "Failed to load blocks")
+                return
+        }
+        defer rows.Close()
+
+        blocks := make([]map[string]interface{}, 0)
+        var last int64
+        for rows.Next() {
+                b, err := scanExplorerBlock(rows)
+                if err != nil {
+                        writeErrorResponse(w, r, ErrCodeInternal, "Failed to load blocks")
+                        return
+                }
+                blocks = append(blocks, explorerBlockResponse(b))
+                last = b.Height
+        }
+        if rows.Err() != nil {
+                writeErrorResponse(w, r, ErrCodeInternal, "Failed to load blocks")
+                return
+        }
+
+        var next *int64
+        if len(blocks) == limit && last > 1 {
+                next = &last
+        }
+        writeJSONResponse(w, http.StatusOK, map[string]interface{}{"blocks": blocks, "nextBefore": next})
+}
+
+// Block detail endpoint. The number in the path is the block height.
+func handleGetBlock(w http.ResponseWriter, r *http.Request) {
+        height, err := strconv.ParseInt(chi.URLParam(r, "number"), 10, 64)
+        if err != nil || height < 1 {
+                writeErrorResponse(w, r, ErrCodeNotFound, "Block not found")
+                return
+        }
+
+        block, err := scanExplorerBlock(db.QueryRow(r.Context(), `
+                SELECT `+explorerBlockColumns+`
+                FROM mining_blocks m
+                WHERE m.height = $1`, height))
+        if err == pgx.ErrNoRows {
+                writeErrorResponse(w, r, ErrCodeNotFound, "Block not found")
+                return
+        }
+        if err != nil {
+                writeErrorResponse(w, r, ErrCodeInternal, "Failed to load block")
+                return
+        }
+
+        shares, err := getBlockShares(r.Context(), height, getUserFromContext(r.Context()))
+        if err != nil {
+                writeErrorResponse(w, r, ErrCodeInternal, "Failed to load block")
+                return
+        }
+
+        resp := explorerBlockResponse(block)
+        resp["shares"] = shares
+        writeJSONResponse(w, http.StatusOK, map[string]interface{}{"block": resp})
+}
+
+// Transaction lookup endpoint for reward tx hashes. The recipient is only
+// shown to them and to admins.
+func handleGetTx(w http.ResponseWriter, r *http.Request) {
+        hash := strings.ToLower(chi.URLParam(r, "hash"))
+        if !txHashPattern.MatchString(hash) {
+                writeErrorResponse(w, r, ErrCodeNotFound, "Transaction not found")
+                return
+        }
+        if !strings.HasPrefix(hash, "0x") {
+                hash = "0x" + hash
+        }
+
+        var userID, username, status string
+        var height *int64
+        var blockNumber int
+        var reward decimal.Decimal
+        var createdAt, expiresAt time.Time
+        var claimedAt *time.Time
+        err := db.QueryRow(r.Context(), `
+                SELECT b.user_id, u.username, b.block_height, b.block_number, b.reward, `+rewardStatusSQL("$2")+`,
+                       b.created_at, b.expires_at, b.claimed_at
+                FROM unclaimed_blocks b
+                JOIN users u ON u.id = b.user_id
+                WHERE lower(b.tx_hash) = $1`, hash, clock.Now()).Scan(&userID, &username, &height, &blockNumber, &reward, &status,
+                &createdAt, &expiresAt, &claimedAt)
+        if err == pgx.ErrNoRows {
+                writeErrorResponse(w, r, ErrCodeNotFound, "Transaction not found")
+                return
+        }
+        if err != nil {
+                writeErrorResponse(w, r, ErrCodeInternal, "Failed to load transaction")
+                return
+        }
+
+        tx := map[string]interface{}{
+                "txHash":      hash,
+                "type":        "block_reward",
+                "blockHeight": height,
+                "blockNumber": blockNumber,
+                "amount":      formatAmount(reward, CurrencyGBTC),
+                "status":      status,
+                "timestamp":   createdAt,
+                "expiresAt":   expiresAt,
+                "claimedAt":   claimedAt,
+        }
+        if viewer := getUserFromContext(r.Context()); viewer != nil && (viewer.IsAdmin || viewer.ID == userID) {
+                tx["recipient"] = map[string]interface{}{"userId": userID, "username": username}
+        }
+        writeJSONResponse(w, http.StatusOK, map[string]interface{}{"transaction": tx})
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+        "context"
+        "encoding/json"
+        "fmt"
+        "sort"
+        "time"
+
+        "github.com/jackc/pgx/v4"
+        "github.com/shopspring/decimal"
+)
+
+// Settings keys used for hash power pricing
+const (
+        settingHashPowerPrice = "hashPowerPrice"
+        settingHashPowerTiers = "hashPowerTiers"
+)
+
+var defaultHashPowerPrice = decimal.NewFromInt(1)
+
+// pricingTier is a volume discount applied to purchases of at least MinAmount USDT
+type pricingTier struct {
+        MinAmount       decimal.Decimal `json:"minAmount"`
+        DiscountPercent decimal.Decimal `json:"discountPercent"`
+}
+
+// defaultPricingTiers is used when hashPowerTiers has not been configured
+var defaultPricingTiers = []pricingTier{
+        {MinAmount: decimal.NewFromInt(1000), DiscountPercent: decimal.NewFromInt(5)},
+        {MinAmount: decimal.NewFromInt(5000), DiscountPercent: decimal.NewFromInt(10)},
+        {MinAmount: decimal.NewFromInt(10000), DiscountPercent: decimal.NewFromInt(15)},
+}
+
+// HashPowerQuote is the price applied to a hash power purchase
+type HashPowerQuote struct {
+        Amount          decimal.Decimal `json:"amount"`
+        UnitPrice       decimal.Decimal `json:"unitPrice"`
+        DiscountPercent decimal.Decimal `json:"discountPercent"`
+        EffectivePrice  decimal.Decimal `json:"effectivePrice"`
+        HashPower       decimal.Decimal `json:"hashPower"`
+}
+
+// HashPowerContract represents the hash_power_contracts table. Contracts
+// without an expiry are permanent purchases.
+type HashPowerContract struct {
+        ID              string          `json:"id" db:"id"`
+        UserID          string          `json:"userId" db:"user_id"`
+        HashPower       decimal.Decimal `json:"hashPower" db:"hash_power"`
+        USDTCost        decimal.Decimal `json:"usdtCost" db:"usdt_cost"`
+        UnitPrice       decimal.Decimal `json:"unitPrice" db:"unit_price"`
+        DiscountPercent decimal.Decimal `json:"discountPercent" db:"discount_percent"`
+        DurationDays    *int            `json:"durationDays" db:"duration_days"`
+        AutoRenew       bool            `json:"autoRenew" db:"auto_renew"`
+        RenewalCount    int             `json:"renewalCount" db:"renewal_count"`
+        Status          string          `json:"status" db:"status"`
+        StartsAt        time.Time       `json:"startsAt" db:"starts_at"`
+        ExpiresAt       *time.Time      `json:"expiresAt" db:"expires_at"`
+        CreatedAt       time.Time       `json:"createdAt" db:"created_at"`
+}
+
+// loadPricingTiers reads the volume discount tiers, highest threshold first
+func loadPricingTiers(ctx context.Context, q rowQuerier) ([]pricingTier, error) {
+        tiers := defaultPricingTiers
+
+        value, ok, err := getSystemSetting(ctx, q, settingHashPowerTiers)
+        if err != nil {
+                return nil, err
+        }
+        if ok {
+                var configured []pricingTier
+                if err := json.Unmarshal([]byte(value), &configured); err != nil {
+                        return nil, fmt.Errorf("setting %s is not valid JSON: %w", settingHashPowerTiers, err)
+                }
+                tiers = configured
+        }
+
+        sorted := make([]pricingTier, len(tiers))
+        copy(sorted, tiers)
+        sort.Slice(sorted, func(i, j int) bool { return sorted[i].MinAmount.GreaterThan(sorted[j].MinAmount) })
+        return sorted, nil
+}
+
+// quoteHashPower prices a purchase of amount USDT using the configured unit
+// price and the best volume tier the amount qualifies for.
+func quoteHashPower(ctx context.Context, q rowQuerier, amount decimal.Decimal) (*HashPowerQuote, error) {
+        unitPrice, err := getDecimalSetting(ctx, q, settingHashPowerPrice, defaultHashPowerPrice)
+        if err != nil {
+                return nil, err
+        }
+        if !unitPrice.IsPositive() {
+                return nil, fmt.Errorf("setting %s must be positive", settingHashPowerPrice)
+        }
+
+        tiers, err := loadPricingTiers(ctx, q)
+        if err != nil {
+                return nil, err
+        }
+
+        discount := decimal.Zero
+        for _, tier := range tiers {
+                if amount.GreaterThanOrEqual(tier.MinAmount) {
+                        discount = tier.DiscountPercent
+                        break
+                }
+        }
+
+        hundred := decimal.NewFromInt(100)
+        effectivePrice := unitPrice.Mul(hundred.Sub(discount)).Div(hundred)
+
+        return &HashPowerQuote{
+                Amount:          amount,
+                UnitPrice:       unitPrice,
+                DiscountPercent: discount,
+                EffectivePrice:  effectivePrice,
+                HashPower:       amount.Div(effectivePrice).Truncate(hashPowerScale),
+        }, nil
+}
+
+// purchaseHashPower debits amount USDT from the user and credits the quoted
+// hash power in a single transaction. A non-nil durationDays makes the
+// purchase an expiring rental contract, which autoRenew extends at expiry.
+func purchaseHashPower(ctx context.Context, userID string, amount decimal.Decimal, durationDays *int, autoRenew bool) (*HashPowerContract, *HashPowerQuote, error) {
+        if durationDays == nil && autoRenew {
+                return nil, nil, newAPIError(ErrCodeValidation, "Auto-renew is only available for rental contracts")
+        }
+
+        tx, err := db.Begin(ctx)
+        if err != nil {
+                return nil, nil, fmt.Errorf("failed to begin transaction: %w", err)
+        }
+        defer tx.Rollback(ctx)
+
+        var usdtBalance decimal.Decimal
+        err = tx.QueryRow(ctx, "SELECT usdt_balance FROM users WHERE id = $1 FOR UPDATE", userID).Scan(&usdtBalance)
+        if err == pgx.ErrNoRows {
+                return nil, nil, newAPIError(ErrCodeNotFound, "User not found")
+        }
+        if err != nil {
+                return nil, nil, fmt.Errorf("failed to lock user: %w", err)
+        }
+
+        if usdtBalance.LessThan(amount) {
+                return nil, nil, newAPIError(ErrCodeInsufficientFunds, "Insufficient USDT balance")
+        }
+
+        quote, err := quoteHashPower(ctx, tx, amount)
+        if err != nil {
+                return nil, nil, err
+        }
+        if !quote.HashPower.IsPositive() {
+                return nil, nil, newAPIError(ErrCodeValidation, "Amount is too small to buy any hash power")
+        }
+
+        // hash_power is always base hash power plus the referral bonus
+        _, err = tx.Exec(ctx, `
+                UPDATE users
+                SET usdt_balance = usdt_balance - $1,
+                    base_hash_power = base_hash_power + $2,
+                    hash_power = base_hash_power + $2 + referral_hash_bonus
+                WHERE id = $3`,
+                amount.String(), quote.HashPower.String(), userID)
+        if err != nil {
+                return nil, nil, fmt.Errorf("failed to update balances: %w", err)
+        }
+
+        now := clock.Now()
+        var expiresAt *time.Time
+        if durationDays != nil {
+                t := now.AddDate(0, 0, *durationDays)
+                expiresAt = &t
+        }
+
+        contract := HashPowerContract{
+                UserID:          userID,
+                HashPower:       quote.HashPower,
+                USDTCost:        amount,
+                UnitPrice:       quote.EffectivePrice,
+                DiscountPercent: quote.DiscountPercent,
+                DurationDays:    durationDays,
+                AutoRenew:       autoRenew,
+                Status:          contractStatusActive,
+                StartsAt:        now,
+                ExpiresAt:       expiresAt,
+        }
+        err = tx.QueryRow(ctx, `
+                INSERT INTO hash_power_contracts (user_id, hash_power, usdt_cost, unit_price, discount_percent,
+                                                  duration_days, auto_renew, status, starts_at, expires_at, created_at)
+                VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $9)
+                RETURNING id, created_at`,
+                userID, contract.HashPower.String(), contract.USDTCost.String(), contract.UnitPrice.String(),
+                contract.DiscountPercent.String(), durationDays, autoRenew, contract.Status, now, expiresAt,
+        ).Scan(&contract.ID, &contract.CreatedAt)
+        if err != nil {
+                return nil, nil, fmt.Errorf("failed to record contract: %w", err)
+        }
+
+        if err := tx.Commit(ctx); err != nil {
+                return nil, nil, fmt.Errorf("failed to commit purchase: %w", err)
+        }
+
+        return &contract, quote, nil
+}
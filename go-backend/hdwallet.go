@@ -0,0 +1,267 @@
+package main
+
+import (
+        "crypto/hmac"
+        "crypto/sha256"
+        "crypto/sha512"
+        "encoding/binary"
+        "encoding/hex"
+        "errors"
+        "math/big"
+
+        "golang.org/x/crypto/ripemd160"
+)
+
+// Extended public key versions. An xpub derives legacy P2PKH Bitcoin
+// addresses (BIP44) and a zpub native segwit ones (BIP84); EVM chains use an
+// xpub.
+const (
+        xpubVersion uint32 = 0x0488b21e
+        xprvVersion uint32 = 0x0488ade4
+        zpubVersion uint32 = 0x04b24746
+        zprvVersion uint32 = 0x04b2430c
+)
+
+const (
+        // accountKeyDepth is the depth of m/purpose'/coin'/account', the level
+        // deposit xpubs are exported at
+        accountKeyDepth = 3
+
+        // hardenedKeyStart is the first hardened child index, which cannot be
+        // derived from a public key
+        hardenedKeyStart = 0x80000000
+)
+
+// secp256k1 domain parameters
+var (
+        secp256k1P = mustHexInt("fffffffffffffffffffffffffffffffffffffffffffffffffffffffefffffc2f")
+        secp256k1N = mustHexInt("fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141")
+        secp256k1G = curvePoint{
+                x: mustHexInt("79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"),
+                y: mustHexInt("483ada7726a3c4655da4fbfc0e1108a8fd17b448a68554199c47d08ffb10d4b8"),
+        }
+)
+
+var errInvalidChildKey = errors.New("derived key is invalid; use the next index")
+
+func mustHexInt(s string) *big.Int {
+        n, ok := new(big.Int).SetString(s, 16)
+        if !ok {
+                panic("hdwallet: invalid constant " + s)
+        }
+        return n
+}
+
+// curvePoint is an affine point on secp256k1; a nil x is the point at infinity
+type curvePoint struct {
+        x, y *big.Int
+}
+
+func (p curvePoint) isInfinity() bool {
+        return p.x == nil
+}
+
+// add returns p + q
+func (p curvePoint) add(q curvePoint) curvePoint {
+        if p.isInfinity() {
+                return q
+        }
+        if q.isInfinity() {
+                return p
+        }
+        if p.x.Cmp(q.x) == 0 {
+                if p.y.Cmp(q.y) != 0 || p.y.Sign() == 0 {
+                        return curvePoint{}
+                }
+                return p.double()
+        }
+        // slope = (qy - py) / (qx - px)
+        dx := new(big.Int).Sub(q.x, p.x)
+        dx.Mod(dx, secp256k1P)
+        slope := new(big.Int).Sub(q.y, p.y)
+        slope.Mul(slope, dx.ModInverse(dx, secp256k1P))
+        slope.Mod(slope, secp256k1P)
+        return p.withSlope(slope, q.x)
+}
+
+// double returns 2p
+func (p curvePoint) double() curvePoint {
+        if p.isInfinity() || p.y.Sign() == 0 {
+                return curvePoint{}
+        }
+        // slope = 3px^2 / 2py
+        slope := new(big.Int).Mul(p.x, p.x)
+        slope.Mul(slope, big.NewInt(3))
+        slope.Mul(slope, new(big.Int).ModInverse(new(big.Int).Lsh(p.y, 1), secp256k1P))
+        slope.Mod(slope, secp256k1P)
+        return p.withSlope(slope, p.x)
+}
+
+// withSlope finishes an addition of p and a point with x coordinate qx
+func (p curvePoint) withSlope(slope, qx *big.Int) curvePoint {
+        x := new(big.Int).Mul(slope, slope)
+        x.Sub(x, p.x)
+        x.Sub(x, qx)
+        x.Mod(x, secp256k1P)
+        y := new(big.Int).Sub(p.x, x)
+        y.Mul(y, slope)
+        y.Sub(y, p.y)
+        y.Mod(y, secp256k1P)
+        return curvePoint{x: x, y: y}
+}
+
+// scalarBaseMult returns k*G
+func scalarBaseMult(k *big.Int) curvePoint {
+        result := curvePoint{}
+        addend := secp256k1G
+        for i := 0; i < k.BitLen(); i++ {
+                if k.Bit(i) == 1 {
+                        result = result.add(addend)
+                }
+                addend = addend.double()
+        }
+        return result
+}
+
+// compressed serializes the point as 33 bytes (SEC1)
+func (p curvePoint) compressed() []byte {
+        out := make([]byte, 33)
+        out[0] = 0x02 + byte(p.y.Bit(0))
+        p.x.FillBytes(out[1:])
+        return out
+}
+
+// uncompressed serializes the point as 65 bytes (SEC1)
+func (p curvePoint) uncompressed() []byte {
+        out := make([]byte, 65)
+        out[0] = 0x04
+        p.x.FillBytes(out[1:33])
+        p.y.FillBytes(out[33:])
+        return out
+}
+
+// decompressPoint parses a 33 byte compressed point
+func decompressPoint(b []byte) (curvePoint, bool) {
+        if len(b) != 33 || (b[0] != 0x02 && b[0] != 0x03) {
+                return curvePoint{}, false
+        }
+        x := new(big.Int).SetBytes(b[1:])
+        if x.Cmp(secp256k1P) >= 0 {
+                return curvePoint{}, false
+        }
+        // y^2 = x^3 + 7; p = 3 mod 4, so y = (y^2)^((p+1)/4)
+        ySquared := new(big.Int).Exp(x, big.NewInt(3), secp256k1P)
+        ySquared.Add(ySquared, big.NewInt(7))
+        ySquared.Mod(ySquared, secp256k1P)
+        exp := new(big.Int).Add(secp256k1P, big.NewInt(1))
+        exp.Rsh(exp, 2)
+        y := new(big.Int).Exp(ySquared, exp, secp256k1P)
+        if new(big.Int).Exp(y, big.NewInt(2), secp256k1P).Cmp(ySquared) != 0 {
+                return curvePoint{}, false
+        }
+        if y.Bit(0) != uint(b[0]&1) {
+                y.Sub(secp256k1P, y)
+        }
+        return curvePoint{x: x, y: y}, true
+}
+
+// extendedPublicKey is a BIP32 extended public key
+type extendedPublicKey struct {
+        version     uint32
+        depth       byte
+        fingerprint uint32
+        childNumber uint32
+        chainCode   []byte
+        key         curvePoint
+}
+
+// parseExtendedPublicKey decodes a base58 xpub or zpub. Extended private
+// keys are rejected so they are never stored.
+func parseExtendedPublicKey(s string) (*extendedPublicKey, error) {
+        version, payload, ok := base58CheckDecode(s)
+        if !ok {
+                return nil, errors.New("not a valid base58check string")
+        }
+        data := append([]byte{version}, payload...)
+        if len(data) != 78 {
+                return nil, errors.New("not a 78 byte extended key")
+        }
+
+        k := &extendedPublicKey{
+                version:     binary.BigEndian.Uint32(data[0:4]),
+                depth:       data[4],
+                fingerprint: binary.BigEndian.Uint32(data[5:9]),
+                childNumber: binary.BigEndian.Uint32(data[9:13]),
+                chainCode:   data[13:45],
+        }
+        switch k.version {
+        case xpubVersion, zpubVersion:
+        case xprvVersion, zprvVersion:
+                return nil, errors.New("is a private key; configure the extended public key instead")
+        default:
+                return nil, errors.New("unsupported extended key version")
+        }
+        if k.key, ok = decompressPoint(data[45:]); !ok {
+                return nil, errors.New("public key is not on secp256k1")
+        }
+        return k, nil
+}
+
+// id is the hash160 of the key, identifying which xpub an address came from
+func (k *extendedPublicKey) id() string {
+        return hex.EncodeToString(hash160(k.key.compressed()))
+}
+
+// child derives the non-hardened child at index (BIP32 CKDpub)
+func (k *extendedPublicKey) child(index uint32) (*extendedPublicKey, error) {
+        if index >= hardenedKeyStart {
+                return nil, errors.New("cannot derive a hardened child from a public key")
+        }
+        mac := hmac.New(sha512.New, k.chainCode)
+        mac.Write(k.key.compressed())
+        mac.Write(binary.BigEndian.AppendUint32(nil, index))
+        sum := mac.Sum(nil)
+
+        il := new(big.Int).SetBytes(sum[:32])
+        if il.Cmp(secp256k1N) >= 0 {
+                return nil, errInvalidChildKey
+        }
+        key := scalarBaseMult(il).add(k.key)
+        if key.isInfinity() {
+                return nil, errInvalidChildKey
+        }
+
+        parent := hash160(k.key.compressed())
+        return &extendedPublicKey{
+                version:     k.version,
+                depth:       k.depth + 1,
+                fingerprint: binary.BigEndian.Uint32(parent[:4]),
+                childNumber: index,
+                chainCode:   sum[32:],
+                key:         key,
+        }, nil
+}
+
+// hash160 is RIPEMD160(SHA256(data))
+func hash160(data []byte) []byte {
+        sha := sha256.Sum256(data)
+        h := ripemd160.New()
+        h.Write(sha[:])
+        return h.Sum(nil)
+}
+
+// btcAddress encodes the key as a P2PKH address for an xpub or a P2WPKH
+// address for a zpub
+func (k *extendedPublicKey) btcAddress() string {
+        pubKeyHash := hash160(k.key.compressed())
+        if k.version == zpubVersion {
+                return encodeSegwitAddress("bc", 0, pubKeyHash)
+        }
+        return base58CheckEncode(btcP2PKHVersion, pubKeyHash)
+}
+
+// evmAddress encodes the key as an EIP-55 checksummed address
+func (k *extendedPublicKey) evmAddress() string {
+        hash := keccak256(k.key.uncompressed()[1:])
+        return evmChecksumAddress(hex.EncodeToString(hash[12:]))
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+        "bytes"
+        "strings"
+        "testing"
+)
+
+func mustParseExtendedPublicKey(t *testing.T, s string) *extendedPublicKey {
+        t.Helper()
+        k, err := parseExtendedPublicKey(s)
+        if err != nil {
+                t.Fatalf("parse %s: %v", s, err)
+        }
+        return k
+}
+
+func TestExtendedPublicKeyChildBIP32Vectors(t *testing.T) {
+        tests := []struct {
+                name   string
+                parent string
+                index  uint32
+                want   string
+        }{
+                {
+                        name:   "vector 1 m/0H/1/2H/2",
+                        parent: "xpub6D4BDPcP2GT577Vvch3R8wDkScZWzQzMMUm3PWbmWvVJrZwQY4VUNgqFJPMM3No2dFDFGTsxxpG5uJh7n7epu4trkrX7x7DogT5Uv6fcLW5",
+                        index:  2,
+                        want:   "xpub6FHa3pjLCk84BayeJxFW2SP4XRrFd1JYnxeLeU8EqN3vDfZmbqBqaGJAyiLjTAwm6ZLRQUMv1ZACTj37sR62cfN7fe5JnJ7dh8zL4fiyLHV",
+                },
+                {
+                        name:   "vector 1 m/0H/1/2H/2/1000000000",
+                        parent: "xpub6FHa3pjLCk84BayeJxFW2SP4XRrFd1JYnxeLeU8EqN3vDfZmbqBqaGJAyiLjTAwm6ZLRQUMv1ZACTj37sR62cfN7fe5JnJ7dh8zL4fiyLHV",
+                        index:  1000000000,
+                        want:   "xpub6H1LXWLaKsWFhvm6RVpEL9P4KfRZSW7abD2ttkWP3SSQvnyA8FSVqNTEcYFgJS2UaFcxupHiYkro49S8yGasTvXEYBVPamhGW6cFJodrTHy",
+                },
+                {
+                        name:   "vector 2 m/0",
+                        parent: "xpub661MyMwAqRbcFW31YEwpkMuc5THy2PSt5bDMsktWQcFF8syAmRUapSCGu8ED9W6oDMSgv6Zz8idoc4a6mr8BDzTJY47LJhkJ8UB7WEGuduB",
+                        index:  0,
+                        want:   "xpub69H7F5d8KSRgmmdJg2KhpAK8SR3DjMwAdkxj3ZuxV27CprR9LgpeyGmXUbC6wb7ERfvrnKZjXoUmmDznezpbZb7ap6r1D3tgFxHmwMkQTPH",
+                },
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        parent := mustParseExtendedPublicKey(t, tt.parent)
+                        want := mustParseExtendedPublicKey(t, tt.want)
+
+                        got, err := parent.child(tt.index)
+                        if err != nil {
+                                t.Fatalf("child(%d): %v", tt.index, err)
+                        }
+                        if got.depth != want.depth || got.fingerprint != want.fingerprint || got.childNumber != want.childNumber {
+                                t.Errorf("header = depth %d fingerprint %08x child %d, want depth %d fingerprint %08x child %d",
+                                        got.depth, got.fingerprint, got.childNumber, want.depth, want.fingerprint, want.childNumber)
+                        }
+                        if !bytes.Equal(got.chainCode, want.chainCode) {
+                                t.Errorf("chain code = %x, want %x", got.chainCode, want.chainCode)
+                        }
+                        if !bytes.Equal(got.key.compressed(), want.key.compressed()) {
+                                t.Errorf("key = %x, want %x", got.key.compressed(), want.key.compressed())
+                        }
+                })
+        }
+}
+
+func TestExtendedPublicKeyChildRejectsHardenedIndex(t *testing.T) {
+        parent := mustParseExtendedPublicKey(t, "xpub661MyMwAqRbcFW31YEwpkMuc5THy2PSt5bDMsktWQcFF8syAmRUapSCGu8ED9W6oDMSgv6Zz8idoc4a6mr8BDzTJY47LJhkJ8UB7WEGuduB")
+        if _, err := parent.child(hardenedKeyStart); err == nil {
+                t.Error("derived a hardened child from a public key")
+        }
+}
+
+func TestDeriveDepositAddress(t *testing.T) {
+        tests := []struct {
+                name  string
+                xpub  string
+                chain string
+                index int64
+                want  string
+        }{
+                {
+                        // BIP44 account 0 of the "abandon ... about" mnemonic
+                        name:  "xpub P2PKH",
+                        xpub:  "xpub6BosfCnifzxcFwrSzQiqu2DBVTshkCXacvNsWGYJVVhhawA7d4R5WSWGFNbi8Aw6ZRc1brxMyWMzG3DSSSSoekkudhUd9yLb6qx39T9nMdj",
+                        chain: hdChainBTC,
+                        want:  "1LqBGSKuX5yYUonjxT5qGfpUsXKYYWeabA",
+                },
+                {
+                        // BIP84 test vector
+                        name:  "zpub bech32 index 0",
+                        xpub:  "zpub6rFR7y4Q2AijBEqTUquhVz398htDFrtymD9xYYfG1m4wAcvPhXNfE3EfH1r1ADqtfSdVCToUG868RvUUkgDKf31mGDtKsAYz2oz2AGutZYs",
+                        chain: hdChainBTC,
+                        want:  "bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu",
+                },
+                {
+                        name:  "zpub bech32 index 1",
+                        xpub:  "zpub6rFR7y4Q2AijBEqTUquhVz398htDFrtymD9xYYfG1m4wAcvPhXNfE3EfH1r1ADqtfSdVCToUG868RvUUkgDKf31mGDtKsAYz2oz2AGutZYs",
+                        chain: hdChainBTC,
+                        index: 1,
+                        want:  "bc1qnjg0jd8228aq7egyzacy8cys3knf9xvrerkf9g",
+                },
+                {
+                        name:  "xpub EIP-55",
+                        xpub:  "xpub6BosfCnifzxcFwrSzQiqu2DBVTshkCXacvNsWGYJVVhhawA7d4R5WSWGFNbi8Aw6ZRc1brxMyWMzG3DSSSSoekkudhUd9yLb6qx39T9nMdj",
+                        chain: hdChainEVM,
+                        want:  "0x030821f53C79461511bCf64367eCc9D013060408",
+                },
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        account := mustParseExtendedPublicKey(t, tt.xpub)
+                        got, index, err := deriveDepositAddress(account, tt.chain, tt.index)
+                        if err != nil {
+                                t.Fatalf("deriveDepositAddress: %v", err)
+                        }
+                        if got != tt.want || index != tt.index {
+                                t.Errorf("got %s at index %d, want %s at index %d", got, index, tt.want, tt.index)
+                        }
+                })
+        }
+}
+
+func TestEVMAddressOfGenerator(t *testing.T) {
+        // The public key of private key 1 is the generator point
+        k := &extendedPublicKey{key: secp256k1G}
+        if got, want := k.evmAddress(), "0x7E5F4552091A69125d5DfCb7b8C2659029395Bdf"; got != want {
+                t.Errorf("evmAddress = %s, want %s", got, want)
+        }
+}
+
+func TestDeriveDepositAddressSkipsInvalidIndex(t *testing.T) {
+        const xpub = "zpub6rFR7y4Q2AijBEqTUquhVz398htDFrtymD9xYYfG1m4wAcvPhXNfE3EfH1r1ADqtfSdVCToUG868RvUUkgDKf31mGDtKsAYz2oz2AGutZYs"
+        account := mustParseExtendedPublicKey(t, xpub)
+
+        defer func(orig func(*extendedPublicKey, uint32) (*extendedPublicKey, error)) {
+                deriveChildKey = orig
+        }(deriveChildKey)
+        deriveChildKey = func(k *extendedPublicKey, index uint32) (*extendedPublicKey, error) {
+                if index == 0 {
+                        return nil, errInvalidChildKey
+                }
+                return k.child(index)
+        }
+
+        got, index, err := deriveDepositAddress(account, hdChainBTC, 0)
+        if err != nil {
+                t.Fatalf("deriveDepositAddress: %v", err)
+        }
+        if want := "bc1qnjg0jd8228aq7egyzacy8cys3knf9xvrerkf9g"; got != want || index != 1 {
+                t.Errorf("got %s at index %d, want %s at index 1", got, index, want)
+        }
+}
+
+func TestCheckDepositXpub(t *testing.T) {
+        tests := []struct {
+                name    string
+                chain   string
+                value   string
+                wantErr string
+        }{
+                {
+                        name:  "account xpub",
+                        chain: hdChainEVM,
+                        value: "xpub6BosfCnifzxcFwrSzQiqu2DBVTshkCXacvNsWGYJVVhhawA7d4R5WSWGFNbi8Aw6ZRc1brxMyWMzG3DSSSSoekkudhUd9yLb6qx39T9nMdj",
+                },
+                {
+                        name:  "account zpub",
+                        chain: hdChainBTC,
+                        value: "zpub6rFR7y4Q2AijBEqTUquhVz398htDFrtymD9xYYfG1m4wAcvPhXNfE3EfH1r1ADqtfSdVCToUG868RvUUkgDKf31mGDtKsAYz2oz2AGutZYs",
+                },
+                {
+                        name:    "xprv",
+                        chain:   hdChainBTC,
+                        value:   "xprv9s21ZrQH143K3QTDL4LXw2F7HEK3wJUD2nW2nRk4stbPy6cq3jPPqjiChkVvvNKmPGJxWUtg6LnF5kejMRNNU3TGtRBeJgk33yuGBxrMPHi",
+                        wantErr: "private key",
+                },
+                {
+                        name:    "master key",
+                        chain:   hdChainBTC,
+                        value:   "xpub661MyMwAqRbcFW31YEwpkMuc5THy2PSt5bDMsktWQcFF8syAmRUapSCGu8ED9W6oDMSgv6Zz8idoc4a6mr8BDzTJY47LJhkJ8UB7WEGuduB",
+                        wantErr: "depth",
+                },
+                {
+                        name:    "depth 5 key",
+                        chain:   hdChainBTC,
+                        value:   "xpub6FHa3pjLCk84BayeJxFW2SP4XRrFd1JYnxeLeU8EqN3vDfZmbqBqaGJAyiLjTAwm6ZLRQUMv1ZACTj37sR62cfN7fe5JnJ7dh8zL4fiyLHV",
+                        wantErr: "depth",
+                },
+                {
+                        name:    "zpub for EVM",
+                        chain:   hdChainEVM,
+                        value:   "zpub6rFR7y4Q2AijBEqTUquhVz398htDFrtymD9xYYfG1m4wAcvPhXNfE3EfH1r1ADqtfSdVCToUG868RvUUkgDKf31mGDtKsAYz2oz2AGutZYs",
+                        wantErr: "xpub",
+                },
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        err := checkDepositXpub(tt.chain)(tt.value)
+                        if tt.wantErr == "" {
+                                if err != nil {
+                                        t.Errorf("rejected: %v", err)
+                                }
+                                return
+                        }
+                        if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+                                t.Errorf("err = %v, want it to mention %q", err, tt.wantErr)
+                        }
+                })
+        }
+}
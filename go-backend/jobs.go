@@ -0,0 +1,495 @@
+package main
+
+import (
+        "context"
+        "errors"
+        "fmt"
+        "log"
+        "net/http"
+        "os"
+        "sort"
+        "strconv"
+        "sync"
+        "time"
+
+        "github.com/go-chi/chi/v5"
+        "github.com/jackc/pgx/v4"
+)
+
+// Job run statuses stored in job_runs.status
+const (
+        jobStatusRunning   = "running"
+        jobStatusSucceeded = "succeeded"
+        jobStatusFailed    = "failed"
+)
+
+// Job run triggers stored in job_runs.trigger
+const (
+        jobTriggerSchedule = "schedule"
+        jobTriggerCatchUp  = "catchup"
+        jobTriggerManual   = "manual"
+
+        // jobTriggerSimulation marks runs driven by the simulated clock
+        jobTriggerSimulation = "simulation"
+)
+
+const (
+        // jobPollInterval is how often the runner checks for due jobs
+        jobPollInterval = 15 * time.Second
+
+        // maxCatchUpRuns caps how many missed runs a job replays after downtime
+        maxCatchUpRuns = 288
+
+        // jobRunStaleAfter is how long a run may stay running before it is
+        // assumed to have been interrupted by a crash
+        jobRunStaleAfter = time.Hour
+
+        settingJobRunRetention = "jobRunRetentionDays"
+
+        defaultJobRunRetentionDays = 30
+)
+
+// errJobLocked is returned when another instance is running the job
+var errJobLocked = errors.New("job is running on another instance")
+
+// Job is a task the runner executes on a cron schedule
+type Job struct {
+        Name        string
+        Schedule    string
+        Description string
+
+        // CatchUp replays every run missed while no instance was up; otherwise
+        // only the most recent missed run is executed
+        CatchUp bool
+
+        Run func(ctx context.Context, scheduledFor time.Time) error
+
+        schedule cronSchedule
+}
+
+// JobRun represents the job_runs table
+type JobRun struct {
+        ID           string     `json:"id" db:"id"`
+        JobName      string     `json:"jobName" db:"job_name"`
+        ScheduledFor time.Time  `json:"scheduledFor" db:"scheduled_for"`
+        Trigger      string     `json:"trigger" db:"trigger"`
+        Status       string     `json:"status" db:"status"`
+        Instance     string     `json:"instance" db:"instance"`
+        StartedAt    time.Time  `json:"startedAt" db:"started_at"`
+        FinishedAt   *time.Time `json:"finishedAt" db:"finished_at"`
+        DurationMs   *int64     `json:"durationMs" db:"duration_ms"`
+        Error        *string    `json:"error" db:"error"`
+}
+
+// JobRunner runs jobs on their schedules. A Postgres advisory lock ensures
+// only one instance runs a job at a time, and the unique (job_name,
+// scheduled_for) key on job_runs ensures each scheduled run happens once.
+type JobRunner struct {
+        jobs     []*Job
+        byName   map[string]*Job
+        instance string
+        started  time.Time
+
+        // executeRun runs and records one run of a job; tests replace it to
+        // run jobs without a database
+        executeRun func(ctx context.Context, job *Job, scheduledFor time.Time, trigger string) (*JobRun, error)
+
+        mu   sync.Mutex
+        last map[string]time.Time
+}
+
+// jobRunner is the process-wide runner, used by the admin endpoints
+var jobRunner *JobRunner
+
+// newJobRunner validates the jobs' schedules and builds a runner
+func newJobRunner(jobs []*Job) (*JobRunner, error) {
+        hostname, _ := os.Hostname()
+        runner := &JobRunner{
+                byName:   make(map[string]*Job, len(jobs)),
+                instance: fmt.Sprintf("%s:%d", hostname, os.Getpid()),
+                started:  clock.Now(),
+                last:     make(map[string]time.Time),
+        }
+        runner.executeRun = runner.execute
+        for _, job := range jobs {
+                schedule, err := parseCronSchedule(job.Schedule)
+                if err != nil {
+                        return nil, fmt.Errorf("job %s: %w", job.Name, err)
+                }
+                if _, ok := runner.byName[job.Name]; ok {
+                        return nil, fmt.Errorf("job %s is registered twice", job.Name)
+                }
+                job.schedule = schedule
+                runner.jobs = append(runner.jobs, job)
+                runner.byName[job.Name] = job
+        }
+        return runner, nil
+}
+
+// Start runs every job on its own goroutine until ctx is cancelled
+func (r *JobRunner) Start(ctx context.Context) {
+        if _, err := db.Exec(ctx, `
+                UPDATE job_runs
+                SET status = $1, error = 'interrupted', finished_at = $4
+                WHERE status = $2 AND started_at < $3`,
+                jobStatusFailed, jobStatusRunning, clock.Now().Add(-jobRunStaleAfter), clock.Now()); err != nil {
+                log.Printf("Failed to clean up interrupted job runs: %v", err)
+        }
+
+        for _, job := range r.jobs {
+                go r.loop(ctx, job)
+        }
+}
+
+func (r *JobRunner) loop(ctx context.Context, job *Job) {
+        ticker := time.NewTicker(jobPollInterval)
+        defer ticker.Stop()
+
+        for {
+                r.runDue(ctx, job, clock.Now())
+
+                select {
+                case <-ctx.Done():
+                        return
+                case <-ticker.C:
+                }
+        }
+}
+
+// lastScheduled returns the scheduled time of the job's latest scheduled run.
+// A job that has never run starts from when this runner started, so the
+// first deploy does not replay history.
+func (r *JobRunner) lastScheduled(ctx context.Context, job *Job) (time.Time, error) {
+        r.mu.Lock()
+        last, ok := r.last[job.Name]
+        r.mu.Unlock()
+        if ok {
+                return last, nil
+        }
+
+        var latest *time.Time
+        err := db.QueryRow(ctx, `
+                SELECT MAX(scheduled_for) FROM job_runs
+                WHERE job_name = $1 AND trigger IN ($2, $3)`,
+                job.Name, jobTriggerSchedule, jobTriggerCatchUp).Scan(&latest)
+        if err != nil {
+                return time.Time{}, fmt.Errorf("failed to get last run of %s: %w", job.Name, err)
+        }
+        if latest == nil {
+                return r.started, nil
+        }
+        return latest.UTC(), nil
+}
+
+func (r *JobRunner) setLastScheduled(job *Job, t time.Time) {
+        r.mu.Lock()
+        r.last[job.Name] = t
+        r.mu.Unlock()
+}
+
+// runDue executes the runs of job that have come due since its last
+// scheduled run
+func (r *JobRunner) runDue(ctx context.Context, job *Job, now time.Time) {
+        last, err := r.lastScheduled(ctx, job)
+        if err != nil {
+                log.Printf("Job runner: %v", err)
+                return
+        }
+
+        var due []time.Time
+        for t := job.schedule.Next(last); !t.IsZero() && !t.After(now); t = job.schedule.Next(t) {
+                due = append(due, t)
+                if len(due) > maxCatchUpRuns {
+                        due = due[1:]
+                }
+        }
+        if len(due) == 0 {
+                return
+        }
+        if !job.CatchUp {
+                due = due[len(due)-1:]
+        }
+
+        for i, scheduledFor := range due {
+                trigger := jobTriggerCatchUp
+                if i == len(due)-1 {
+                        trigger = jobTriggerSchedule
+                }
+
+                _, err := r.executeRun(ctx, job, scheduledFor, trigger)
+                if errors.Is(err, errJobLocked) {
+                        // Another instance holds the job; re-read its progress next tick
+                        r.mu.Lock()
+                        delete(r.last, job.Name)
+                        r.mu.Unlock()
+                        return
+                }
+                if err != nil {
+                        log.Printf("Job %s could not start: %v", job.Name, err)
+                        return
+                }
+                r.setLastScheduled(job, scheduledFor)
+        }
+}
+
+// execute runs job once under its advisory lock and records the run in
+// job_runs. It returns a nil run without error if the scheduled run has
+// already been executed.
+func (r *JobRunner) execute(ctx context.Context, job *Job, scheduledFor time.Time, trigger string) (*JobRun, error) {
+        // The transaction only holds the advisory lock; it is released on rollback
+        lockTx, err := db.Begin(ctx)
+        if err != nil {
+                return nil, fmt.Errorf("failed to begin transaction: %w", err)
+        }
+        defer lockTx.Rollback(ctx)
+
+        var locked bool
+        if err := lockTx.QueryRow(ctx, "SELECT pg_try_advisory_xact_lock(hashtext($1))", "job:"+job.Name).Scan(&locked); err != nil {
+                return nil, fmt.Errorf("failed to lock job: %w", err)
+        }
+        if !locked {
+                return nil, errJobLocked
+        }
+
+        run := JobRun{
+                JobName:      job.Name,
+                ScheduledFor: scheduledFor,
+                Trigger:      trigger,
+                Status:       jobStatusRunning,
+                Instance:     r.instance,
+                StartedAt:    clock.Now(),
+        }
+        err = db.QueryRow(ctx, `
+                INSERT INTO job_runs (job_name, scheduled_for, trigger, status, instance, started_at)
+                VALUES ($1, $2, $3, $4, $5, $6)
+                ON CONFLICT (job_name, scheduled_for) DO NOTHING
+                RETURNING id`,
+                run.JobName, run.ScheduledFor, run.Trigger, run.Status, run.Instance, run.StartedAt,
+        ).Scan(&run.ID)
+        if err == pgx.ErrNoRows {
+                return nil, nil
+        }
+        if err != nil {
+                return nil, fmt.Errorf("failed to record job run: %w", err)
+        }
+
+        runErr := job.Run(ctx, scheduledFor)
+
+        finished := clock.Now()
+        duration := finished.Sub(run.StartedAt).Milliseconds()
+        run.FinishedAt = &finished
+        run.DurationMs = &duration
+        run.Status = jobStatusSucceeded
+        if runErr != nil {
+                message := runErr.Error()
+                run.Status = jobStatusFailed
+                run.Error = &message
+                log.Printf("Job %s failed: %v", job.Name, runErr)
+        }
+
+        // Record the outcome even if the runner is shutting down
+        _, err = db.Exec(context.WithoutCancel(ctx), `
+                UPDATE job_runs
+                SET status = $1, finished_at = $2, duration_ms = $3, error = $4
+                WHERE id = $5`,
+                run.Status, finished, duration, run.Error, run.ID)
+        if err != nil {
+                log.Printf("Failed to record outcome of job %s: %v", job.Name, err)
+        }
+        return &run, nil
+}
+
+// pruneJobRuns deletes finished job runs older than the retention period.
+// Each job's latest run is kept, since the runner resumes its schedule from it.
+func pruneJobRuns(ctx context.Context, now time.Time) (int64, error) {
+        days, err := getIntSetting(ctx, db, settingJobRunRetention, defaultJobRunRetentionDays)
+        if err != nil {
+                return 0, err
+        }
+
+        tag, err := db.Exec(ctx, `
+                DELETE FROM job_runs r
+                WHERE r.status <> $1 AND r.started_at < $2
+                  AND r.scheduled_for < (SELECT MAX(scheduled_for) FROM job_runs l WHERE l.job_name = r.job_name)`,
+                jobStatusRunning, now.AddDate(0, 0, -int(days)))
+        if err != nil {
+                return 0, fmt.Errorf("failed to prune job runs: %w", err)
+        }
+        return tag.RowsAffected(), nil
+}
+
+// runDailyReset is the scheduled job resetting the daily block number and
+// pruning old job runs and block participation
+func runDailyReset(ctx context.Context, scheduledFor time.Time) error {
+        if err := dailyReset(ctx, scheduledFor); err != nil {
+                return err
+        }
+        now := clock.Now()
+        pruned, err := pruneJobRuns(ctx, now)
+        if err != nil {
+                return err
+        }
+        if pruned > 0 {
+                log.Printf("Pruned %d old job runs", pruned)
+        }
+        pruned, err = pruneBlockParticipation(ctx, now)
+        if err != nil {
+                return err
+        }
+        if pruned > 0 {
+                log.Printf("Pruned %d old block participation rows", pruned)
+        }
+        return nil
+}
+
+// defaultJobs lists the jobs the backend runs. The BTC price job is only
+// registered when a poll interval is configured.
+func defaultJobs(priceSource PriceSource, pricePollInterval time.Duration) []*Job {
+        jobs := []*Job{
+                {
+                        Name:        "block",
+                        Schedule:    "*/10 * * * *",
+                        Description: "Mine a block, distribute its reward and expire unclaimed rewards",
+                        CatchUp:     true,
+                        Run:         mineBlock,
+                },
+                {
+                        Name:        "daily-reset",
+                        Schedule:    "0 0 * * *",
+                        Description: "Reset the daily block number at midnight UTC and prune old job runs",
+                        Run:         runDailyReset,
+                },
+                {
+                        Name:        "staking-payout",
+                        Schedule:    "0 0 * * *",
+                        Description: "Pay due BTC staking rewards and unlock matured stakes",
+                        Run:         runStakingPayouts,
+                },
+                {
+                        Name:        "contract-expiry",
+                        Schedule:    "* * * * *",
+                        Description: "Renew or expire rental hash power contracts past their expiry",
+                        Run:         runContractExpiry,
+                },
+        }
+
+        if pricePollInterval > 0 {
+                jobs = append(jobs, &Job{
+                        Name:        "btc-price",
+                        Schedule:    "@every " + pricePollInterval.String(),
+                        Description: "Fetch the BTC price from " + priceSource.Name(),
+                        Run: func(ctx context.Context, _ time.Time) error {
+                                _, err := updatePriceFrom(ctx, priceSource)
+                                return err
+                        },
+                })
+        }
+        return jobs
+}
+
+// latestJobRun returns the job's most recent run, or nil if it has never run
+func latestJobRun(ctx context.Context, jobName string) (*JobRun, error) {
+        runs, err := listJobRuns(ctx, jobName, 1)
+        if err != nil || len(runs) == 0 {
+                return nil, err
+        }
+        return runs[0], nil
+}
+
+func listJobRuns(ctx context.Context, jobName string, limit int) ([]*JobRun, error) {
+        rows, err := db.Query(ctx, `
+                SELECT id, job_name, scheduled_for, trigger, status, instance, started_at, finished_at, duration_ms, error
+                FROM job_runs
+                WHERE job_name = $1
+                ORDER BY started_at DESC
+                LIMIT $2`, jobName, limit)
+        if err != nil {
+                return nil, fmt.Errorf("failed to get job runs: %w", err)
+        }
+        defer rows.Close()
+
+        runs := make([]*JobRun, 0)
+        for rows.Next() {
+                var run JobRun
+                if err := rows.Scan(&run.ID, &run.JobName, &run.ScheduledFor, &run.Trigger, &run.Status, &run.Instance,
+                        &run.StartedAt, &run.FinishedAt, &run.DurationMs, &run.Error); err != nil {
+                        return nil, fmt.Errorf("failed to scan job run: %w", err)
+                }
+                runs = append(runs, &run)
+        }
+        return runs, rows.Err()
+}
+
+// Admin job list endpoint
+func handleAdminListJobs(w http.ResponseWriter, r *http.Request) {
+        now := clock.Now()
+        jobs := make([]map[string]interface{}, 0, len(jobRunner.jobs))
+        for _, job := range jobRunner.jobs {
+                lastRun, err := latestJobRun(r.Context(), job.Name)
+                if err != nil {
+                        writeErrorResponse(w, r, ErrCodeInternal, "Failed to load jobs")
+                        return
+                }
+                jobs = append(jobs, map[string]interface{}{
+                        "name":        job.Name,
+                        "schedule":    job.Schedule,
+                        "description": job.Description,
+                        "catchUp":     job.CatchUp,
+                        "nextRunAt":   job.schedule.Next(now),
+                        "lastRun":     lastRun,
+                })
+        }
+        sort.Slice(jobs, func(i, j int) bool { return jobs[i]["name"].(string) < jobs[j]["name"].(string) })
+
+        writeJSONResponse(w, http.StatusOK, map[string]interface{}{"jobs": jobs})
+}
+
+// Admin job history endpoint
+func handleAdminJobRuns(w http.ResponseWriter, r *http.Request) {
+        job, ok := jobRunner.byName[chi.URLParam(r, "name")]
+        if !ok {
+                writeErrorResponse(w, r, ErrCodeNotFound, "Job not found")
+                return
+        }
+
+        limit := 50
+        if v := r.URL.Query().Get("limit"); v != "" {
+                n, err := strconv.Atoi(v)
+                if err != nil || n < 1 || n > 500 {
+                        writeErrorResponse(w, r, ErrCodeValidation, "limit must be between 1 and 500")
+                        return
+                }
+                limit = n
+        }
+
+        runs, err := listJobRuns(r.Context(), job.Name, limit)
+        if err != nil {
+                writeErrorResponse(w, r, ErrCodeInternal, "Failed to load job runs")
+                return
+        }
+
+        writeJSONResponse(w, http.StatusOK, map[string]interface{}{"runs": runs})
+}
+
+// Admin manual job trigger endpoint. The job runs synchronously and the
+// response reports its outcome.
+func handleAdminRunJob(w http.ResponseWriter, r *http.Request) {
+        job, ok := jobRunner.byName[chi.URLParam(r, "name")]
+        if !ok {
+                writeErrorResponse(w, r, ErrCodeNotFound, "Job not found")
+                return
+        }
+
+        // Keep running if the admin disconnects; the outcome is in job_runs
+        run, err := jobRunner.executeRun(context.WithoutCancel(r.Context()), job, clock.Now(), jobTriggerManual)
+        if errors.Is(err, errJobLocked) {
+                writeErrorResponse(w, r, ErrCodeConflict, "Job is already running")
+                return
+        }
+        if err != nil || run == nil {
+                writeErrorResponse(w, r, ErrCodeInternal, "Failed to run job")
+                return
+        }
+
+        writeJSONResponse(w, http.StatusOK, map[string]interface{}{"run": run})
+}
@@ -0,0 +1,709 @@
+package main
+
+import (
+        "bytes"
+        "context"
+        "crypto/sha256"
+        "encoding/hex"
+        "errors"
+        "fmt"
+        "io"
+        "log"
+        "mime/multipart"
+        "net/http"
+        "sort"
+        "strings"
+        "time"
+
+        "github.com/go-chi/chi/v5"
+        "github.com/jackc/pgx/v4"
+)
+
+// KYC tiers. Basic verification needs an identity document; advanced also
+// needs a selfie holding it.
+const (
+        kycTierNone     = 0
+        kycTierBasic    = 1
+        kycTierAdvanced = 2
+)
+
+// kycTierNames are the tier names used in settings and API responses
+var kycTierNames = []string{"unverified", "basic", "advanced"}
+
+const (
+        kycStatusPending  = "pending"
+        kycStatusApproved = "approved"
+        kycStatusRejected = "rejected"
+)
+
+const (
+        maxKYCFileBytes    = 10 << 20
+        maxKYCRequestBytes = 32 << 20
+)
+
+// kycContentTypes lists the document formats accepted for upload
+var kycContentTypes = map[string]bool{
+        "image/jpeg":      true,
+        "image/png":       true,
+        "application/pdf": true,
+}
+
+// objectStorage holds uploaded KYC documents
+var objectStorage ObjectStorage
+
+// kycTierName returns the name of a KYC tier
+func kycTierName(tier int) string {
+        if tier < 0 || tier >= len(kycTierNames) {
+                return fmt.Sprintf("tier%d", tier)
+        }
+        return kycTierNames[tier]
+}
+
+// effectiveKYCTier treats users verified before tiers existed as basic
+func effectiveKYCTier(tier int, kycVerified bool) int {
+        if kycVerified && tier < kycTierBasic {
+                return kycTierBasic
+        }
+        return tier
+}
+
+// kycTier returns the user's effective KYC tier
+func (u *User) kycTier() int {
+        return effectiveKYCTier(u.KYCTier, u.KYCVerified)
+}
+
+// policyForTier returns the policy configured for a tier, falling back to
+// the closest lower tier that has one
+func policyForTier[T any](policies map[string]T, tier int) (T, bool) {
+        for t := tier; t >= 0; t-- {
+                if policy, ok := policies[kycTierName(t)]; ok {
+                        return policy, true
+                }
+        }
+        var zero T
+        return zero, false
+}
+
+// KYCSubmission represents the kyc_submissions table
+type KYCSubmission struct {
+        ID                 string         `json:"id" db:"id"`
+        UserID             string         `json:"userId" db:"user_id"`
+        Tier               int            `json:"tier" db:"tier"`
+        FullName           string         `json:"fullName" db:"full_name"`
+        DateOfBirth        time.Time      `json:"dateOfBirth" db:"date_of_birth"`
+        Country            string         `json:"country" db:"country"`
+        DocumentType       string         `json:"documentType" db:"document_type"`
+        DocumentNumberHash string         `json:"documentNumberHash" db:"document_number_hash"`
+        DocumentLast4      string         `json:"documentLast4" db:"document_last4"`
+        Status             string         `json:"status" db:"status"`
+        RejectionReason    *string        `json:"rejectionReason" db:"rejection_reason"`
+        ReviewedBy         *string        `json:"reviewedBy" db:"reviewed_by"`
+        ReviewedAt         *time.Time     `json:"reviewedAt" db:"reviewed_at"`
+        CreatedAt          time.Time      `json:"createdAt" db:"created_at"`
+        Documents          []*KYCDocument `json:"documents"`
+}
+
+// KYCDocument represents the kyc_documents table
+type KYCDocument struct {
+        ID           string    `json:"id" db:"id"`
+        SubmissionID string    `json:"submissionId" db:"submission_id"`
+        Kind         string    `json:"kind" db:"kind"`
+        FileName     string    `json:"fileName" db:"file_name"`
+        ContentType  string    `json:"contentType" db:"content_type"`
+        SizeBytes    int       `json:"sizeBytes" db:"size_bytes"`
+        SHA256       string    `json:"sha256" db:"sha256"`
+        StorageKey   string    `json:"-" db:"storage_key"`
+        CreatedAt    time.Time `json:"createdAt" db:"created_at"`
+}
+
+// KYCSubmissionRequest holds the form fields of a KYC submission
+type KYCSubmissionRequest struct {
+        Tier           string `json:"tier" validate:"required,oneof=basic advanced"`
+        FullName       string `json:"fullName" validate:"required,min=2,max=100"`
+        DateOfBirth    string `json:"dateOfBirth" validate:"required,max=10"`
+        Country        string `json:"country" validate:"required,min=2,max=2"`
+        DocumentType   string `json:"documentType" validate:"required,oneof=passport national_id drivers_license"`
+        DocumentNumber string `json:"documentNumber" validate:"required,min=4,max=32"`
+}
+
+type KYCRejectRequest struct {
+        Reason string `json:"reason" validate:"required,max=500"`
+}
+
+// kycUpload is a document file read from a submission form
+type kycUpload struct {
+        Kind        string
+        FileName    string
+        ContentType string
+        Data        []byte
+        SHA256      string
+}
+
+// readKYCUpload reads a form file, checking its size and format. It returns
+// nil if the field is absent.
+func readKYCUpload(r *http.Request, kind string) (*kycUpload, error) {
+        file, header, err := r.FormFile(kind)
+        if err == http.ErrMissingFile {
+                return nil, nil
+        }
+        if err != nil {
+                return nil, newAPIError(ErrCodeBadRequest, "Invalid "+kind+" upload")
+        }
+        defer file.Close()
+
+        data, err := io.ReadAll(io.LimitReader(file, maxKYCFileBytes+1))
+        if err != nil {
+                return nil, newAPIError(ErrCodeBadRequest, "Invalid "+kind+" upload")
+        }
+        if len(data) > maxKYCFileBytes {
+                return nil, newAPIError(ErrCodeValidation, fmt.Sprintf("%s must be at most %d MB", kind, maxKYCFileBytes>>20))
+        }
+        if len(data) == 0 {
+                return nil, newAPIError(ErrCodeValidation, kind+" is empty")
+        }
+
+        // Trust the file contents rather than the client-supplied content type
+        contentType, _, _ := strings.Cut(http.DetectContentType(data), ";")
+        if !kycContentTypes[contentType] {
+                return nil, newAPIError(ErrCodeValidation, kind+" must be a JPEG, PNG or PDF file")
+        }
+
+        sum := sha256.Sum256(data)
+        return &kycUpload{
+                Kind:        kind,
+                FileName:    uploadFileName(header),
+                ContentType: contentType,
+                Data:        data,
+                SHA256:      hex.EncodeToString(sum[:]),
+        }, nil
+}
+
+// uploadFileName returns the base name a client gave an uploaded file
+func uploadFileName(header *multipart.FileHeader) string {
+        name := header.Filename
+        if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+                name = name[i+1:]
+        }
+        if len(name) > 255 {
+                name = name[:255]
+        }
+        return name
+}
+
+// hashDocumentNumber fingerprints a document number so reuse across
+// accounts can be detected without storing the number itself
+func hashDocumentNumber(documentType, number string) string {
+        normalized := strings.ToUpper(strings.Join(strings.Fields(number), ""))
+        sum := sha256.Sum256([]byte(documentType + ":" + normalized))
+        return hex.EncodeToString(sum[:])
+}
+
+// kycVerificationHash commits to the approved submission and the exact
+// documents it was approved with
+func kycVerificationHash(submissionID string, documents []*KYCDocument) string {
+        hashes := make([]string, 0, len(documents))
+        for _, doc := range documents {
+                hashes = append(hashes, doc.SHA256)
+        }
+        sort.Strings(hashes)
+        sum := sha256.Sum256([]byte(submissionID + ":" + strings.Join(hashes, ":")))
+        return hex.EncodeToString(sum[:])
+}
+
+// createKYCSubmission records a pending submission for review and stores its
+// uploaded documents
+func createKYCSubmission(ctx context.Context, user *User, req KYCSubmissionRequest, dateOfBirth time.Time, uploads []*kycUpload) (*KYCSubmission, error) {
+        tier := kycTierBasic
+        if req.Tier == "advanced" {
+                tier = kycTierAdvanced
+        }
+        if user.kycTier() >= tier {
+                return nil, newAPIError(ErrCodeInvalidState, "Your account is already verified at this tier")
+        }
+
+        tx, err := db.Begin(ctx)
+        if err != nil {
+                return nil, fmt.Errorf("failed to begin transaction: %w", err)
+        }
+        defer tx.Rollback(ctx)
+
+        // Lock the user so two submissions cannot both pass the pending check
+        if _, err := tx.Exec(ctx, "SELECT id FROM users WHERE id = $1 FOR UPDATE", user.ID); err != nil {
+                return nil, fmt.Errorf("failed to lock user: %w", err)
+        }
+
+        var pending bool
+        err = tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM kyc_submissions WHERE user_id = $1 AND status = $2)",
+                user.ID, kycStatusPending).Scan(&pending)
+        if err != nil {
+                return nil, fmt.Errorf("failed to check pending submissions: %w", err)
+        }
+        if pending {
+                return nil, newAPIError(ErrCodeConflict, "A KYC submission is already awaiting review")
+        }
+
+        // Store the documents only once the submission can be accepted. Keys
+        // are content addressed, so a retry after a failed insert reuses them.
+        for _, upload := range uploads {
+                key := fmt.Sprintf("kyc/%s/%s", user.ID, upload.SHA256)
+                if err := objectStorage.Put(ctx, key, bytes.NewReader(upload.Data)); err != nil {
+                        return nil, fmt.Errorf("failed to store %s: %w", upload.Kind, err)
+                }
+        }
+
+        now := clock.Now()
+        number := strings.ToUpper(strings.Join(strings.Fields(req.DocumentNumber), ""))
+        submission := KYCSubmission{
+                UserID:             user.ID,
+                Tier:               tier,
+                FullName:           strings.TrimSpace(req.FullName),
+                DateOfBirth:        dateOfBirth,
+                Country:            strings.ToUpper(req.Country),
+                DocumentType:       req.DocumentType,
+                DocumentNumberHash: hashDocumentNumber(req.DocumentType, req.DocumentNumber),
+                DocumentLast4:      number[max(0, len(number)-4):],
+                Status:             kycStatusPending,
+        }
+        err = tx.QueryRow(ctx, `
+                INSERT INTO kyc_submissions (user_id, tier, full_name, date_of_birth, country, document_type,
+                                             document_number_hash, document_last4, status, created_at)
+                VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+                RETURNING id, created_at`,
+                submission.UserID, submission.Tier, submission.FullName, submission.DateOfBirth, submission.Country,
+                submission.DocumentType, submission.DocumentNumberHash, submission.DocumentLast4, submission.Status, now,
+        ).Scan(&submission.ID, &submission.CreatedAt)
+        if err != nil {
+                return nil, fmt.Errorf("failed to record submission: %w", err)
+        }
+
+        for _, upload := range uploads {
+                doc := KYCDocument{
+                        SubmissionID: submission.ID,
+                        Kind:         upload.Kind,
+                        FileName:     upload.FileName,
+                        ContentType:  upload.ContentType,
+                        SizeBytes:    len(upload.Data),
+                        SHA256:       upload.SHA256,
+                        StorageKey:   fmt.Sprintf("kyc/%s/%s", user.ID, upload.SHA256),
+                }
+                err = tx.QueryRow(ctx, `
+                        INSERT INTO kyc_documents (submission_id, kind, file_name, content_type, size_bytes, sha256, storage_key, created_at)
+                        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+                        RETURNING id, created_at`,
+                        doc.SubmissionID, doc.Kind, doc.FileName, doc.ContentType, doc.SizeBytes, doc.SHA256, doc.StorageKey, now,
+                ).Scan(&doc.ID, &doc.CreatedAt)
+                if err != nil {
+                        return nil, fmt.Errorf("failed to record document: %w", err)
+                }
+                submission.Documents = append(submission.Documents, &doc)
+        }
+
+        if err := tx.Commit(ctx); err != nil {
+                return nil, fmt.Errorf("failed to commit submission: %w", err)
+        }
+        return &submission, nil
+}
+
+// kycSubmissionColumns lists the kyc_submissions columns in the order scanKYCSubmission expects
+const kycSubmissionColumns = `
+        id, user_id, tier, full_name, date_of_birth, country, document_type, document_number_hash,
+        document_last4, status, rejection_reason, reviewed_by, reviewed_at, created_at`
+
+func scanKYCSubmission(row pgx.Row) (*KYCSubmission, error) {
+        var s KYCSubmission
+        err := row.Scan(&s.ID, &s.UserID, &s.Tier, &s.FullName, &s.DateOfBirth, &s.Country, &s.DocumentType,
+                &s.DocumentNumberHash, &s.DocumentLast4, &s.Status, &s.RejectionReason, &s.ReviewedBy, &s.ReviewedAt,
+                &s.CreatedAt)
+        if err != nil {
+                return nil, err
+        }
+        return &s, nil
+}
+
+// loadKYCDocuments attaches the documents of each submission
+func loadKYCDocuments(ctx context.Context, submissions []*KYCSubmission) error {
+        if len(submissions) == 0 {
+                return nil
+        }
+        byID := make(map[string]*KYCSubmission, len(submissions))
+        ids := make([]string, 0, len(submissions))
+        for _, s := range submissions {
+                byID[s.ID] = s
+                ids = append(ids, s.ID)
+        }
+
+        rows, err := db.Query(ctx, `
+                SELECT id, submission_id, kind, file_name, content_type, size_bytes, sha256, storage_key, created_at
+                FROM kyc_documents
+                WHERE submission_id = ANY($1::uuid[])
+                ORDER BY created_at ASC`, ids)
+        if err != nil {
+                return fmt.Errorf("failed to get KYC documents: %w", err)
+        }
+        defer rows.Close()
+
+        for rows.Next() {
+                var doc KYCDocument
+                if err := rows.Scan(&doc.ID, &doc.SubmissionID, &doc.Kind, &doc.FileName, &doc.ContentType,
+                        &doc.SizeBytes, &doc.SHA256, &doc.StorageKey, &doc.CreatedAt); err != nil {
+                        return fmt.Errorf("failed to scan KYC document: %w", err)
+                }
+                s := byID[doc.SubmissionID]
+                s.Documents = append(s.Documents, &doc)
+        }
+        return rows.Err()
+}
+
+// reviewKYCSubmission approves or rejects a pending submission. Approval
+// raises the user's KYC tier and records the verification hash.
+func reviewKYCSubmission(ctx context.Context, submissionID, reviewerID string, approve bool, reason string) (*KYCSubmission, error) {
+        tx, err := db.Begin(ctx)
+        if err != nil {
+                return nil, fmt.Errorf("failed to begin transaction: %w", err)
+        }
+        defer tx.Rollback(ctx)
+
+        submission, err := scanKYCSubmission(tx.QueryRow(ctx,
+                "SELECT "+kycSubmissionColumns+" FROM kyc_submissions WHERE id = $1 FOR UPDATE", submissionID))
+        if err == pgx.ErrNoRows {
+                return nil, newAPIError(ErrCodeNotFound, "KYC submission not found")
+        }
+        if err != nil {
+                return nil, fmt.Errorf("failed to lock submission: %w", err)
+        }
+        if submission.Status != kycStatusPending {
+                return nil, newAPIError(ErrCodeInvalidState, "KYC submission has already been reviewed")
+        }
+        if err := loadKYCDocuments(ctx, []*KYCSubmission{submission}); err != nil {
+                return nil, err
+        }
+
+        now := clock.Now()
+        submission.ReviewedBy = &reviewerID
+        submission.ReviewedAt = &now
+        if approve {
+                submission.Status = kycStatusApproved
+                hash := kycVerificationHash(submission.ID, submission.Documents)
+                _, err = tx.Exec(ctx, `
+                        UPDATE users
+                        SET kyc_verified = true,
+                            kyc_tier = GREATEST(kyc_tier, $1),
+                            kyc_verification_hash = $2
+                        WHERE id = $3`,
+                        submission.Tier, hash, submission.UserID)
+                if err != nil {
+                        return nil, fmt.Errorf("failed to verify user: %w", err)
+                }
+        } else {
+                submission.Status = kycStatusRejected
+                submission.RejectionReason = &reason
+        }
+
+        _, err = tx.Exec(ctx, `
+                UPDATE kyc_submissions
+                SET status = $1, rejection_reason = $2, reviewed_by = $3, reviewed_at = $4
+                WHERE id = $5`,
+                submission.Status, submission.RejectionReason, reviewerID, now, submission.ID)
+        if err != nil {
+                return nil, fmt.Errorf("failed to update submission: %w", err)
+        }
+
+        if err := tx.Commit(ctx); err != nil {
+                return nil, fmt.Errorf("failed to commit review: %w", err)
+        }
+        return submission, nil
+}
+
+func kycSubmissionResponse(s *KYCSubmission) map[string]interface{} {
+        documents := make([]map[string]interface{}, 0, len(s.Documents))
+        for _, doc := range s.Documents {
+                documents = append(documents, map[string]interface{}{
+                        "id":          doc.ID,
+                        "kind":        doc.Kind,
+                        "fileName":    doc.FileName,
+                        "contentType": doc.ContentType,
+                        "sizeBytes":   doc.SizeBytes,
+                        "sha256":      doc.SHA256,
+                })
+        }
+        return map[string]interface{}{
+                "id":              s.ID,
+                "userId":          s.UserID,
+                "tier":            kycTierName(s.Tier),
+                "fullName":        s.FullName,
+                "dateOfBirth":     s.DateOfBirth.Format("2006-01-02"),
+                "country":         s.Country,
+                "documentType":    s.DocumentType,
+                "documentLast4":   s.DocumentLast4,
+                "status":          s.Status,
+                "rejectionReason": s.RejectionReason,
+                "reviewedAt":      s.ReviewedAt,
+                "createdAt":       s.CreatedAt,
+                "documents":       documents,
+        }
+}
+
+// KYC submission endpoint. Expects multipart/form-data with the
+// KYCSubmissionRequest fields and documentFront, documentBack and selfie files.
+func handleSubmitKYC(w http.ResponseWriter, r *http.Request) {
+        user := getUserFromContext(r.Context())
+        if user == nil {
+                writeErrorResponse(w, r, ErrCodeUnauthorized, "Unauthorized")
+                return
+        }
+
+        r.Body = http.MaxBytesReader(w, r.Body, maxKYCRequestBytes)
+        if err := r.ParseMultipartForm(maxKYCFileBytes); err != nil {
+                var maxBytesErr *http.MaxBytesError
+                if errors.As(err, &maxBytesErr) {
+                        writeErrorResponse(w, r, ErrCodeBadRequest, "Request body too large")
+                        return
+                }
+                writeErrorResponse(w, r, ErrCodeBadRequest, "Request must be multipart/form-data")
+                return
+        }
+        defer r.MultipartForm.RemoveAll()
+
+        req := KYCSubmissionRequest{
+                Tier:           r.FormValue("tier"),
+                FullName:       r.FormValue("fullName"),
+                DateOfBirth:    r.FormValue("dateOfBirth"),
+                Country:        r.FormValue("country"),
+                DocumentType:   r.FormValue("documentType"),
+                DocumentNumber: r.FormValue("documentNumber"),
+        }
+        if err := validateStruct(&req); err != nil {
+                writeAPIError(w, r, err)
+                return
+        }
+
+        dateOfBirth, err := time.Parse("2006-01-02", req.DateOfBirth)
+        if err != nil || dateOfBirth.After(clock.Now().AddDate(-18, 0, 0)) {
+                writeAPIError(w, r, &APIError{
+                        Code:    ErrCodeValidation,
+                        Message: "Validation failed",
+                        Fields:  []FieldError{{Field: "dateOfBirth", Message: "must be a YYYY-MM-DD date at least 18 years ago"}},
+                })
+                return
+        }
+
+        kinds := []string{"documentFront", "documentBack", "selfie"}
+        required := map[string]bool{"documentFront": true, "selfie": req.Tier == "advanced"}
+        var uploads []*kycUpload
+        for _, kind := range kinds {
+                upload, err := readKYCUpload(r, kind)
+                if err != nil {
+                        writeAPIError(w, r, err)
+                        return
+                }
+                if upload == nil {
+                        if required[kind] {
+                                writeAPIError(w, r, &APIError{
+                                        Code:    ErrCodeValidation,
+                                        Message: "Validation failed",
+                                        Fields:  []FieldError{{Field: kind, Message: "is required"}},
+                                })
+                                return
+                        }
+                        continue
+                }
+                uploads = append(uploads, upload)
+        }
+
+        submission, err := createKYCSubmission(r.Context(), user, req, dateOfBirth, uploads)
+        if err != nil {
+                var apiErr *APIError
+                if !errors.As(err, &apiErr) {
+                        log.Printf("KYC submission for user %s failed: %v", user.ID, err)
+                }
+                writeAPIError(w, r, err)
+                return
+        }
+
+        writeJSONResponse(w, http.StatusCreated, kycSubmissionResponse(submission))
+}
+
+// KYC status endpoint
+func handleGetKYC(w http.ResponseWriter, r *http.Request) {
+        user := getUserFromContext(r.Context())
+        if user == nil {
+                writeErrorResponse(w, r, ErrCodeUnauthorized, "Unauthorized")
+                return
+        }
+
+        var latest interface{}
+        submission, err := scanKYCSubmission(db.QueryRow(r.Context(),
+                "SELECT "+kycSubmissionColumns+" FROM kyc_submissions WHERE user_id = $1 ORDER BY created_at DESC LIMIT 1",
+                user.ID))
+        switch {
+        case err == pgx.ErrNoRows:
+        case err != nil:
+                writeErrorResponse(w, r, ErrCodeInternal, "Failed to load KYC status")
+                return
+        default:
+                if err := loadKYCDocuments(r.Context(), []*KYCSubmission{submission}); err != nil {
+                        writeErrorResponse(w, r, ErrCodeInternal, "Failed to load KYC status")
+                        return
+                }
+                latest = kycSubmissionResponse(submission)
+        }
+
+        writeJSONResponse(w, http.StatusOK, map[string]interface{}{
+                "kycVerified":      user.KYCVerified,
+                "tier":             kycTierName(user.kycTier()),
+                "latestSubmission": latest,
+        })
+}
+
+// Admin KYC review queue endpoint
+func handleAdminKYCQueue(w http.ResponseWriter, r *http.Request) {
+        status := r.URL.Query().Get("status")
+        if status == "" {
+                status = kycStatusPending
+        }
+        if status != kycStatusPending && status != kycStatusApproved && status != kycStatusRejected {
+                writeErrorResponse(w, r, ErrCodeValidation, "status must be one of: pending, approved, rejected")
+                return
+        }
+
+        // Oldest first so the queue is reviewed in submission order. The last
+        // column flags document numbers already used by another account.
+        rows, err := db.Query(r.Context(), `
+                SELECT `+kycSubmissionColumns+`, (SELECT username FROM users WHERE users.id = kyc_submissions.user_id),
+                       EXISTS (SELECT 1 FROM kyc_submissions o
+                               WHERE o.document_number_hash = kyc_submissions.document_number_hash
+                                 AND o.user_id <> kyc_submissions.user_id AND o.status <> $2)
+                FROM kyc_submissions
+                WHERE status = $1
+                ORDER BY created_at ASC
+                LIMIT 200`, status, kycStatusRejected)
+        if err != nil {
+                writeErrorResponse(w, r, ErrCodeInternal, "Failed to load KYC queue")
+                return
+        }
+        defer rows.Close()
+
+        var submissions []*KYCSubmission
+        usernames := make(map[string]*string)
+        duplicates := make(map[string]bool)
+        for rows.Next() {
+                var s KYCSubmission
+                var username *string
+                var reused bool
+                if err := rows.Scan(&s.ID, &s.UserID, &s.Tier, &s.FullName, &s.DateOfBirth, &s.Country, &s.DocumentType,
+                        &s.DocumentNumberHash, &s.DocumentLast4, &s.Status, &s.RejectionReason, &s.ReviewedBy, &s.ReviewedAt,
+                        &s.CreatedAt, &username, &reused); err != nil {
+                        writeErrorResponse(w, r, ErrCodeInternal, "Failed to load KYC queue")
+                        return
+                }
+                submissions = append(submissions, &s)
+                usernames[s.ID] = username
+                duplicates[s.ID] = reused
+        }
+        if rows.Err() != nil {
+                writeErrorResponse(w, r, ErrCodeInternal, "Failed to load KYC queue")
+                return
+        }
+        rows.Close()
+
+        if err := loadKYCDocuments(r.Context(), submissions); err != nil {
+                writeErrorResponse(w, r, ErrCodeInternal, "Failed to load KYC queue")
+                return
+        }
+
+        entries := make([]map[string]interface{}, 0, len(submissions))
+        for _, s := range submissions {
+                entry := kycSubmissionResponse(s)
+                entry["username"] = usernames[s.ID]
+                entry["documentNumberReused"] = duplicates[s.ID]
+                entries = append(entries, entry)
+        }
+
+        writeJSONResponse(w, http.StatusOK, map[string]interface{}{"submissions": entries})
+}
+
+// Admin KYC document download endpoint
+func handleAdminKYCDocument(w http.ResponseWriter, r *http.Request) {
+        documentID := chi.URLParam(r, "id")
+        if !isUUID(documentID) {
+                writeErrorResponse(w, r, ErrCodeNotFound, "Document not found")
+                return
+        }
+
+        var doc KYCDocument
+        err := db.QueryRow(r.Context(),
+                "SELECT file_name, content_type, storage_key FROM kyc_documents WHERE id = $1", documentID,
+        ).Scan(&doc.FileName, &doc.ContentType, &doc.StorageKey)
+        if err == pgx.ErrNoRows {
+                writeErrorResponse(w, r, ErrCodeNotFound, "Document not found")
+                return
+        }
+        if err != nil {
+                writeErrorResponse(w, r, ErrCodeInternal, "Failed to load document")
+                return
+        }
+
+        object, err := objectStorage.Get(r.Context(), doc.StorageKey)
+        if errors.Is(err, ErrObjectNotFound) {
+                writeErrorResponse(w, r, ErrCodeNotFound, "Document file is missing")
+                return
+        }
+        if err != nil {
+                writeErrorResponse(w, r, ErrCodeInternal, "Failed to load document")
+                return
+        }
+        defer object.Close()
+
+        w.Header().Set("Content-Type", doc.ContentType)
+        w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", doc.FileName))
+        w.Header().Set("Cache-Control", "no-store")
+        io.Copy(w, object)
+}
+
+// Admin KYC approval endpoint
+func handleAdminApproveKYC(w http.ResponseWriter, r *http.Request) {
+        admin := getUserFromContext(r.Context())
+        submissionID := chi.URLParam(r, "id")
+        if !isUUID(submissionID) {
+                writeErrorResponse(w, r, ErrCodeNotFound, "KYC submission not found")
+                return
+        }
+
+        submission, err := reviewKYCSubmission(r.Context(), submissionID, admin.ID, true, "")
+        if err != nil {
+                writeAPIError(w, r, err)
+                return
+        }
+
+        writeJSONResponse(w, http.StatusOK, map[string]interface{}{
+                "message":    "KYC submission approved",
+                "submission": kycSubmissionResponse(submission),
+        })
+}
+
+// Admin KYC rejection endpoint
+func handleAdminRejectKYC(w http.ResponseWriter, r *http.Request) {
+        admin := getUserFromContext(r.Context())
+        submissionID := chi.URLParam(r, "id")
+        if !isUUID(submissionID) {
+                writeErrorResponse(w, r, ErrCodeNotFound, "KYC submission not found")
+                return
+        }
+
+        var req KYCRejectRequest
+        if err := decodeAndValidate(w, r, &req); err != nil {
+                writeAPIError(w, r, err)
+                return
+        }
+
+        submission, err := reviewKYCSubmission(r.Context(), submissionID, admin.ID, false, strings.TrimSpace(req.Reason))
+        if err != nil {
+                writeAPIError(w, r, err)
+                return
+        }
+
+        writeJSONResponse(w, http.StatusOK, map[string]interface{}{
+                "message":    "KYC submission rejected",
+                "submission": kycSubmissionResponse(submission),
+        })
+}
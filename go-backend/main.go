@@ -26,6 +26,9 @@ var (
         store *sessions.CookieStore
 )
 
+// sessionMaxAge is how long a login lasts
+const sessionMaxAge = 7 * 24 * time.Hour
+
 // User represents the users table
 type User struct {
         ID                    string          `json:"id" db:"id"`
@@ -49,6 +52,7 @@ type User struct {
         HasStartedMining      bool            `json:"hasStartedMining" db:"has_started_mining"`
         KYCVerified           bool            `json:"kycVerified" db:"kyc_verified"`
         KYCVerificationHash   *string         `json:"kycVerificationHash" db:"kyc_verification_hash"`
+        KYCTier               int             `json:"kycTier" db:"kyc_tier"`
         CreatedAt             time.Time       `json:"createdAt" db:"created_at"`
 }
 
@@ -60,6 +64,7 @@ type Deposit struct {
         TxHash    string          `json:"txHash" db:"tx_hash"`
         Amount    decimal.Decimal `json:"amount" db:"amount"`
         Currency  string          `json:"currency" db:"currency"`
+        Address   *string         `json:"address" db:"address"`
         Status    string          `json:"status" db:"status"`
         AdminNote *string         `json:"adminNote" db:"admin_note"`
         CreatedAt time.Time       `json:"createdAt" db:"created_at"`
@@ -93,7 +98,7 @@ type LoginRequest struct {
 }
 
 type RegisterRequest struct {
-        Username     string  `json:"username" validate:"required,min=3,max=20"`
+        Username     string  `json:"username" validate:"required,min=3,max=20,username"`
         AccessKey    string  `json:"accessKey" validate:"required,min=6"`
         ReferralCode *string `json:"referralCode,omitempty"`
 }
@@ -109,6 +114,12 @@ type DeviceCheckRequest struct {
         AudioFingerprint     string `json:"audioFingerprint" validate:"required"`
 }
 
+type PurchasePowerRequest struct {
+        Amount       string `json:"amount" validate:"required,money=USDT"`
+        DurationDays *int   `json:"durationDays,omitempty" validate:"oneof=30 90 365"`
+        AutoRenew    bool   `json:"autoRenew,omitempty"`
+}
+
 type DeviceCheckResponse struct {
         DeviceID      string `json:"deviceId"`
         CanRegister   bool   `json:"canRegister"`
@@ -118,44 +129,45 @@ type DeviceCheckResponse struct {
 }
 
 type ErrorResponse struct {
-        Message string `json:"message"`
+        Code      ErrorCode    `json:"code"`
+        Message   string       `json:"message"`
+        Fields    []FieldError `json:"fields,omitempty"`
+        RequestID string       `json:"requestId,omitempty"`
 }
 
 // Database operations
 
-// getUserByID retrieves a user by ID
-func getUserByID(ctx context.Context, userID string) (*User, error) {
-        query := `
-                SELECT id, username, access_key, referral_code, referred_by, registration_ip,
-                       usdt_balance, btc_balance, hash_power, base_hash_power, referral_hash_bonus,
-                       gbtc_balance, unclaimed_balance, total_referral_earnings, last_active_block,
-                       is_admin, is_frozen, is_banned, has_started_mining, kyc_verified,
-                       kyc_verification_hash, created_at
-                FROM users WHERE id = $1
-        `
-        
+// userColumns lists the users columns in the order scanUser expects
+const userColumns = `
+        id, username, access_key, referral_code, referred_by, registration_ip,
+        usdt_balance, btc_balance, hash_power, base_hash_power, referral_hash_bonus,
+        gbtc_balance, unclaimed_balance, total_referral_earnings, last_active_block,
+        is_admin, is_frozen, is_banned, has_started_mining, kyc_verified,
+        kyc_verification_hash, kyc_tier, created_at`
+
+// scanUser scans a row selected with userColumns. Numeric columns are scanned
+// directly into decimal.Decimal so a malformed value is an error, not zero.
+func scanUser(row pgx.Row) (*User, error) {
         var user User
-        var usdtStr, btcStr, hashStr, baseHashStr, refHashStr, gbtcStr, unclaimedStr, refEarningsStr string
-        err := db.QueryRow(ctx, query, userID).Scan(
+        err := row.Scan(
                 &user.ID, &user.Username, &user.AccessKey, &user.ReferralCode, &user.ReferredBy,
-                &user.RegistrationIP, &usdtStr, &btcStr, &hashStr,
-                &baseHashStr, &refHashStr, &gbtcStr, &unclaimedStr,
-                &refEarningsStr, &user.LastActiveBlock, &user.IsAdmin, &user.IsFrozen,
+                &user.RegistrationIP, &user.USDTBalance, &user.BTCBalance, &user.HashPower,
+                &user.BaseHashPower, &user.ReferralHashBonus, &user.GBTCBalance, &user.UnclaimedBalance,
+                &user.TotalReferralEarnings, &user.LastActiveBlock, &user.IsAdmin, &user.IsFrozen,
                 &user.IsBanned, &user.HasStartedMining, &user.KYCVerified, &user.KYCVerificationHash,
-                &user.CreatedAt,
+                &user.KYCTier, &user.CreatedAt,
         )
-        
-        if err == nil {
-                user.USDTBalance, _ = decimal.NewFromString(usdtStr)
-                user.BTCBalance, _ = decimal.NewFromString(btcStr)
-                user.HashPower, _ = decimal.NewFromString(hashStr)
-                user.BaseHashPower, _ = decimal.NewFromString(baseHashStr)
-                user.ReferralHashBonus, _ = decimal.NewFromString(refHashStr)
-                user.GBTCBalance, _ = decimal.NewFromString(gbtcStr)
-                user.UnclaimedBalance, _ = decimal.NewFromString(unclaimedStr)
-                user.TotalReferralEarnings, _ = decimal.NewFromString(refEarningsStr)
+        if err != nil {
+                return nil, err
         }
+        return &user, nil
+}
+
+// getUserByID retrieves a user by ID
+func getUserByID(ctx context.Context, userID string) (*User, error) {
+        query := `SELECT ` + userColumns + ` FROM users WHERE id = $1`
         
+        user, err := scanUser(db.QueryRow(ctx, query, userID))
         if err != nil {
                 if err == pgx.ErrNoRows {
                         return nil, nil
@@ -163,42 +175,14 @@ func getUserByID(ctx context.Context, userID string) (*User, error) {
                 return nil, fmt.Errorf("failed to get user by ID: %w", err)
         }
         
-        return &user, nil
+        return user, nil
 }
 
 // getUserByUsername retrieves a user by username
 func getUserByUsername(ctx context.Context, username string) (*User, error) {
-        query := `
-                SELECT id, username, access_key, referral_code, referred_by, registration_ip,
-                       usdt_balance, btc_balance, hash_power, base_hash_power, referral_hash_bonus,
-                       gbtc_balance, unclaimed_balance, total_referral_earnings, last_active_block,
-                       is_admin, is_frozen, is_banned, has_started_mining, kyc_verified,
-                       kyc_verification_hash, created_at
-                FROM users WHERE username = $1
-        `
-        
-        var user User
-        var usdtStr, btcStr, hashStr, baseHashStr, refHashStr, gbtcStr, unclaimedStr, refEarningsStr string
-        err := db.QueryRow(ctx, query, username).Scan(
-                &user.ID, &user.Username, &user.AccessKey, &user.ReferralCode, &user.ReferredBy,
-                &user.RegistrationIP, &usdtStr, &btcStr, &hashStr,
-                &baseHashStr, &refHashStr, &gbtcStr, &unclaimedStr,
-                &refEarningsStr, &user.LastActiveBlock, &user.IsAdmin, &user.IsFrozen,
-                &user.IsBanned, &user.HasStartedMining, &user.KYCVerified, &user.KYCVerificationHash,
-                &user.CreatedAt,
-        )
-        
-        if err == nil {
-                user.USDTBalance, _ = decimal.NewFromString(usdtStr)
-                user.BTCBalance, _ = decimal.NewFromString(btcStr)
-                user.HashPower, _ = decimal.NewFromString(hashStr)
-                user.BaseHashPower, _ = decimal.NewFromString(baseHashStr)
-                user.ReferralHashBonus, _ = decimal.NewFromString(refHashStr)
-                user.GBTCBalance, _ = decimal.NewFromString(gbtcStr)
-                user.UnclaimedBalance, _ = decimal.NewFromString(unclaimedStr)
-                user.TotalReferralEarnings, _ = decimal.NewFromString(refEarningsStr)
-        }
+        query := `SELECT ` + userColumns + ` FROM users WHERE username = $1`
         
+        user, err := scanUser(db.QueryRow(ctx, query, username))
         if err != nil {
                 if err == pgx.ErrNoRows {
                         return nil, nil
@@ -206,7 +190,7 @@ func getUserByUsername(ctx context.Context, username string) (*User, error) {
                 return nil, fmt.Errorf("failed to get user: %w", err)
         }
         
-        return &user, nil
+        return user, nil
 }
 
 // createUser creates a new user account
@@ -230,42 +214,16 @@ func createUser(ctx context.Context, req RegisterRequest, clientIP string) (*Use
         query := `
                 INSERT INTO users (username, access_key, referral_code, referred_by, registration_ip,
                                   usdt_balance, btc_balance, hash_power, base_hash_power, referral_hash_bonus,
-                                  gbtc_balance, unclaimed_balance, total_referral_earnings)
-                VALUES ($1, $2, $3, $4, $5, 0.00, 0.00000000, 0.00, 0.00, 0.00, 0.00000000, 0.00000000, 0.00)
-                RETURNING id, username, access_key, referral_code, referred_by, registration_ip,
-                          usdt_balance, btc_balance, hash_power, base_hash_power, referral_hash_bonus,
-                          gbtc_balance, unclaimed_balance, total_referral_earnings, last_active_block,
-                          is_admin, is_frozen, is_banned, has_started_mining, kyc_verified,
-                          kyc_verification_hash, created_at
-        `
-        
-        var user User
-        var usdtStr, btcStr, hashStr, baseHashStr, refHashStr, gbtcStr, unclaimedStr, refEarningsStr string
-        err = db.QueryRow(ctx, query, req.Username, hashedKey, referralCode, req.ReferralCode, clientIP).Scan(
-                &user.ID, &user.Username, &user.AccessKey, &user.ReferralCode, &user.ReferredBy,
-                &user.RegistrationIP, &usdtStr, &btcStr, &hashStr,
-                &baseHashStr, &refHashStr, &gbtcStr, &unclaimedStr,
-                &refEarningsStr, &user.LastActiveBlock, &user.IsAdmin, &user.IsFrozen,
-                &user.IsBanned, &user.HasStartedMining, &user.KYCVerified, &user.KYCVerificationHash,
-                &user.CreatedAt,
-        )
-        
-        if err == nil {
-                user.USDTBalance, _ = decimal.NewFromString(usdtStr)
-                user.BTCBalance, _ = decimal.NewFromString(btcStr)
-                user.HashPower, _ = decimal.NewFromString(hashStr)
-                user.BaseHashPower, _ = decimal.NewFromString(baseHashStr)
-                user.ReferralHashBonus, _ = decimal.NewFromString(refHashStr)
-                user.GBTCBalance, _ = decimal.NewFromString(gbtcStr)
-                user.UnclaimedBalance, _ = decimal.NewFromString(unclaimedStr)
-                user.TotalReferralEarnings, _ = decimal.NewFromString(refEarningsStr)
-        }
+                                  gbtc_balance, unclaimed_balance, total_referral_earnings, created_at)
+                VALUES ($1, $2, $3, $4, $5, 0.00, 0.00000000, 0.00, 0.00, 0.00, 0.00000000, 0.00000000, 0.00, $6)
+                RETURNING ` + userColumns
         
+        user, err := scanUser(db.QueryRow(ctx, query, req.Username, hashedKey, referralCode, req.ReferralCode, clientIP, clock.Now()))
         if err != nil {
                 return nil, fmt.Errorf("failed to create user: %w", err)
         }
         
-        return &user, nil
+        return user, nil
 }
 
 // verifyAccessKey verifies the user's access key
@@ -295,6 +253,16 @@ func verifyAccessKey(hashedKey, plainKey string) bool {
 
 // HTTP Handlers
 
+// sessionExpired reports whether a session is older than sessionMaxAge by
+// the clock. The cookie's own expiry only follows the wall clock.
+func sessionExpired(session *sessions.Session) bool {
+        issuedAt, ok := session.Values["issued_at"].(int64)
+        if !ok {
+                return false
+        }
+        return clock.Now().Sub(time.Unix(issuedAt, 0)) > sessionMaxAge
+}
+
 // Authentication middleware
 func authMiddleware(next http.Handler) http.Handler {
         return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -302,25 +270,29 @@ func authMiddleware(next http.Handler) http.Handler {
                 
                 userID, ok := session.Values["user_id"].(string)
                 if !ok || userID == "" {
-                        writeErrorResponse(w, http.StatusUnauthorized, "Authentication required")
+                        writeErrorResponse(w, r, ErrCodeUnauthorized, "Authentication required")
+                        return
+                }
+                if sessionExpired(session) {
+                        writeErrorResponse(w, r, ErrCodeUnauthorized, "Session expired")
                         return
                 }
                 
                 // Get user from database
                 user, err := getUserByID(r.Context(), userID)
                 if err != nil || user == nil {
-                        writeErrorResponse(w, http.StatusUnauthorized, "Invalid session")
+                        writeErrorResponse(w, r, ErrCodeUnauthorized, "Invalid session")
                         return
                 }
                 
                 // Check if user is banned or frozen
                 if user.IsBanned {
-                        writeErrorResponse(w, http.StatusForbidden, "Account is banned")
+                        writeErrorResponse(w, r, ErrCodeAccountBanned, "Account is banned")
                         return
                 }
                 
                 if user.IsFrozen {
-                        writeErrorResponse(w, http.StatusForbidden, "Account is frozen")
+                        writeErrorResponse(w, r, ErrCodeAccountFrozen, "Account is frozen")
                         return
                 }
                 
@@ -330,12 +302,29 @@ func authMiddleware(next http.Handler) http.Handler {
         })
 }
 
+// optionalAuthMiddleware adds the session user to the context when there is
+// a valid session, but lets anonymous requests through
+func optionalAuthMiddleware(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+                session, _ := store.Get(r, "session")
+                
+                if userID, ok := session.Values["user_id"].(string); ok && userID != "" && !sessionExpired(session) {
+                        user, err := getUserByID(r.Context(), userID)
+                        if err == nil && user != nil && !user.IsBanned && !user.IsFrozen {
+                                r = r.WithContext(context.WithValue(r.Context(), "user", user))
+                        }
+                }
+                
+                next.ServeHTTP(w, r)
+        })
+}
+
 // Admin middleware
 func adminMiddleware(next http.Handler) http.Handler {
         return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
                 user := getUserFromContext(r.Context())
                 if user == nil || !user.IsAdmin {
-                        writeErrorResponse(w, http.StatusForbidden, "Admin access required")
+                        writeErrorResponse(w, r, ErrCodeForbidden, "Admin access required")
                         return
                 }
                 
@@ -346,31 +335,20 @@ func adminMiddleware(next http.Handler) http.Handler {
 // Register handler
 func handleRegister(w http.ResponseWriter, r *http.Request) {
         var req RegisterRequest
-        if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-                writeErrorResponse(w, http.StatusBadRequest, "Invalid request format")
-                return
-        }
-        
-        // Validate request
-        if req.Username == "" || len(req.Username) < 3 || len(req.Username) > 20 {
-                writeErrorResponse(w, http.StatusBadRequest, "Username must be 3-20 characters")
-                return
-        }
-        
-        if req.AccessKey == "" || len(req.AccessKey) < 6 {
-                writeErrorResponse(w, http.StatusBadRequest, "Access key must be at least 6 characters")
+        if err := decodeAndValidate(w, r, &req); err != nil {
+                writeAPIError(w, r, err)
                 return
         }
         
         // Check if username already exists
         existingUser, err := getUserByUsername(r.Context(), req.Username)
         if err != nil {
-                writeErrorResponse(w, http.StatusInternalServerError, "Database error")
+                writeErrorResponse(w, r, ErrCodeInternal, "Database error")
                 return
         }
         
         if existingUser != nil {
-                writeErrorResponse(w, http.StatusConflict, "Username already exists")
+                writeErrorResponse(w, r, ErrCodeConflict, "Username already exists")
                 return
         }
         
@@ -380,13 +358,14 @@ func handleRegister(w http.ResponseWriter, r *http.Request) {
         // Create user
         user, err := createUser(r.Context(), req, clientIP)
         if err != nil {
-                writeErrorResponse(w, http.StatusInternalServerError, "Failed to create user")
+                writeErrorResponse(w, r, ErrCodeInternal, "Failed to create user")
                 return
         }
         
         // Create session
         session, _ := store.Get(r, "session")
         session.Values["user_id"] = user.ID
+        session.Values["issued_at"] = clock.Now().Unix()
         session.Save(r, w)
         
         // Return user data (without sensitive fields)
@@ -394,12 +373,12 @@ func handleRegister(w http.ResponseWriter, r *http.Request) {
                 "id":                    user.ID,
                 "username":              user.Username,
                 "referralCode":          user.ReferralCode,
-                "usdtBalance":           user.USDTBalance.String(),
-                "btcBalance":            user.BTCBalance.String(),
-                "hashPower":             user.HashPower.String(),
-                "gbtcBalance":           user.GBTCBalance.String(),
-                "unclaimedBalance":      user.UnclaimedBalance.String(),
-                "totalReferralEarnings": user.TotalReferralEarnings.String(),
+                "usdtBalance":           formatAmount(user.USDTBalance, CurrencyUSDT),
+                "btcBalance":            formatAmount(user.BTCBalance, CurrencyBTC),
+                "hashPower":             formatHashPower(user.HashPower),
+                "gbtcBalance":           formatAmount(user.GBTCBalance, CurrencyGBTC),
+                "unclaimedBalance":      formatAmount(user.UnclaimedBalance, CurrencyGBTC),
+                "totalReferralEarnings": formatAmount(user.TotalReferralEarnings, CurrencyUSDT),
                 "isAdmin":               user.IsAdmin,
                 "hasStartedMining":      user.HasStartedMining,
                 "kycVerified":           user.KYCVerified,
@@ -412,43 +391,44 @@ func handleRegister(w http.ResponseWriter, r *http.Request) {
 // Login handler
 func handleLogin(w http.ResponseWriter, r *http.Request) {
         var req LoginRequest
-        if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-                writeErrorResponse(w, http.StatusBadRequest, "Invalid request format")
+        if err := decodeAndValidate(w, r, &req); err != nil {
+                writeAPIError(w, r, err)
                 return
         }
         
         // Get user by username
         user, err := getUserByUsername(r.Context(), req.Username)
         if err != nil {
-                writeErrorResponse(w, http.StatusInternalServerError, "Database error")
+                writeErrorResponse(w, r, ErrCodeInternal, "Database error")
                 return
         }
         
         if user == nil {
-                writeErrorResponse(w, http.StatusUnauthorized, "Invalid credentials")
+                writeErrorResponse(w, r, ErrCodeInvalidCredentials, "Invalid credentials")
                 return
         }
         
         // Verify access key
         if !verifyAccessKey(user.AccessKey, req.AccessKey) {
-                writeErrorResponse(w, http.StatusUnauthorized, "Invalid credentials")
+                writeErrorResponse(w, r, ErrCodeInvalidCredentials, "Invalid credentials")
                 return
         }
         
         // Check if user is banned or frozen
         if user.IsBanned {
-                writeErrorResponse(w, http.StatusForbidden, "Account is banned")
+                writeErrorResponse(w, r, ErrCodeAccountBanned, "Account is banned")
                 return
         }
         
         if user.IsFrozen {
-                writeErrorResponse(w, http.StatusForbidden, "Account is frozen")
+                writeErrorResponse(w, r, ErrCodeAccountFrozen, "Account is frozen")
                 return
         }
         
         // Create session
         session, _ := store.Get(r, "session")
         session.Values["user_id"] = user.ID
+        session.Values["issued_at"] = clock.Now().Unix()
         session.Save(r, w)
         
         // Return user data (without sensitive fields)
@@ -456,12 +436,12 @@ func handleLogin(w http.ResponseWriter, r *http.Request) {
                 "id":                    user.ID,
                 "username":              user.Username,
                 "referralCode":          user.ReferralCode,
-                "usdtBalance":           user.USDTBalance.String(),
-                "btcBalance":            user.BTCBalance.String(),
-                "hashPower":             user.HashPower.String(),
-                "gbtcBalance":           user.GBTCBalance.String(),
-                "unclaimedBalance":      user.UnclaimedBalance.String(),
-                "totalReferralEarnings": user.TotalReferralEarnings.String(),
+                "usdtBalance":           formatAmount(user.USDTBalance, CurrencyUSDT),
+                "btcBalance":            formatAmount(user.BTCBalance, CurrencyBTC),
+                "hashPower":             formatHashPower(user.HashPower),
+                "gbtcBalance":           formatAmount(user.GBTCBalance, CurrencyGBTC),
+                "unclaimedBalance":      formatAmount(user.UnclaimedBalance, CurrencyGBTC),
+                "totalReferralEarnings": formatAmount(user.TotalReferralEarnings, CurrencyUSDT),
                 "isAdmin":               user.IsAdmin,
                 "hasStartedMining":      user.HasStartedMining,
                 "kycVerified":           user.KYCVerified,
@@ -476,7 +456,7 @@ func handleLogin(w http.ResponseWriter, r *http.Request) {
 func handleGetUser(w http.ResponseWriter, r *http.Request) {
         user := getUserFromContext(r.Context())
         if user == nil {
-                writeErrorResponse(w, http.StatusUnauthorized, "Authentication required")
+                writeErrorResponse(w, r, ErrCodeUnauthorized, "Authentication required")
                 return
         }
         
@@ -485,14 +465,14 @@ func handleGetUser(w http.ResponseWriter, r *http.Request) {
                 "id":                    user.ID,
                 "username":              user.Username,
                 "referralCode":          user.ReferralCode,
-                "usdtBalance":           user.USDTBalance.String(),
-                "btcBalance":            user.BTCBalance.String(),
-                "hashPower":             user.HashPower.String(),
-                "baseHashPower":         user.BaseHashPower.String(),
-                "referralHashBonus":     user.ReferralHashBonus.String(),
-                "gbtcBalance":           user.GBTCBalance.String(),
-                "unclaimedBalance":      user.UnclaimedBalance.String(),
-                "totalReferralEarnings": user.TotalReferralEarnings.String(),
+                "usdtBalance":           formatAmount(user.USDTBalance, CurrencyUSDT),
+                "btcBalance":            formatAmount(user.BTCBalance, CurrencyBTC),
+                "hashPower":             formatHashPower(user.HashPower),
+                "baseHashPower":         formatHashPower(user.BaseHashPower),
+                "referralHashBonus":     formatHashPower(user.ReferralHashBonus),
+                "gbtcBalance":           formatAmount(user.GBTCBalance, CurrencyGBTC),
+                "unclaimedBalance":      formatAmount(user.UnclaimedBalance, CurrencyGBTC),
+                "totalReferralEarnings": formatAmount(user.TotalReferralEarnings, CurrencyUSDT),
                 "isAdmin":               user.IsAdmin,
                 "hasStartedMining":      user.HasStartedMining,
                 "kycVerified":           user.KYCVerified,
@@ -517,146 +497,149 @@ func handleLogout(w http.ResponseWriter, r *http.Request) {
 
 // Global stats endpoint
 func handleGlobalStats(w http.ResponseWriter, r *http.Request) {
-        stats := map[string]interface{}{
-                "totalHashrate":       1000.0,
-                "blockHeight":         1,
-                "totalBlockHeight":    0,
-                "activeMiners":        0,
-                "blockReward":         50.0,
-                "totalCirculation":    0.0,
-                "maxSupply":           2100000,
-                "nextHalving":         210000,
-                "blocksUntilHalving":  210000,
+        supply, err := getSupplyMetrics(r.Context(), clock.Now())
+        if err != nil {
+                writeErrorResponse(w, r, ErrCodeInternal, "Failed to load global stats")
+                return
+        }
+        
+        blockNumber, err := getIntSetting(r.Context(), db, settingBlockNumber, 1)
+        if err != nil {
+                writeErrorResponse(w, r, ErrCodeInternal, "Failed to load global stats")
+                return
         }
         
-        // Get real data from database if possible
-        var totalHashrate float64
-        if err := db.QueryRow(r.Context(), 
-                "SELECT COALESCE(SUM(hash_power), 0) FROM users").Scan(&totalHashrate); err == nil {
-                stats["totalHashrate"] = totalHashrate
+        var totalHashrate decimal.Decimal
+        var activeMiners int64
+        err = db.QueryRow(r.Context(), `
+                SELECT COALESCE(SUM(u.hash_power), 0), COUNT(*) FILTER (WHERE `+eligibleMinerCondition+`)
+                FROM users u`).Scan(&totalHashrate, &activeMiners)
+        if err != nil {
+                writeErrorResponse(w, r, ErrCodeInternal, "Failed to load global stats")
+                return
         }
         
-        writeJSONResponse(w, http.StatusOK, stats)
+        writeJSONResponse(w, http.StatusOK, map[string]interface{}{
+                "totalHashrate":      totalHashrate.InexactFloat64(),
+                "blockHeight":        blockNumber,
+                "totalBlockHeight":   supply.TotalBlocks,
+                "activeMiners":       activeMiners,
+                "blockReward":        supply.CurrentBlockReward.InexactFloat64(),
+                "totalCirculation":   supply.Circulating.InexactFloat64(),
+                "maxSupply":          supply.MaxSupply.InexactFloat64(),
+                "nextHalving":        supply.NextHalving,
+                "blocksUntilHalving": supply.BlocksRemaining,
+        })
 }
 
 // Purchase hash power endpoint  
 func handlePurchasePower(w http.ResponseWriter, r *http.Request) {
         user := getUserFromContext(r.Context())
         if user == nil {
-                writeErrorResponse(w, http.StatusUnauthorized, "Unauthorized")
+                writeErrorResponse(w, r, ErrCodeUnauthorized, "Unauthorized")
                 return
         }
         
-        var req struct {
-                Amount float64 `json:"amount"`
-        }
-        
-        if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-                writeErrorResponse(w, http.StatusBadRequest, "Invalid request format")
+        var req PurchasePowerRequest
+        if err := decodeAndValidate(w, r, &req); err != nil {
+                writeAPIError(w, r, err)
                 return
         }
         
-        if req.Amount < 1 {
-                writeErrorResponse(w, http.StatusBadRequest, "Minimum purchase is 1 USDT")
+        amount, err := parseAmount(req.Amount, CurrencyUSDT)
+        if err != nil {
+                writeAPIError(w, r, err)
                 return
         }
         
-        // Check balance
-        if user.USDTBalance.LessThan(decimal.NewFromFloat(req.Amount)) {
-                writeErrorResponse(w, http.StatusBadRequest, "Insufficient USDT balance")
+        if amount.LessThan(decimal.NewFromInt(1)) {
+                writeErrorResponse(w, r, ErrCodeValidation, "Minimum purchase is 1 USDT")
                 return
         }
         
-        // Update user balances - deduct USDT, add hash power
-        newUSDT := user.USDTBalance.Sub(decimal.NewFromFloat(req.Amount))
-        newHashPower := user.HashPower.Add(decimal.NewFromFloat(req.Amount))
-        
-        _, err := db.Exec(r.Context(), 
-                "UPDATE users SET usdt_balance = $1, hash_power = $2 WHERE id = $3",
-                newUSDT.String(), newHashPower.String(), user.ID)
-        
+        contract, quote, err := purchaseHashPower(r.Context(), user.ID, amount, req.DurationDays, req.AutoRenew)
         if err != nil {
-                writeErrorResponse(w, http.StatusInternalServerError, "Failed to purchase hash power")
+                writeAPIError(w, r, err)
                 return
         }
         
-        writeJSONResponse(w, http.StatusOK, map[string]string{"message": "Hash power purchased successfully"})
+        receipt := map[string]interface{}{
+                "contractId":      contract.ID,
+                "amount":          formatAmount(quote.Amount, CurrencyUSDT),
+                "unitPrice":       quote.UnitPrice.String(),
+                "discountPercent": quote.DiscountPercent.StringFixed(2),
+                "effectivePrice":  quote.EffectivePrice.String(),
+                "hashPower":       formatHashPower(quote.HashPower),
+                "durationDays":    contract.DurationDays,
+                "autoRenew":       contract.AutoRenew,
+                "expiresAt":       contract.ExpiresAt,
+                "purchasedAt":     contract.CreatedAt,
+        }
+        
+        writeJSONResponse(w, http.StatusOK, map[string]interface{}{
+                "message": "Hash power purchased successfully",
+                "receipt": receipt,
+        })
 }
 
 // Start mining endpoint
 func handleStartMining(w http.ResponseWriter, r *http.Request) {
         user := getUserFromContext(r.Context())
         if user == nil {
-                writeErrorResponse(w, http.StatusUnauthorized, "Unauthorized")
+                writeErrorResponse(w, r, ErrCodeUnauthorized, "Unauthorized")
                 return
         }
         
         if user.HashPower.LessThanOrEqual(decimal.Zero) {
-                writeErrorResponse(w, http.StatusBadRequest, "Hash power required to start mining")
+                writeErrorResponse(w, r, ErrCodeInvalidState, "Hash power required to start mining")
                 return
         }
         
-        // Mark user as having started mining
-        _, err := db.Exec(r.Context(), 
-                "UPDATE users SET has_started_mining = true WHERE id = $1", user.ID)
-        
+        // Mark user as having started mining, resuming an inactive miner
+        resumed, err := startMining(r.Context(), user.ID, clock.Now())
         if err != nil {
-                writeErrorResponse(w, http.StatusInternalServerError, "Failed to start mining")
+                writeAPIError(w, r, err)
                 return
         }
         
-        writeJSONResponse(w, http.StatusOK, map[string]string{"message": "Mining started successfully"})
+        writeJSONResponse(w, http.StatusOK, map[string]interface{}{
+                "message": "Mining started successfully",
+                "resumed": resumed,
+        })
 }
 
 // Claim rewards endpoint
 func handleClaimRewards(w http.ResponseWriter, r *http.Request) {
         user := getUserFromContext(r.Context())
         if user == nil {
-                writeErrorResponse(w, http.StatusUnauthorized, "Unauthorized")
-                return
-        }
-        
-        if user.UnclaimedBalance.LessThanOrEqual(decimal.Zero) {
-                writeErrorResponse(w, http.StatusBadRequest, "No rewards to claim")
+                writeErrorResponse(w, r, ErrCodeUnauthorized, "Unauthorized")
                 return
         }
         
         // Move unclaimed to GBTC balance
-        newGBTC := user.GBTCBalance.Add(user.UnclaimedBalance)
-        
-        _, err := db.Exec(r.Context(), 
-                "UPDATE users SET gbtc_balance = $1, unclaimed_balance = '0' WHERE id = $2",
-                newGBTC.String(), user.ID)
-        
+        blocks, claimed, err := claimRewards(r.Context(), user.ID)
         if err != nil {
-                writeErrorResponse(w, http.StatusInternalServerError, "Failed to claim rewards")
+                writeAPIError(w, r, err)
                 return
         }
         
-        writeJSONResponse(w, http.StatusOK, map[string]string{"message": "Rewards claimed successfully"})
+        writeJSONResponse(w, http.StatusOK, map[string]interface{}{
+                "message": "Rewards claimed successfully",
+                "claimed": formatAmount(claimed, CurrencyGBTC),
+                "blocks":  blocks,
+        })
 }
 
 // BTC related endpoints
-func handleBTCPrices(w http.ResponseWriter, r *http.Request) {
-        prices := map[string]interface{}{
-                "btcPrice":                "95000.00",
-                "hashratePrice":           "1.00", 
-                "requiredHashratePerBTC":  95000.0,
-                "timestamp":               "2025-01-18T12:00:00Z",
-        }
-        
-        writeJSONResponse(w, http.StatusOK, prices)
-}
-
 func handleBTCBalance(w http.ResponseWriter, r *http.Request) {
         user := getUserFromContext(r.Context())
         if user == nil {
-                writeErrorResponse(w, http.StatusUnauthorized, "Unauthorized")
+                writeErrorResponse(w, r, ErrCodeUnauthorized, "Unauthorized")
                 return
         }
         
         writeJSONResponse(w, http.StatusOK, map[string]string{
-                "btcBalance": user.BTCBalance.String(),
+                "btcBalance": formatAmount(user.BTCBalance, CurrencyBTC),
         })
 }
 
@@ -664,7 +647,7 @@ func handleBTCBalance(w http.ResponseWriter, r *http.Request) {
 func handleReferrals(w http.ResponseWriter, r *http.Request) {
         user := getUserFromContext(r.Context())
         if user == nil {
-                writeErrorResponse(w, http.StatusUnauthorized, "Unauthorized")
+                writeErrorResponse(w, r, ErrCodeUnauthorized, "Unauthorized")
                 return
         }
         
@@ -677,7 +660,7 @@ func handleReferrals(w http.ResponseWriter, r *http.Request) {
                 "referralCode":    referralCode,
                 "totalReferrals":  0,
                 "activeReferrals": 0,
-                "totalEarnings":   user.TotalReferralEarnings.String(),
+                "totalEarnings":   formatAmount(user.TotalReferralEarnings, CurrencyUSDT),
                 "referrals":       []interface{}{},
         }
         
@@ -691,12 +674,6 @@ func writeJSONResponse(w http.ResponseWriter, statusCode int, data interface{})
         json.NewEncoder(w).Encode(data)
 }
 
-func writeErrorResponse(w http.ResponseWriter, statusCode int, message string) {
-        w.Header().Set("Content-Type", "application/json")
-        w.WriteHeader(statusCode)
-        json.NewEncoder(w).Encode(ErrorResponse{Message: message})
-}
-
 func getUserFromContext(ctx context.Context) *User {
         if user, ok := ctx.Value("user").(*User); ok {
                 return user
@@ -773,6 +750,11 @@ func min(a, b int) int {
 }
 
 func main() {
+        // b2b simulate runs an economic backtest instead of the server
+        if len(os.Args) > 1 && os.Args[1] == "simulate" {
+                os.Exit(runSimulateCommand(os.Args[2:], os.Stdout, os.Stderr))
+        }
+        
         // Initialize database connection
         dbURL := os.Getenv("DATABASE_URL")
         if dbURL == "" {
@@ -786,28 +768,75 @@ func main() {
         }
         defer db.Close()
 
+        // SIMULATION_MODE=true replaces the wall clock with one advanced by admins
+        clock, err = newClockFromEnv()
+        if err != nil {
+                log.Fatalf("Failed to configure clock: %v", err)
+        }
+        _, simulating := simulatedClock()
+        
+        // BTC price updates from the external source; set BTC_PRICE_POLL_INTERVAL=0 to rely on manual prices
+        pollInterval := defaultPricePollInterval
+        if v := os.Getenv("BTC_PRICE_POLL_INTERVAL"); v != "" {
+                if pollInterval, err = time.ParseDuration(v); err != nil {
+                        log.Fatalf("Invalid BTC_PRICE_POLL_INTERVAL: %v", err)
+                }
+        }
+        if simulating {
+                // Simulations must not depend on live prices
+                pollInterval = 0
+        }
+
+        // Scheduled jobs stop when main returns
+        ctx, cancel := context.WithCancel(context.Background())
+        defer cancel()
+        jobRunner, err = newJobRunner(defaultJobs(newHTTPPriceSourceFromEnv(), pollInterval))
+        if err != nil {
+                log.Fatalf("Failed to configure jobs: %v", err)
+        }
+        if simulating {
+                // Jobs only run when the simulated clock is advanced
+                log.Printf("Simulation mode: clock starts at %s", clock.Now().Format(time.RFC3339))
+        } else {
+                jobRunner.Start(ctx)
+        }
+        eventHub.Start(ctx)
+
         // Initialize session store
         sessionSecret := os.Getenv("SESSION_SECRET")
         if sessionSecret == "" {
                 sessionSecret = "your-secret-key-change-in-production"
         }
         store = sessions.NewCookieStore([]byte(sessionSecret))
+        
+        // Conversion quotes are signed with their own key when one is configured
+        quoteSigningKey = []byte(os.Getenv("QUOTE_SECRET"))
+        if len(quoteSigningKey) == 0 {
+                quoteSigningKey = []byte(sessionSecret)
+        }
+        
+        // KYC documents are kept in object storage, not the database
+        objectStorage, err = newObjectStorageFromEnv()
+        if err != nil {
+                log.Fatalf("Failed to configure object storage: %v", err)
+        }
         store.Options = &sessions.Options{
                 Path:     "/",
-                MaxAge:   86400 * 7, // 7 days
+                MaxAge:   int(sessionMaxAge / time.Second),
                 HttpOnly: true,
                 Secure:   false, // Set to true in production with HTTPS
                 SameSite: http.SameSiteDefaultMode,
         }
 
         r := chi.NewRouter()
+        r.Use(requestIDMiddleware)
 
         // CORS configuration for Replit proxy
         r.Use(cors.Handler(cors.Options{
                 AllowedOrigins:   []string{"*"},
                 AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
                 AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-                ExposedHeaders:   []string{"Link"},
+                ExposedHeaders:   []string{"Link", "X-Request-Id", "X-Next-Cursor"},
                 AllowCredentials: true,
                 MaxAge:           300,
         }))
@@ -817,6 +846,19 @@ func main() {
                 writeJSONResponse(w, http.StatusOK, map[string]string{"status": "ok", "service": "bit2block-mining-go"})
         })
 
+        // Error code catalog
+        r.Get("/api/errors", handleErrorCatalog)
+        r.Get("/api/supply-metrics", handleSupplyMetrics)
+        
+        // Block explorer; shares and recipients are shown to signed in users
+        r.Group(func(r chi.Router) {
+                r.Use(optionalAuthMiddleware)
+                
+                r.Get("/api/blocks", handleListBlocks)
+                r.Get("/api/blocks/{number}", handleGetBlock)
+                r.Get("/api/tx/{hash}", handleGetTx)
+        })
+
         // Authentication routes
         r.Post("/api/auth/register", handleRegister)
         r.Post("/api/auth/login", handleLogin)
@@ -828,19 +870,90 @@ func main() {
                 
                 // User routes
                 r.Get("/api/user", handleGetUser)
+                r.Get("/api/events", handleEventStream)
+                
+                r.Get("/api/settings/{key}", handleGetSetting)
                 
                 // Mining routes
                 r.Get("/api/global-stats", handleGlobalStats)
+                r.Get("/api/mining/difficulty", handleDifficulty)
                 r.Post("/api/purchase-power", handlePurchasePower)
                 r.Post("/api/start-mining", handleStartMining)
+                r.Post("/api/stop-mining", handleStopMining)
+                r.Get("/api/mining/participation", handleMiningParticipation)
+                r.Get("/api/mining/stats", handleMiningStats)
                 r.Post("/api/claim-rewards", handleClaimRewards)
                 
+                // Hash power contract routes
+                r.Get("/api/contracts", handleGetContracts)
+                r.Post("/api/contracts/{id}/auto-renew", handleSetContractAutoRenew)
+                
                 // BTC routes
                 r.Get("/api/btc/prices", handleBTCPrices)
+                r.Get("/api/btc/price-history", handleBTCPriceHistory)
                 r.Get("/api/btc/balance", handleBTCBalance)
+                r.Post("/api/btc/stake", handleCreateStake)
+                r.Get("/api/btc/stakes", handleGetStakes)
+                r.Post("/api/btc/stakes/{id}/cancel", handleCancelStake)
+                
+                // Conversion routes
+                r.Post("/api/convert/quote", handleConversionQuote)
+                r.Post("/api/convert", handleConvert)
+                r.Get("/api/conversions", handleGetConversions)
+                
+                // Transfer routes
+                r.Post("/api/transfer", handleTransfer)
+                r.Get("/api/transfers", handleGetTransfers)
+                r.Get("/api/transactions", handleGetTransactions)
+                
+                // Deposit and withdrawal routes
+                r.Post("/api/deposits", handleCreateDeposit)
+                r.Get("/api/deposits", handleGetDeposits)
+                r.Get("/api/deposit-addresses", handleGetDepositAddresses)
+                r.Post("/api/withdrawals", handleCreateWithdrawal)
+                r.Get("/api/withdrawals", handleGetWithdrawals)
+                r.Get("/api/cooldowns", handleGetCooldowns)
+                
+                // KYC routes
+                r.Post("/api/kyc", handleSubmitKYC)
+                r.Get("/api/kyc", handleGetKYC)
                 
                 // Referral routes
                 r.Get("/api/referrals", handleReferrals)
+                
+                // Admin routes
+                r.Group(func(r chi.Router) {
+                        r.Use(adminMiddleware)
+                        
+                        r.Post("/api/admin/btc/price", handleSetBTCPrice)
+                        
+                        r.Get("/api/admin/kyc", handleAdminKYCQueue)
+                        r.Get("/api/admin/kyc/documents/{id}", handleAdminKYCDocument)
+                        r.Post("/api/admin/kyc/{id}/approve", handleAdminApproveKYC)
+                        r.Post("/api/admin/kyc/{id}/reject", handleAdminRejectKYC)
+                        
+                        r.Get("/api/admin/deposit-addresses", handleAdminDepositAddresses)
+                        r.Post("/api/admin/deposit-addresses", handleAdminRotateDepositAddress)
+                        r.Get("/api/admin/deposit-addresses/{network}/history", handleAdminDepositAddressHistory)
+                        r.Post("/api/admin/deposits/detected", handleAdminDetectedDeposit)
+                        
+                        r.Get("/api/admin/miners", handleAdminMiners)
+                        r.Post("/api/admin/users/{id}/pause-mining", handleAdminPauseMining)
+                        r.Post("/api/admin/users/{id}/resume-mining", handleAdminResumeMining)
+                        
+                        r.Get("/api/admin/jobs", handleAdminListJobs)
+                        r.Get("/api/admin/jobs/{name}/runs", handleAdminJobRuns)
+                        r.Post("/api/admin/jobs/{name}/run", handleAdminRunJob)
+                        
+                        r.Post("/api/settings", handleSetSetting)
+                        r.Get("/api/admin/settings", handleAdminListSettings)
+                        r.Put("/api/admin/settings/{key}", handleAdminUpdateSetting)
+                        r.Delete("/api/admin/settings/{key}", handleAdminResetSetting)
+                        r.Get("/api/admin/settings/{key}/history", handleAdminSettingHistory)
+                        
+                        r.Get("/api/admin/simulation", handleAdminSimulation)
+                        r.Post("/api/admin/simulation/advance", handleAdminSimulationAdvance)
+                })
         })
 
         // Test endpoint
@@ -0,0 +1,389 @@
+package main
+
+import (
+        "context"
+        "fmt"
+        "net/http"
+        "strconv"
+        "strings"
+        "time"
+
+        "github.com/go-chi/chi/v5"
+        "github.com/jackc/pgx/v4"
+        "github.com/shopspring/decimal"
+)
+
+// settingMaxMissedClaims is the number of consecutive claim windows a miner
+// can miss before being marked inactive
+const settingMaxMissedClaims = "maxMissedClaims"
+
+const defaultMaxMissedClaims = 3
+
+// MinerActivity represents the miner_activity table
+type MinerActivity struct {
+        ID            string     `json:"id" db:"id"`
+        UserID        string     `json:"userId" db:"user_id"`
+        LastClaimTime *time.Time `json:"lastClaimTime" db:"last_claim_time"`
+        TotalClaims   int        `json:"totalClaims" db:"total_claims"`
+        MissedClaims  int        `json:"missedClaims" db:"missed_claims"`
+        IsActive      bool       `json:"isActive" db:"is_active"`
+        ResumedAt     *time.Time `json:"resumedAt" db:"resumed_at"`
+        UpdatedAt     time.Time  `json:"updatedAt" db:"updated_at"`
+}
+
+// recordClaimActivity records a claim in miner_activity and moves the user's
+// last active block to the current height. A claim also reactivates an
+// inactive miner.
+func recordClaimActivity(ctx context.Context, q rowQuerier, userID string, now time.Time) error {
+        height, err := getIntSetting(ctx, q, settingTotalBlockHeight, 0)
+        if err != nil {
+                return err
+        }
+
+        err = q.QueryRow(ctx, `
+                INSERT INTO miner_activity (user_id, last_claim_time, total_claims, missed_claims, is_active, updated_at)
+                VALUES ($1, $2, 1, 0, true, $2)
+                ON CONFLICT (user_id) DO UPDATE
+                SET last_claim_time = EXCLUDED.last_claim_time,
+                    total_claims = COALESCE(miner_activity.total_claims, 0) + 1,
+                    missed_claims = 0, is_active = true, updated_at = EXCLUDED.updated_at
+                RETURNING user_id`, userID, now).Scan(&userID)
+        if err != nil {
+                return fmt.Errorf("failed to record claim activity: %w", err)
+        }
+
+        err = q.QueryRow(ctx, "UPDATE users SET last_active_block = $1 WHERE id = $2 RETURNING id", height, userID).Scan(&userID)
+        if err != nil {
+                return fmt.Errorf("failed to update last active block: %w", err)
+        }
+        return nil
+}
+
+// MiningPauseRequest is the body of the admin pause endpoint
+type MiningPauseRequest struct {
+        Reason string `json:"reason" validate:"required,max=500"`
+}
+
+// startMining marks the user as mining. For a miner deactivated for missing
+// claims it resets the missed count so they share rewards again. Users paused
+// by an admin cannot start mining until the pause is lifted.
+func startMining(ctx context.Context, userID string, now time.Time) (resumed bool, err error) {
+        tx, err := db.Begin(ctx)
+        if err != nil {
+                return false, fmt.Errorf("failed to begin transaction: %w", err)
+        }
+        defer tx.Rollback(ctx)
+
+        var pauseReason *string
+        var pausedAt *time.Time
+        err = tx.QueryRow(ctx, "SELECT mining_paused_at, mining_pause_reason FROM users WHERE id = $1 FOR UPDATE", userID).
+                Scan(&pausedAt, &pauseReason)
+        if err != nil {
+                return false, fmt.Errorf("failed to lock user: %w", err)
+        }
+        if pausedAt != nil {
+                message := "Mining is paused by an administrator"
+                if pauseReason != nil {
+                        message += ": " + *pauseReason
+                }
+                return false, newAPIError(ErrCodeInvalidState, message)
+        }
+
+        if _, err := tx.Exec(ctx, "UPDATE users SET has_started_mining = true WHERE id = $1", userID); err != nil {
+                return false, fmt.Errorf("failed to start mining: %w", err)
+        }
+
+        tag, err := tx.Exec(ctx, `
+                UPDATE miner_activity
+                SET is_active = true, missed_claims = 0, resumed_at = $2, updated_at = $2
+                WHERE user_id = $1 AND is_active = false`, userID, now)
+        if err != nil {
+                return false, fmt.Errorf("failed to resume miner: %w", err)
+        }
+
+        if err := tx.Commit(ctx); err != nil {
+                return false, fmt.Errorf("failed to commit start mining: %w", err)
+        }
+        return tag.RowsAffected() > 0, nil
+}
+
+// stopMining stops the user mining; they share no further block rewards
+// until they start again
+func stopMining(ctx context.Context, userID string) error {
+        tag, err := db.Exec(ctx, "UPDATE users SET has_started_mining = false WHERE id = $1 AND has_started_mining = true", userID)
+        if err != nil {
+                return fmt.Errorf("failed to stop mining: %w", err)
+        }
+        if tag.RowsAffected() == 0 {
+                return newAPIError(ErrCodeInvalidState, "Mining is not started")
+        }
+        return nil
+}
+
+// setMiningPause pauses or, with a nil reason, unpauses a user's mining. A
+// paused user is excluded from reward distribution whether or not they have
+// started mining.
+func setMiningPause(ctx context.Context, userID, adminID string, reason *string, now time.Time) error {
+        tx, err := db.Begin(ctx)
+        if err != nil {
+                return fmt.Errorf("failed to begin transaction: %w", err)
+        }
+        defer tx.Rollback(ctx)
+
+        var paused bool
+        err = tx.QueryRow(ctx, "SELECT mining_paused_at IS NOT NULL FROM users WHERE id = $1 FOR UPDATE", userID).Scan(&paused)
+        if err == pgx.ErrNoRows {
+                return newAPIError(ErrCodeNotFound, "User not found")
+        }
+        if err != nil {
+                return fmt.Errorf("failed to lock user: %w", err)
+        }
+
+        if reason != nil {
+                if paused {
+                        return newAPIError(ErrCodeInvalidState, "Mining is already paused")
+                }
+                _, err = tx.Exec(ctx, `
+                        UPDATE users SET mining_paused_at = $2, mining_pause_reason = $3, mining_paused_by = $4
+                        WHERE id = $1`, userID, now, *reason, adminID)
+        } else {
+                if !paused {
+                        return newAPIError(ErrCodeInvalidState, "Mining is not paused")
+                }
+                _, err = tx.Exec(ctx, `
+                        UPDATE users SET mining_paused_at = NULL, mining_pause_reason = NULL, mining_paused_by = NULL
+                        WHERE id = $1`, userID)
+        }
+        if err != nil {
+                return fmt.Errorf("failed to update mining pause: %w", err)
+        }
+
+        if err := tx.Commit(ctx); err != nil {
+                return fmt.Errorf("failed to commit mining pause: %w", err)
+        }
+        return nil
+}
+
+// Stop mining endpoint
+func handleStopMining(w http.ResponseWriter, r *http.Request) {
+        user := getUserFromContext(r.Context())
+        if user == nil {
+                writeErrorResponse(w, r, ErrCodeUnauthorized, "Unauthorized")
+                return
+        }
+
+        if err := stopMining(r.Context(), user.ID); err != nil {
+                writeAPIError(w, r, err)
+                return
+        }
+
+        writeJSONResponse(w, http.StatusOK, map[string]string{"message": "Mining stopped successfully"})
+}
+
+// Admin pause mining endpoint
+func handleAdminPauseMining(w http.ResponseWriter, r *http.Request) {
+        admin := getUserFromContext(r.Context())
+        userID := chi.URLParam(r, "id")
+        if !isUUID(userID) {
+                writeErrorResponse(w, r, ErrCodeNotFound, "User not found")
+                return
+        }
+
+        var req MiningPauseRequest
+        if err := decodeAndValidate(w, r, &req); err != nil {
+                writeAPIError(w, r, err)
+                return
+        }
+
+        reason := strings.TrimSpace(req.Reason)
+        if err := setMiningPause(r.Context(), userID, admin.ID, &reason, clock.Now()); err != nil {
+                writeAPIError(w, r, err)
+                return
+        }
+
+        writeJSONResponse(w, http.StatusOK, map[string]string{"message": "Mining paused"})
+}
+
+// Admin resume mining endpoint
+func handleAdminResumeMining(w http.ResponseWriter, r *http.Request) {
+        admin := getUserFromContext(r.Context())
+        userID := chi.URLParam(r, "id")
+        if !isUUID(userID) {
+                writeErrorResponse(w, r, ErrCodeNotFound, "User not found")
+                return
+        }
+
+        if err := setMiningPause(r.Context(), userID, admin.ID, nil, clock.Now()); err != nil {
+                writeAPIError(w, r, err)
+                return
+        }
+
+        writeJSONResponse(w, http.StatusOK, map[string]string{"message": "Mining resumed"})
+}
+
+// updateMissedClaims counts, for every miner with rewards left unclaimed
+// since their last claim or resume, how many claim windows have passed since
+// the oldest of them, and deactivates miners who reached the limit. It
+// returns the number of miners deactivated.
+func updateMissedClaims(ctx context.Context, now time.Time) (int, error) {
+        claimWindow, err := getIntSetting(ctx, db, settingClaimWindowHours, defaultClaimWindowHours)
+        if err != nil {
+                return 0, err
+        }
+        maxMissed, err := getIntSetting(ctx, db, settingMaxMissedClaims, defaultMaxMissedClaims)
+        if err != nil {
+                return 0, err
+        }
+        if claimWindow <= 0 || maxMissed <= 0 {
+                return 0, fmt.Errorf("settings %s and %s must be positive", settingClaimWindowHours, settingMaxMissedClaims)
+        }
+
+        rows, err := db.Query(ctx, `
+                WITH missed AS (
+                        SELECT b.user_id,
+                               FLOOR(EXTRACT(EPOCH FROM $1::timestamp - MIN(b.created_at)) / $2)::int AS windows
+                        FROM unclaimed_blocks b
+                        LEFT JOIN miner_activity ma ON ma.user_id = b.user_id
+                        WHERE b.claimed = false
+                          AND b.created_at > COALESCE(GREATEST(ma.last_claim_time, ma.resumed_at), '-infinity')
+                        GROUP BY b.user_id
+                )
+                INSERT INTO miner_activity (user_id, missed_claims, is_active, updated_at)
+                SELECT user_id, windows, windows < $3, $1 FROM missed
+                ON CONFLICT (user_id) DO UPDATE
+                SET missed_claims = EXCLUDED.missed_claims, is_active = EXCLUDED.is_active, updated_at = EXCLUDED.updated_at
+                WHERE miner_activity.missed_claims IS DISTINCT FROM EXCLUDED.missed_claims
+                   OR miner_activity.is_active IS DISTINCT FROM EXCLUDED.is_active
+                RETURNING is_active`, now, claimWindow*3600, maxMissed)
+        if err != nil {
+                return 0, fmt.Errorf("failed to update missed claims: %w", err)
+        }
+        defer rows.Close()
+
+        deactivated := 0
+        for rows.Next() {
+                var active bool
+                if err := rows.Scan(&active); err != nil {
+                        return 0, fmt.Errorf("failed to scan miner activity: %w", err)
+                }
+                if !active {
+                        deactivated++
+                }
+        }
+        return deactivated, rows.Err()
+}
+
+// Admin miners endpoint. Filters: status (active, inactive), search
+// (username prefix), minMissed, limit and offset.
+func handleAdminMiners(w http.ResponseWriter, r *http.Request) {
+        query := r.URL.Query()
+
+        conditions := []string{"true"}
+        var args []interface{}
+        addArg := func(v interface{}) string {
+                args = append(args, v)
+                return "$" + strconv.Itoa(len(args))
+        }
+
+        switch status := query.Get("status"); status {
+        case "":
+        case "active", "inactive":
+                conditions = append(conditions, "ma.is_active = "+addArg(status == "active"))
+        default:
+                writeErrorResponse(w, r, ErrCodeValidation, "status must be one of: active, inactive")
+                return
+        }
+        if search := strings.TrimSpace(query.Get("search")); search != "" {
+                escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(search)
+                conditions = append(conditions, "u.username ILIKE "+addArg(escaped+"%"))
+        }
+        if v := query.Get("minMissed"); v != "" {
+                n, err := strconv.Atoi(v)
+                if err != nil || n < 0 {
+                        writeErrorResponse(w, r, ErrCodeValidation, "minMissed must be a non-negative integer")
+                        return
+                }
+                conditions = append(conditions, "ma.missed_claims >= "+addArg(n))
+        }
+
+        limit := 100
+        if v := query.Get("limit"); v != "" {
+                n, err := strconv.Atoi(v)
+                if err != nil || n < 1 || n > 1000 {
+                        writeErrorResponse(w, r, ErrCodeValidation, "limit must be between 1 and 1000")
+                        return
+                }
+                limit = n
+        }
+        offset := 0
+        if v := query.Get("offset"); v != "" {
+                n, err := strconv.Atoi(v)
+                if err != nil || n < 0 {
+                        writeErrorResponse(w, r, ErrCodeValidation, "offset must be a non-negative integer")
+                        return
+                }
+                offset = n
+        }
+
+        where := strings.Join(conditions, " AND ")
+        var total int64
+        err := db.QueryRow(r.Context(), `
+                SELECT COUNT(*) FROM miner_activity ma JOIN users u ON u.id = ma.user_id
+                WHERE `+where, args...).Scan(&total)
+        if err != nil {
+                writeErrorResponse(w, r, ErrCodeInternal, "Failed to load miners")
+                return
+        }
+
+        rows, err := db.Query(r.Context(), `
+                SELECT ma.id, ma.user_id, ma.last_claim_time, COALESCE(ma.total_claims, 0), COALESCE(ma.missed_claims, 0),
+                       COALESCE(ma.is_active, true), ma.resumed_at, ma.updated_at,
+                       u.username, u.hash_power, u.unclaimed_balance, u.has_started_mining, u.last_active_block
+                FROM miner_activity ma
+                JOIN users u ON u.id = ma.user_id
+                WHERE `+where+`
+                ORDER BY ma.missed_claims DESC, ma.last_claim_time DESC NULLS LAST, u.username
+                LIMIT `+addArg(limit)+` OFFSET `+addArg(offset), args...)
+        if err != nil {
+                writeErrorResponse(w, r, ErrCodeInternal, "Failed to load miners")
+                return
+        }
+        defer rows.Close()
+
+        miners := make([]map[string]interface{}, 0)
+        for rows.Next() {
+                var a MinerActivity
+                var username string
+                var hashPower, unclaimed decimal.Decimal
+                var hasStartedMining bool
+                var lastActiveBlock *int
+                if err := rows.Scan(&a.ID, &a.UserID, &a.LastClaimTime, &a.TotalClaims, &a.MissedClaims, &a.IsActive,
+                        &a.ResumedAt, &a.UpdatedAt, &username, &hashPower, &unclaimed, &hasStartedMining, &lastActiveBlock); err != nil {
+                        writeErrorResponse(w, r, ErrCodeInternal, "Failed to load miners")
+                        return
+                }
+                miners = append(miners, map[string]interface{}{
+                        "id":            a.ID,
+                        "userId":        a.UserID,
+                        "lastClaimTime": a.LastClaimTime,
+                        "totalClaims":   a.TotalClaims,
+                        "missedClaims":  a.MissedClaims,
+                        "isActive":      a.IsActive,
+                        "resumedAt":     a.ResumedAt,
+                        "updatedAt":     a.UpdatedAt,
+                        "user": map[string]interface{}{
+                                "username":         username,
+                                "hashPower":        formatHashPower(hashPower),
+                                "unclaimedBalance": formatAmount(unclaimed, CurrencyGBTC),
+                                "hasStartedMining": hasStartedMining,
+                                "lastActiveBlock":  lastActiveBlock,
+                        },
+                })
+        }
+        if rows.Err() != nil {
+                writeErrorResponse(w, r, ErrCodeInternal, "Failed to load miners")
+                return
+        }
+
+        writeJSONResponse(w, http.StatusOK, map[string]interface{}{"miners": miners, "total": total})
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+        "fmt"
+        "strings"
+
+        "github.com/shopspring/decimal"
+)
+
+// Currency identifies a balance the platform tracks
+type Currency string
+
+const (
+        CurrencyUSDT Currency = "USDT"
+        CurrencyBTC  Currency = "BTC"
+        CurrencyGBTC Currency = "GBTC"
+)
+
+// hashPowerScale matches users.hash_power numeric(10, 2)
+const hashPowerScale = 2
+
+// currencyScales holds the number of decimal places each currency is stored
+// with, matching the numeric columns in shared/schema.ts.
+var currencyScales = map[Currency]int32{
+        CurrencyUSDT: 2,
+        CurrencyBTC:  8,
+        CurrencyGBTC: 8,
+}
+
+// scaleOf returns the storage scale of a currency
+func scaleOf(currency Currency) (int32, error) {
+        scale, ok := currencyScales[currency]
+        if !ok {
+                return 0, fmt.Errorf("unknown currency %q", currency)
+        }
+        return scale, nil
+}
+
+// parseCurrency normalizes a currency name and checks that it is supported
+func parseCurrency(s string) (Currency, error) {
+        currency := Currency(strings.ToUpper(strings.TrimSpace(s)))
+        if _, err := scaleOf(currency); err != nil {
+                return "", newAPIError(ErrCodeValidation, fmt.Sprintf("Unsupported currency %q", s))
+        }
+        return currency, nil
+}
+
+// parseAmount parses a positive decimal string for the given currency and
+// rejects values with more decimal places than the currency is stored with.
+func parseAmount(s string, currency Currency) (decimal.Decimal, error) {
+        scale, err := scaleOf(currency)
+        if err != nil {
+                return decimal.Zero, err
+        }
+
+        amount, err := decimal.NewFromString(strings.TrimSpace(s))
+        if err != nil {
+                return decimal.Zero, newAPIError(ErrCodeValidation, fmt.Sprintf("Invalid %s amount", currency))
+        }
+        if !amount.IsPositive() {
+                return decimal.Zero, newAPIError(ErrCodeValidation, "Amount must be greater than zero")
+        }
+        if !amount.Equal(amount.Truncate(scale)) {
+                return decimal.Zero, newAPIError(ErrCodeValidation,
+                        fmt.Sprintf("%s amounts support at most %d decimal places", currency, scale))
+        }
+        return amount, nil
+}
+
+// formatAmount renders an amount with the fixed scale of its currency
+func formatAmount(amount decimal.Decimal, currency Currency) string {
+        scale, err := scaleOf(currency)
+        if err != nil {
+                return amount.String()
+        }
+        return amount.StringFixed(scale)
+}
+
+// formatHashPower renders hash power with the scale of users.hash_power
+func formatHashPower(hashPower decimal.Decimal) string {
+        return hashPower.StringFixed(hashPowerScale)
+}
+
+// balanceColumns maps each currency to its users balance column
+var balanceColumns = map[Currency]string{
+        CurrencyUSDT: "usdt_balance",
+        CurrencyBTC:  "btc_balance",
+        CurrencyGBTC: "gbtc_balance",
+}
+
+// balanceColumn returns the users column holding a currency's balance
+func balanceColumn(currency Currency) (string, error) {
+        column, ok := balanceColumns[currency]
+        if !ok {
+                return "", fmt.Errorf("unknown currency %q", currency)
+        }
+        return column, nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+        "testing"
+
+        "github.com/shopspring/decimal"
+)
+
+func TestParseAmount(t *testing.T) {
+        tests := []struct {
+                in       string
+                currency Currency
+                want     string
+                wantErr  string
+        }{
+                {in: "10", currency: CurrencyUSDT, want: "10"},
+                {in: " 10.25 ", currency: CurrencyUSDT, want: "10.25"},
+                {in: "10.250", currency: CurrencyUSDT, want: "10.25"},
+                {in: "10.255", currency: CurrencyUSDT, wantErr: "USDT amounts support at most 2 decimal places"},
+                {in: "0.00000001", currency: CurrencyBTC, want: "0.00000001"},
+                {in: "0.000000001", currency: CurrencyBTC, wantErr: "BTC amounts support at most 8 decimal places"},
+                {in: "123456789.12345678", currency: CurrencyGBTC, want: "123456789.12345678"},
+                {in: "0", currency: CurrencyUSDT, wantErr: "Amount must be greater than zero"},
+                {in: "-5", currency: CurrencyBTC, wantErr: "Amount must be greater than zero"},
+                {in: "1,000", currency: CurrencyUSDT, wantErr: "Invalid USDT amount"},
+                {in: "", currency: CurrencyUSDT, wantErr: "Invalid USDT amount"},
+        }
+        for _, tt := range tests {
+                t.Run(string(tt.currency)+" "+tt.in, func(t *testing.T) {
+                        got, err := parseAmount(tt.in, tt.currency)
+                        if tt.wantErr != "" {
+                                apiErr, ok := err.(*APIError)
+                                if !ok || apiErr.Code != ErrCodeValidation || apiErr.Message != tt.wantErr {
+                                        t.Fatalf("err = %v, want %s: %s", err, ErrCodeValidation, tt.wantErr)
+                                }
+                                return
+                        }
+                        if err != nil {
+                                t.Fatalf("parseAmount: %v", err)
+                        }
+                        if !got.Equal(decimal.RequireFromString(tt.want)) {
+                                t.Errorf("got %s, want %s", got, tt.want)
+                        }
+                })
+        }
+}
+
+func TestParseAmountUnknownCurrency(t *testing.T) {
+        if _, err := parseAmount("1", Currency("DOGE")); err == nil {
+                t.Error("parsed an amount of an unknown currency")
+        }
+}
+
+func TestParseCurrency(t *testing.T) {
+        for in, want := range map[string]Currency{"usdt": CurrencyUSDT, " BTC ": CurrencyBTC, "Gbtc": CurrencyGBTC} {
+                got, err := parseCurrency(in)
+                if err != nil || got != want {
+                        t.Errorf("parseCurrency(%q) = %q, %v, want %q", in, got, err, want)
+                }
+        }
+        if _, err := parseCurrency("HASH"); err == nil {
+                t.Error("parseCurrency accepted HASH")
+        }
+}
+
+func TestFormatAmount(t *testing.T) {
+        tests := []struct {
+                amount   string
+                currency Currency
+                want     string
+        }{
+                {"1", CurrencyUSDT, "1.00"},
+                {"1.5", CurrencyBTC, "1.50000000"},
+                {"0.00000001", CurrencyGBTC, "0.00000001"},
+                {"-2.1", CurrencyUSDT, "-2.10"},
+                {"1.23", Currency("HASH"), "1.23"},
+        }
+        for _, tt := range tests {
+                if got := formatAmount(decimal.RequireFromString(tt.amount), tt.currency); got != tt.want {
+                        t.Errorf("formatAmount(%s, %s) = %s, want %s", tt.amount, tt.currency, got, tt.want)
+                }
+        }
+        if got := formatHashPower(decimal.RequireFromString("12.5")); got != "12.50" {
+                t.Errorf("formatHashPower(12.5) = %s, want 12.50", got)
+        }
+}
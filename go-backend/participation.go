@@ -0,0 +1,160 @@
+package main
+
+import (
+        "context"
+        "fmt"
+        "net/http"
+        "strconv"
+        "time"
+
+        "github.com/jackc/pgx/v4"
+        "github.com/shopspring/decimal"
+)
+
+// Reasons a user with hash power was not eligible for a block
+const (
+        participationPaused   = "paused"
+        participationStopped  = "stopped"
+        participationInactive = "inactive"
+)
+
+const (
+        settingParticipationRetention = "participationRetentionDays"
+
+        defaultParticipationRetentionDays = 30
+)
+
+// BlockParticipation represents the block_participation table. Every user
+// with hash power gets a row per block recording whether they were eligible
+// for its reward and, if not, why.
+type BlockParticipation struct {
+        ID          string          `json:"id" db:"id"`
+        BlockHeight int64           `json:"blockHeight" db:"block_height"`
+        UserID      string          `json:"userId" db:"user_id"`
+        HashPower   decimal.Decimal `json:"hashPower" db:"hash_power"`
+        Eligible    bool            `json:"eligible" db:"eligible"`
+        Reason      *string         `json:"reason" db:"reason"`
+        Reward      decimal.Decimal `json:"reward" db:"reward"`
+        CreatedAt   time.Time       `json:"createdAt" db:"created_at"`
+}
+
+// recordBlockParticipation writes the participation rows of a block. It must
+// run after the block's rewards have been distributed.
+func recordBlockParticipation(ctx context.Context, tx pgx.Tx, height int64, at time.Time) error {
+        _, err := tx.Exec(ctx, `
+                INSERT INTO block_participation (block_height, user_id, hash_power, eligible, reason, reward, created_at)
+                        SELECT $1, u.id, u.hash_power, (`+eligibleMinerCondition+`),
+                               CASE
+                                   WHEN (`+eligibleMinerCondition+`) THEN NULL
+                                   WHEN u.mining_paused_at IS NOT NULL THEN $3
+                                   WHEN u.has_started_mining IS NOT TRUE THEN $4
+                                   ELSE $5
+                               END,
+                               COALESCE(b.reward, 0), $2
+                        FROM users u
+                        LEFT JOIN unclaimed_blocks b ON b.user_id = u.id AND b.block_height = $1
+                WHERE u.hash_power > 0`,
+                height, at, participationPaused, participationStopped, participationInactive)
+        if err != nil {
+                return fmt.Errorf("failed to record block participation: %w", err)
+        }
+        return nil
+}
+
+// pruneBlockParticipation deletes participation rows older than the retention
+// period. A row is written per block for every user with hash power, so the
+// table would otherwise grow by blocksPerDay rows per user every day.
+func pruneBlockParticipation(ctx context.Context, now time.Time) (int64, error) {
+        days, err := getIntSetting(ctx, db, settingParticipationRetention, defaultParticipationRetentionDays)
+        if err != nil {
+                return 0, err
+        }
+
+        tag, err := db.Exec(ctx, "DELETE FROM block_participation WHERE created_at < $1", now.AddDate(0, 0, -int(days)))
+        if err != nil {
+                return 0, fmt.Errorf("failed to prune block participation: %w", err)
+        }
+        return tag.RowsAffected(), nil
+}
+
+// Mining participation endpoint listing the blocks the user had hash power
+// for, newest first. Pass before=<height> to page back.
+func handleMiningParticipation(w http.ResponseWriter, r *http.Request) {
+        user := getUserFromContext(r.Context())
+        if user == nil {
+                writeErrorResponse(w, r, ErrCodeUnauthorized, "Unauthorized")
+                return
+        }
+
+        limit := blocksPerDay
+        if v := r.URL.Query().Get("limit"); v != "" {
+                n, err := strconv.Atoi(v)
+                if err != nil || n < 1 || n > 1000 {
+                        writeErrorResponse(w, r, ErrCodeValidation, "limit must be between 1 and 1000")
+                        return
+                }
+                limit = n
+        }
+        var before *int64
+        if v := r.URL.Query().Get("before"); v != "" {
+                n, err := strconv.ParseInt(v, 10, 64)
+                if err != nil || n < 1 {
+                        writeErrorResponse(w, r, ErrCodeValidation, "before must be a positive block height")
+                        return
+                }
+                before = &n
+        }
+
+        ctx := r.Context()
+        var pausedAt *time.Time
+        var pauseReason *string
+        err := db.QueryRow(ctx, "SELECT mining_paused_at, mining_pause_reason FROM users WHERE id = $1", user.ID).
+                Scan(&pausedAt, &pauseReason)
+        if err != nil {
+                writeErrorResponse(w, r, ErrCodeInternal, "Failed to load participation")
+                return
+        }
+
+        rows, err := db.Query(ctx, `
+                SELECT id, block_height, user_id, hash_power, eligible, reason, reward, created_at
+                FROM block_participation
+                WHERE user_id = $1 AND ($2::bigint IS NULL OR block_height < $2)
+                ORDER BY block_height DESC
+                LIMIT $3`, user.ID, before, limit)
+        if err != nil {
+                writeErrorResponse(w, r, ErrCodeInternal, "Failed to load participation")
+                return
+        }
+        defer rows.Close()
+
+        participation := make([]map[string]interface{}, 0)
+        for rows.Next() {
+                var p BlockParticipation
+                if err := rows.Scan(&p.ID, &p.BlockHeight, &p.UserID, &p.HashPower, &p.Eligible, &p.Reason,
+                        &p.Reward, &p.CreatedAt); err != nil {
+                        writeErrorResponse(w, r, ErrCodeInternal, "Failed to load participation")
+                        return
+                }
+                participation = append(participation, map[string]interface{}{
+                        "blockHeight": p.BlockHeight,
+                        "hashPower":   formatHashPower(p.HashPower),
+                        "eligible":    p.Eligible,
+                        "reason":      p.Reason,
+                        "reward":      formatAmount(p.Reward, CurrencyGBTC),
+                        "createdAt":   p.CreatedAt,
+                })
+        }
+        if rows.Err() != nil {
+                writeErrorResponse(w, r, ErrCodeInternal, "Failed to load participation")
+                return
+        }
+
+        var pause interface{}
+        if pausedAt != nil {
+                pause = map[string]interface{}{"pausedAt": pausedAt, "reason": pauseReason}
+        }
+        writeJSONResponse(w, http.StatusOK, map[string]interface{}{
+                "participation": participation,
+                "pause":         pause,
+        })
+}
@@ -0,0 +1,405 @@
+package main
+
+import (
+        "context"
+        "encoding/json"
+        "fmt"
+        "net/http"
+        "os"
+        "strings"
+        "sync"
+        "time"
+
+        "github.com/jackc/pgx/v4"
+        "github.com/shopspring/decimal"
+)
+
+// Price sources recorded in btc_price_history.source
+const (
+        priceSourceSystem = "system"
+        priceSourceManual = "manual"
+        priceSourceAPI    = "api"
+)
+
+const (
+        settingPriceMaxAge = "btcPriceMaxAgeMinutes"
+
+        defaultPriceMaxAgeMinutes = 30
+        defaultPriceURL           = "https://api.coingecko.com/api/v3/simple/price?ids=bitcoin&vs_currencies=usd"
+        defaultPriceField         = "bitcoin.usd"
+        defaultPricePollInterval  = 5 * time.Minute
+
+        // maxPriceHistoryBuckets caps the number of OHLC candles per request
+        maxPriceHistoryBuckets = 1000
+)
+
+// defaultBTCPrice is shown until a price has been recorded in btc_price_history
+var defaultBTCPrice = decimal.NewFromInt(95000)
+
+// priceIntervals maps the supported OHLC bucket sizes to their length
+var priceIntervals = map[string]time.Duration{
+        "5m":  5 * time.Minute,
+        "15m": 15 * time.Minute,
+        "1h":  time.Hour,
+        "4h":  4 * time.Hour,
+        "1d":  24 * time.Hour,
+}
+
+// PriceSource supplies the current BTC price in USDT
+type PriceSource interface {
+        Name() string
+        FetchBTCPrice(ctx context.Context) (decimal.Decimal, error)
+}
+
+// PriceQuote is a BTC price recorded in btc_price_history
+type PriceQuote struct {
+        Price     decimal.Decimal `json:"price"`
+        Source    string          `json:"source"`
+        Timestamp time.Time       `json:"timestamp"`
+}
+
+// ManualPriceSource returns the last price an admin set
+type ManualPriceSource struct {
+        mu    sync.Mutex
+        price decimal.Decimal
+}
+
+func (s *ManualPriceSource) Name() string { return priceSourceManual }
+
+func (s *ManualPriceSource) Set(price decimal.Decimal) {
+        s.mu.Lock()
+        defer s.mu.Unlock()
+        s.price = price
+}
+
+func (s *ManualPriceSource) FetchBTCPrice(ctx context.Context) (decimal.Decimal, error) {
+        s.mu.Lock()
+        defer s.mu.Unlock()
+        if !s.price.IsPositive() {
+                return decimal.Zero, fmt.Errorf("no manual price has been set")
+        }
+        return s.price, nil
+}
+
+// HTTPPriceSource reads the price from a JSON HTTP endpoint. Field is a
+// dot-separated path to the price inside the response, e.g. "bitcoin.usd".
+type HTTPPriceSource struct {
+        URL    string
+        Field  string
+        Client *http.Client
+}
+
+// newHTTPPriceSourceFromEnv builds the external source from BTC_PRICE_URL and
+// BTC_PRICE_FIELD, defaulting to CoinGecko.
+func newHTTPPriceSourceFromEnv() *HTTPPriceSource {
+        url := os.Getenv("BTC_PRICE_URL")
+        if url == "" {
+                url = defaultPriceURL
+        }
+        field := os.Getenv("BTC_PRICE_FIELD")
+        if field == "" {
+                field = defaultPriceField
+        }
+        return &HTTPPriceSource{URL: url, Field: field, Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (s *HTTPPriceSource) Name() string { return priceSourceAPI }
+
+func (s *HTTPPriceSource) FetchBTCPrice(ctx context.Context) (decimal.Decimal, error) {
+        req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL, nil)
+        if err != nil {
+                return decimal.Zero, fmt.Errorf("failed to build price request: %w", err)
+        }
+        req.Header.Set("Accept", "application/json")
+
+        resp, err := s.Client.Do(req)
+        if err != nil {
+                return decimal.Zero, fmt.Errorf("failed to fetch price: %w", err)
+        }
+        defer resp.Body.Close()
+
+        if resp.StatusCode != http.StatusOK {
+                return decimal.Zero, fmt.Errorf("price source returned %s", resp.Status)
+        }
+
+        var body interface{}
+        decoder := json.NewDecoder(resp.Body)
+        decoder.UseNumber()
+        if err := decoder.Decode(&body); err != nil {
+                return decimal.Zero, fmt.Errorf("failed to decode price response: %w", err)
+        }
+
+        for _, key := range strings.Split(s.Field, ".") {
+                obj, ok := body.(map[string]interface{})
+                if !ok {
+                        return decimal.Zero, fmt.Errorf("price field %q not found", s.Field)
+                }
+                body = obj[key]
+        }
+
+        var price decimal.Decimal
+        switch v := body.(type) {
+        case json.Number:
+                price, err = decimal.NewFromString(v.String())
+        case string:
+                price, err = decimal.NewFromString(v)
+        default:
+                err = fmt.Errorf("price field %q not found", s.Field)
+        }
+        if err != nil {
+                return decimal.Zero, err
+        }
+        if !price.IsPositive() {
+                return decimal.Zero, fmt.Errorf("price source returned non-positive price %s", price)
+        }
+        return price, nil
+}
+
+// manualPrices backs the admin price endpoint
+var manualPrices = &ManualPriceSource{}
+
+// recordBTCPrice persists a price update to btc_price_history
+func recordBTCPrice(ctx context.Context, price decimal.Decimal, source string) (*PriceQuote, error) {
+        quote := PriceQuote{Price: price.Round(2), Source: source}
+        err := db.QueryRow(ctx,
+                "INSERT INTO btc_price_history (price, source, timestamp) VALUES ($1, $2, $3) RETURNING timestamp",
+                quote.Price.StringFixed(2), source, clock.Now()).Scan(&quote.Timestamp)
+        if err != nil {
+                return nil, fmt.Errorf("failed to record BTC price: %w", err)
+        }
+
+        err = publishEvent(ctx, db, eventPrice, "", map[string]interface{}{
+                "price":     quote.Price.StringFixed(2),
+                "source":    quote.Source,
+                "timestamp": quote.Timestamp,
+        })
+        if err != nil {
+                return nil, err
+        }
+        return &quote, nil
+}
+
+// updatePriceFrom fetches a price from source and records it
+func updatePriceFrom(ctx context.Context, source PriceSource) (*PriceQuote, error) {
+        price, err := source.FetchBTCPrice(ctx)
+        if err != nil {
+                return nil, fmt.Errorf("%s source: %w", source.Name(), err)
+        }
+        return recordBTCPrice(ctx, price, source.Name())
+}
+
+// getLatestBTCPrice returns the most recent recorded price, or nil if none exists
+func getLatestBTCPrice(ctx context.Context, q rowQuerier) (*PriceQuote, error) {
+        var quote PriceQuote
+        var source *string
+        err := q.QueryRow(ctx, "SELECT price, source, timestamp FROM btc_price_history ORDER BY timestamp DESC LIMIT 1").
+                Scan(&quote.Price, &source, &quote.Timestamp)
+        if err == pgx.ErrNoRows {
+                return nil, nil
+        }
+        if err != nil {
+                return nil, fmt.Errorf("failed to get BTC price: %w", err)
+        }
+        quote.Source = priceSourceSystem
+        if source != nil {
+                quote.Source = *source
+        }
+        return &quote, nil
+}
+
+// getCurrentBTCPrice returns the latest recorded BTC price in USDT for
+// display, falling back to the default when nothing has been recorded.
+func getCurrentBTCPrice(ctx context.Context, q rowQuerier) (decimal.Decimal, error) {
+        quote, err := getLatestBTCPrice(ctx, q)
+        if err != nil {
+                return decimal.Zero, err
+        }
+        if quote == nil {
+                return defaultBTCPrice, nil
+        }
+        return quote.Price, nil
+}
+
+// priceMaxAge returns how old a quote may be before it is considered stale
+func priceMaxAge(ctx context.Context, q rowQuerier) (time.Duration, error) {
+        minutes, err := getDecimalSetting(ctx, q, settingPriceMaxAge, decimal.NewFromInt(defaultPriceMaxAgeMinutes))
+        if err != nil {
+                return 0, err
+        }
+        return time.Duration(minutes.IntPart()) * time.Minute, nil
+}
+
+// getFreshBTCPrice returns the latest price for operations that move money
+// at that price. It fails with PRICE_UNAVAILABLE when the quote is stale.
+func getFreshBTCPrice(ctx context.Context, q rowQuerier) (*PriceQuote, error) {
+        quote, err := getLatestBTCPrice(ctx, q)
+        if err != nil {
+                return nil, err
+        }
+        if quote == nil {
+                return nil, newAPIError(ErrCodePriceUnavailable, "BTC price is not available yet")
+        }
+
+        maxAge, err := priceMaxAge(ctx, q)
+        if err != nil {
+                return nil, err
+        }
+        if clock.Now().Sub(quote.Timestamp) > maxAge {
+                return nil, newAPIError(ErrCodePriceUnavailable, "BTC price is stale; try again shortly")
+        }
+        return quote, nil
+}
+
+// BTC prices endpoint
+func handleBTCPrices(w http.ResponseWriter, r *http.Request) {
+        quote, err := getLatestBTCPrice(r.Context(), db)
+        if err != nil {
+                writeErrorResponse(w, r, ErrCodeInternal, "Failed to load BTC price")
+                return
+        }
+        if quote == nil {
+                quote = &PriceQuote{Price: defaultBTCPrice, Source: priceSourceSystem, Timestamp: clock.Now()}
+        }
+
+        hashratePrice, err := getDecimalSetting(r.Context(), db, settingHashPowerPrice, defaultHashPowerPrice)
+        if err != nil {
+                writeErrorResponse(w, r, ErrCodeInternal, "Failed to load hashrate price")
+                return
+        }
+
+        maxAge, err := priceMaxAge(r.Context(), db)
+        if err != nil {
+                writeErrorResponse(w, r, ErrCodeInternal, "Failed to load BTC price")
+                return
+        }
+
+        writeJSONResponse(w, http.StatusOK, map[string]interface{}{
+                "btcPrice":               quote.Price.StringFixed(2),
+                "hashratePrice":          hashratePrice.StringFixed(2),
+                "requiredHashratePerBTC": quote.Price.Div(hashratePrice).InexactFloat64(),
+                "source":                 quote.Source,
+                "timestamp":              quote.Timestamp,
+                "stale":                  clock.Now().Sub(quote.Timestamp) > maxAge,
+        })
+}
+
+// BTC price history endpoint
+func handleBTCPriceHistory(w http.ResponseWriter, r *http.Request) {
+        interval := r.URL.Query().Get("interval")
+        if interval == "" {
+                interval = "1h"
+        }
+        bucket, ok := priceIntervals[interval]
+        if !ok {
+                writeErrorResponse(w, r, ErrCodeValidation, "interval must be one of 5m, 15m, 1h, 4h, 1d")
+                return
+        }
+
+        to := clock.Now()
+        if v := r.URL.Query().Get("to"); v != "" {
+                t, err := time.Parse(time.RFC3339, v)
+                if err != nil {
+                        writeErrorResponse(w, r, ErrCodeValidation, "to must be an RFC 3339 timestamp")
+                        return
+                }
+                to = t.UTC()
+        }
+        from := to.Add(-24 * time.Hour)
+        if v := r.URL.Query().Get("from"); v != "" {
+                t, err := time.Parse(time.RFC3339, v)
+                if err != nil {
+                        writeErrorResponse(w, r, ErrCodeValidation, "from must be an RFC 3339 timestamp")
+                        return
+                }
+                from = t.UTC()
+        }
+        if !from.Before(to) {
+                writeErrorResponse(w, r, ErrCodeValidation, "from must be before to")
+                return
+        }
+        if to.Sub(from)/bucket > maxPriceHistoryBuckets {
+                writeErrorResponse(w, r, ErrCodeValidation, "Range is too large for the interval")
+                return
+        }
+
+        rows, err := db.Query(r.Context(), `
+                SELECT to_timestamp(floor(extract(epoch FROM timestamp) / $1) * $1) AS bucket,
+                       (array_agg(price ORDER BY timestamp ASC))[1] AS open,
+                       MAX(price) AS high,
+                       MIN(price) AS low,
+                       (array_agg(price ORDER BY timestamp DESC))[1] AS close,
+                       COUNT(*) AS samples
+                FROM btc_price_history
+                WHERE timestamp >= $2 AND timestamp < $3
+                GROUP BY bucket
+                ORDER BY bucket ASC`,
+                int64(bucket.Seconds()), from, to)
+        if err != nil {
+                writeErrorResponse(w, r, ErrCodeInternal, "Failed to load price history")
+                return
+        }
+        defer rows.Close()
+
+        candles := make([]map[string]interface{}, 0)
+        for rows.Next() {
+                var start time.Time
+                var open, high, low, closePrice decimal.Decimal
+                var samples int
+                if err := rows.Scan(&start, &open, &high, &low, &closePrice, &samples); err != nil {
+                        writeErrorResponse(w, r, ErrCodeInternal, "Failed to load price history")
+                        return
+                }
+                candles = append(candles, map[string]interface{}{
+                        "time":    start.UTC(),
+                        "open":    open.StringFixed(2),
+                        "high":    high.StringFixed(2),
+                        "low":     low.StringFixed(2),
+                        "close":   closePrice.StringFixed(2),
+                        "samples": samples,
+                })
+        }
+        if rows.Err() != nil {
+                writeErrorResponse(w, r, ErrCodeInternal, "Failed to load price history")
+                return
+        }
+
+        writeJSONResponse(w, http.StatusOK, map[string]interface{}{
+                "interval": interval,
+                "from":     from,
+                "to":       to,
+                "candles":  candles,
+        })
+}
+
+type SetBTCPriceRequest struct {
+        Price string `json:"price" validate:"required,decimal=2"`
+}
+
+// Admin manual BTC price endpoint
+func handleSetBTCPrice(w http.ResponseWriter, r *http.Request) {
+        var req SetBTCPriceRequest
+        if err := decodeAndValidate(w, r, &req); err != nil {
+                writeAPIError(w, r, err)
+                return
+        }
+
+        price, err := decimal.NewFromString(req.Price)
+        if err != nil {
+                writeErrorResponse(w, r, ErrCodeValidation, "Invalid price")
+                return
+        }
+
+        manualPrices.Set(price)
+        quote, err := updatePriceFrom(r.Context(), manualPrices)
+        if err != nil {
+                writeErrorResponse(w, r, ErrCodeInternal, "Failed to record BTC price")
+                return
+        }
+
+        writeJSONResponse(w, http.StatusOK, map[string]interface{}{
+                "message":   "BTC price updated",
+                "price":     quote.Price.StringFixed(2),
+                "source":    quote.Source,
+                "timestamp": quote.Timestamp,
+        })
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+        "context"
+        "errors"
+        "net/http"
+        "net/http/httptest"
+        "strings"
+        "testing"
+        "time"
+
+        "github.com/jackc/pgx/v4"
+        "github.com/shopspring/decimal"
+)
+
+func TestHTTPPriceSourceFetchBTCPrice(t *testing.T) {
+        tests := []struct {
+                name    string
+                status  int
+                body    string
+                want    string
+                wantErr string
+        }{
+                {
+                        name:   "number",
+                        status: http.StatusOK,
+                        body:   `{"bitcoin":{"usd":97123.45}}`,
+                        want:   "97123.45",
+                },
+                {
+                        name:   "string",
+                        status: http.StatusOK,
+                        body:   `{"bitcoin":{"usd":"97123.45"}}`,
+                        want:   "97123.45",
+                },
+                {
+                        name:    "missing field",
+                        status:  http.StatusOK,
+                        body:    `{"bitcoin":{"eur":90000}}`,
+                        wantErr: `price field "bitcoin.usd" not found`,
+                },
+                {
+                        name:    "missing object",
+                        status:  http.StatusOK,
+                        body:    `{"ethereum":{"usd":3000}}`,
+                        wantErr: `price field "bitcoin.usd" not found`,
+                },
+                {
+                        name:    "non-200",
+                        status:  http.StatusTooManyRequests,
+                        body:    `{"status":{"error_code":429}}`,
+                        wantErr: "429",
+                },
+                {
+                        name:    "non-numeric",
+                        status:  http.StatusOK,
+                        body:    `{"bitcoin":{"usd":"n/a"}}`,
+                        wantErr: "can't convert",
+                },
+                {
+                        name:    "not a number or string",
+                        status:  http.StatusOK,
+                        body:    `{"bitcoin":{"usd":true}}`,
+                        wantErr: `price field "bitcoin.usd" not found`,
+                },
+                {
+                        name:    "non-positive",
+                        status:  http.StatusOK,
+                        body:    `{"bitcoin":{"usd":0}}`,
+                        wantErr: "non-positive",
+                },
+                {
+                        name:    "invalid JSON",
+                        status:  http.StatusOK,
+                        body:    `<html>`,
+                        wantErr: "failed to decode",
+                },
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+                                if accept := r.Header.Get("Accept"); accept != "application/json" {
+                                        t.Errorf("Accept = %q", accept)
+                                }
+                                w.WriteHeader(tt.status)
+                                w.Write([]byte(tt.body))
+                        }))
+                        defer server.Close()
+
+                        source := &HTTPPriceSource{URL: server.URL, Field: defaultPriceField, Client: server.Client()}
+                        price, err := source.FetchBTCPrice(context.Background())
+                        if tt.wantErr != "" {
+                                if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+                                        t.Fatalf("err = %v, want it to contain %q", err, tt.wantErr)
+                                }
+                                return
+                        }
+                        if err != nil {
+                                t.Fatalf("FetchBTCPrice: %v", err)
+                        }
+                        if !price.Equal(decimal.RequireFromString(tt.want)) {
+                                t.Errorf("price = %s, want %s", price, tt.want)
+                        }
+                })
+        }
+}
+
+// scanRow is a pgx.Row backed by a scan function
+type scanRow func(dest ...interface{}) error
+
+func (f scanRow) Scan(dest ...interface{}) error { return f(dest...) }
+
+// priceQuerier answers the latest price and max age queries of
+// getFreshBTCPrice; a nil quote or empty maxAge reads as no rows
+type priceQuerier struct {
+        quote  *PriceQuote
+        maxAge string
+}
+
+func (q priceQuerier) QueryRow(_ context.Context, sql string, _ ...interface{}) pgx.Row {
+        switch {
+        case strings.Contains(sql, "btc_price_history"):
+                return scanRow(func(dest ...interface{}) error {
+                        if q.quote == nil {
+                                return pgx.ErrNoRows
+                        }
+                        source := q.quote.Source
+                        *dest[0].(*decimal.Decimal) = q.quote.Price
+                        *dest[1].(**string) = &source
+                        *dest[2].(*time.Time) = q.quote.Timestamp
+                        return nil
+                })
+        case strings.Contains(sql, "system_settings"):
+                return scanRow(func(dest ...interface{}) error {
+                        if q.maxAge == "" {
+                                return pgx.ErrNoRows
+                        }
+                        *dest[0].(*string) = q.maxAge
+                        return nil
+                })
+        }
+        return scanRow(func(...interface{}) error { return errors.New("unexpected query: " + sql) })
+}
+
+func TestGetFreshBTCPrice(t *testing.T) {
+        now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+        defer func(orig Clock) { clock = orig }(clock)
+        clock = NewSimulatedClock(now)
+
+        quoteAged := func(age time.Duration) *PriceQuote {
+                return &PriceQuote{Price: decimal.RequireFromString("97000.00"), Source: priceSourceAPI, Timestamp: now.Add(-age)}
+        }
+        tests := []struct {
+                name   string
+                q      priceQuerier
+                fresh  bool
+                reason string
+        }{
+                {name: "recent quote", q: priceQuerier{quote: quoteAged(10 * time.Minute)}, fresh: true},
+                {name: "at the default max age", q: priceQuerier{quote: quoteAged(defaultPriceMaxAgeMinutes * time.Minute)}, fresh: true},
+                {name: "past the default max age", q: priceQuerier{quote: quoteAged(31 * time.Minute)}, reason: "stale"},
+                {name: "past a configured max age", q: priceQuerier{quote: quoteAged(10 * time.Minute), maxAge: "5"}, reason: "stale"},
+                {name: "within a configured max age", q: priceQuerier{quote: quoteAged(50 * time.Minute), maxAge: "60"}, fresh: true},
+                {name: "no quote", q: priceQuerier{}, reason: "not available"},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        quote, err := getFreshBTCPrice(context.Background(), tt.q)
+                        if tt.fresh {
+                                if err != nil {
+                                        t.Fatalf("getFreshBTCPrice: %v", err)
+                                }
+                                if !quote.Price.Equal(tt.q.quote.Price) {
+                                        t.Errorf("price = %s, want %s", quote.Price, tt.q.quote.Price)
+                                }
+                                return
+                        }
+                        var apiErr *APIError
+                        if !errors.As(err, &apiErr) || apiErr.Code != ErrCodePriceUnavailable || !strings.Contains(apiErr.Message, tt.reason) {
+                                t.Errorf("err = %v, want %s (%s)", err, ErrCodePriceUnavailable, tt.reason)
+                        }
+                })
+        }
+}
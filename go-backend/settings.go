@@ -0,0 +1,663 @@
+package main
+
+import (
+        "context"
+        "encoding/json"
+        "fmt"
+        "net/http"
+        "sort"
+        "strconv"
+        "strings"
+        "sync"
+        "time"
+
+        "github.com/go-chi/chi/v5"
+        "github.com/jackc/pgx/v4"
+        "github.com/shopspring/decimal"
+)
+
+// rowQuerier is satisfied by both the pool and a transaction
+type rowQuerier interface {
+        QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
+// settingType is how a setting's value is parsed and validated
+type settingType string
+
+const (
+        settingTypeInt     settingType = "int"
+        settingTypeDecimal settingType = "decimal"
+        settingTypeString  settingType = "string"
+        settingTypeDate    settingType = "date"
+        settingTypeJSON    settingType = "json"
+)
+
+// settingDefinition describes a key in the settings registry. Min and Max
+// bound numeric settings; an empty Default means the key has none.
+type settingDefinition struct {
+        Key         string
+        Type        settingType
+        Default     string
+        Description string
+        Min, Max    string
+        // System settings are maintained by the block engine and cannot be edited
+        System bool
+        // Public settings can be read by any signed-in user
+        Public bool
+        // Check validates a value beyond its type
+        Check func(value string) error
+}
+
+// checkJSON returns a Check that a value decodes into the type newTarget returns
+func checkJSON(newTarget func() interface{}) func(string) error {
+        return func(value string) error {
+                decoder := json.NewDecoder(strings.NewReader(value))
+                decoder.DisallowUnknownFields()
+                return decoder.Decode(newTarget())
+        }
+}
+
+// mustJSON encodes a default value of a JSON setting
+func mustJSON(v interface{}) string {
+        data, err := json.Marshal(v)
+        if err != nil {
+                panic(fmt.Sprintf("settings: %v", err))
+        }
+        return string(data)
+}
+
+// settingDefinitions is the registry of every setting the Go backend reads
+var settingDefinitions = indexSettings([]*settingDefinition{
+        {Key: settingBlockReward, Type: settingTypeDecimal, Default: defaultBlockReward.String(), System: true, Public: true,
+                Description: "Reward of the next block, kept in step with the emission schedule"},
+        {Key: settingBlockNumber, Type: settingTypeInt, Default: "1", System: true, Public: true,
+                Description: "Number of the next block within the current day"},
+        {Key: settingTotalBlockHeight, Type: settingTypeInt, Default: "0", System: true, Public: true,
+                Description: "Height of the latest block"},
+        {Key: settingLastResetDate, Type: settingTypeDate, System: true,
+                Description: "UTC date of the last daily block number reset"},
+        {Key: settingClaimWindowHours, Type: settingTypeInt, Default: strconv.Itoa(defaultClaimWindowHours), Min: "1", Max: "168",
+                Public: true, Description: "Hours a block reward can be claimed before it expires"},
+        {Key: settingMaxMissedClaims, Type: settingTypeInt, Default: strconv.Itoa(defaultMaxMissedClaims), Min: "1", Max: "100",
+                Description: "Missed claim windows after which a miner is marked inactive"},
+        {Key: settingMaxSupply, Type: settingTypeDecimal, Default: defaultMaxSupply.String(), Min: "1", Public: true,
+                Description: "Maximum GBTC supply"},
+        {Key: settingInitialBlockReward, Type: settingTypeDecimal, Default: defaultBlockReward.String(), Min: "0.00000001",
+                Public: true, Description: "Block reward before the first halving"},
+        {Key: settingHalvingInterval, Type: settingTypeInt, Default: strconv.Itoa(defaultHalvingInterval), Min: "1", Public: true,
+                Description: "Blocks between block reward halvings"},
+        {Key: settingDifficultyInterval, Type: settingTypeInt, Default: strconv.Itoa(defaultDifficultyInterval), Min: "1",
+                Description: "Blocks between difficulty adjustments"},
+        {Key: settingDifficultyBaseHashPower, Type: settingTypeDecimal, Default: defaultDifficultyBaseHashPower.String(),
+                Min: "0.01", Description: "Network hash power at difficulty 1"},
+        {Key: settingHashPowerPrice, Type: settingTypeDecimal, Default: defaultHashPowerPrice.String(), Min: "0.00000001",
+                Public: true, Description: "USDT price of one unit of hash power"},
+        {Key: settingHashPowerTiers, Type: settingTypeJSON, Default: mustJSON(defaultPricingTiers), Public: true,
+                Description: "Volume discount tiers for hash power purchases",
+                Check:       checkJSON(func() interface{} { return &[]pricingTier{} })},
+        {Key: settingConversionFee, Type: settingTypeDecimal, Default: defaultConversionFee.String(), Min: "0", Max: "100",
+                Public: true, Description: "Conversion fee in percent"},
+        {Key: settingConversionQuoteTTL, Type: settingTypeDecimal, Default: defaultConversionQuoteTTL.String(), Min: "5",
+                Max: "600", Description: "Seconds a conversion quote stays valid"},
+        {Key: settingGBTCRate, Type: settingTypeDecimal, Min: "0", Public: true,
+                Description: "USDT price of GBTC for conversions; unset or zero disables GBTC conversions"},
+        {Key: settingPriceMaxAge, Type: settingTypeDecimal, Default: strconv.Itoa(defaultPriceMaxAgeMinutes), Min: "1",
+                Description: "Minutes after which a BTC price is too old to trade on"},
+        {Key: settingStakingAPR, Type: settingTypeDecimal, Default: defaultStakingAPR.String(), Min: "0", Max: "1000",
+                Public: true, Description: "Annual BTC staking return in percent"},
+        {Key: settingStakingCancelPenalty, Type: settingTypeDecimal, Default: defaultStakingCancelPenalty.String(), Min: "0",
+                Max: "100", Public: true, Description: "Percent of staked BTC principal forfeited on early cancel"},
+        {Key: settingTransferLimits, Type: settingTypeJSON, Default: mustJSON(defaultTransferLimits),
+                Description: "Daily GBTC transfer limits per KYC tier",
+                Check:       checkJSON(func() interface{} { return &map[string]transferLimit{} })},
+        {Key: fundingPolicySettings[fundingDeposit], Type: settingTypeJSON, Default: mustJSON(defaultFundingPolicies[fundingDeposit]),
+                Description: "Deposit cooldowns and caps per KYC tier",
+                Check:       checkJSON(func() interface{} { return &fundingPolicies{} })},
+        {Key: fundingPolicySettings[fundingWithdrawal], Type: settingTypeJSON,
+                Default:     mustJSON(defaultFundingPolicies[fundingWithdrawal]),
+                Description: "Withdrawal cooldowns and caps per KYC tier",
+                Check:       checkJSON(func() interface{} { return &fundingPolicies{} })},
+        {Key: settingJobRunRetention, Type: settingTypeInt, Default: strconv.Itoa(defaultJobRunRetentionDays), Min: "1",
+                Description: "Days finished scheduled job runs are kept"},
+        {Key: settingParticipationRetention, Type: settingTypeInt, Default: strconv.Itoa(defaultParticipationRetentionDays),
+                Min: "1", Description: "Days per-block mining participation records are kept"},
+        {Key: settingBTCDepositXpub, Type: settingTypeString, Check: checkDepositXpub(hdChainBTC),
+                Description: "Account xpub (BIP44) or zpub (BIP84) per-user BTC deposit addresses are derived from"},
+        {Key: settingEVMDepositXpub, Type: settingTypeString, Check: checkDepositXpub(hdChainEVM),
+                Description: "Account xpub (BIP44) per-user BSC and ETH deposit addresses are derived from"},
+        {Key: settingRetiredAddressGrace, Type: settingTypeInt, Default: strconv.Itoa(defaultRetiredAddressGraceDays), Min: "0",
+                Max: "365", Description: "Days deposits to a retired deposit address are still matched to users"},
+        {Key: legacyDepositAddressSettings["TRC20"], Type: settingTypeString, System: true, Public: true,
+                Description: "TRC20 USDT deposit address, kept in step with the active deposit address"},
+        {Key: legacyDepositAddressSettings["BTC"], Type: settingTypeString, System: true, Public: true,
+                Description: "BTC deposit address, kept in step with the active deposit address"},
+})
+
+// indexSettings maps definitions by key
+func indexSettings(defs []*settingDefinition) map[string]*settingDefinition {
+        index := make(map[string]*settingDefinition, len(defs))
+        for _, def := range defs {
+                if _, dup := index[def.Key]; dup {
+                        panic(fmt.Sprintf("settings: %s registered twice", def.Key))
+                }
+                index[def.Key] = def
+        }
+        return index
+}
+
+// validate checks a value against the definition and returns a message
+// describing the first problem, or ""
+func (d *settingDefinition) validate(value string) string {
+        var number decimal.Decimal
+        switch d.Type {
+        case settingTypeInt:
+                n, err := strconv.ParseInt(value, 10, 64)
+                if err != nil {
+                        return "must be an integer"
+                }
+                number = decimal.NewFromInt(n)
+        case settingTypeDecimal:
+                n, err := decimal.NewFromString(value)
+                if err != nil {
+                        return "must be a decimal number"
+                }
+                number = n
+        case settingTypeDate:
+                if _, err := time.Parse("2006-01-02", value); err != nil {
+                        return "must be a date in YYYY-MM-DD format"
+                }
+        case settingTypeJSON:
+                if !json.Valid([]byte(value)) {
+                        return "must be valid JSON"
+                }
+        case settingTypeString:
+                if strings.TrimSpace(value) == "" {
+                        return "is required"
+                }
+        }
+
+        if d.Min != "" && number.LessThan(decimal.RequireFromString(d.Min)) {
+                return "must be at least " + d.Min
+        }
+        if d.Max != "" && number.GreaterThan(decimal.RequireFromString(d.Max)) {
+                return "must be at most " + d.Max
+        }
+        if d.Check != nil {
+                if err := d.Check(value); err != nil {
+                        return "is not valid: " + err.Error()
+                }
+        }
+        return ""
+}
+
+// cachedSetting is a cached lookup; ok is false when the key is unset
+type cachedSetting struct {
+        value string
+        ok    bool
+}
+
+// settingsCache holds registered settings that are not maintained by the
+// block engine. It is only enabled while the event listener is connected,
+// since that is what delivers invalidations when another instance or the
+// TypeScript server changes a setting.
+type settingsCache struct {
+        mu         sync.Mutex
+        enabled    bool
+        generation uint64
+        values     map[string]cachedSetting
+}
+
+var settingCache = &settingsCache{values: make(map[string]cachedSetting)}
+
+// get returns the cached value of key. On a miss it returns the generation
+// to pass to store, so a lookup that raced an invalidation is not cached.
+func (c *settingsCache) get(key string) (cachedSetting, uint64, bool) {
+        c.mu.Lock()
+        defer c.mu.Unlock()
+        s, hit := c.values[key]
+        return s, c.generation, c.enabled && hit
+}
+
+// store caches a lookup made at generation
+func (c *settingsCache) store(key string, s cachedSetting, generation uint64) {
+        c.mu.Lock()
+        defer c.mu.Unlock()
+        if c.enabled && c.generation == generation {
+                c.values[key] = s
+        }
+}
+
+// invalidate drops the cached value of key
+func (c *settingsCache) invalidate(key string) {
+        c.mu.Lock()
+        defer c.mu.Unlock()
+        c.generation++
+        delete(c.values, key)
+}
+
+// setEnabled turns the cache on or off, dropping everything cached
+func (c *settingsCache) setEnabled(enabled bool) {
+        c.mu.Lock()
+        defer c.mu.Unlock()
+        c.generation++
+        c.enabled = enabled
+        c.values = make(map[string]cachedSetting)
+}
+
+// cacheable reports whether reads of key may be served from settingCache
+func cacheable(key string) bool {
+        def, ok := settingDefinitions[key]
+        return ok && !def.System
+}
+
+// getSystemSetting returns the value stored under key in system_settings.
+// ok is false when the key has never been set.
+func getSystemSetting(ctx context.Context, q rowQuerier, key string) (value string, ok bool, err error) {
+        var generation uint64
+        if cacheable(key) {
+                cached, gen, hit := settingCache.get(key)
+                if hit {
+                        return cached.value, cached.ok, nil
+                }
+                generation = gen
+        }
+
+        err = q.QueryRow(ctx, "SELECT value FROM system_settings WHERE key = $1", key).Scan(&value)
+        if err == pgx.ErrNoRows {
+                value, ok, err = "", false, nil
+        } else if err != nil {
+                return "", false, fmt.Errorf("failed to get setting %s: %w", key, err)
+        } else {
+                ok = true
+        }
+
+        if cacheable(key) {
+                settingCache.store(key, cachedSetting{value: value, ok: ok}, generation)
+        }
+        return value, ok, nil
+}
+
+// getDecimalSetting reads a decimal setting, falling back to def when unset
+func getDecimalSetting(ctx context.Context, q rowQuerier, key string, def decimal.Decimal) (decimal.Decimal, error) {
+        value, ok, err := getSystemSetting(ctx, q, key)
+        if err != nil || !ok {
+                return def, err
+        }
+
+        d, err := decimal.NewFromString(value)
+        if err != nil {
+                return def, fmt.Errorf("setting %s is not a decimal: %w", key, err)
+        }
+        return d, nil
+}
+
+// getIntSetting reads an integer setting, falling back to def when unset
+func getIntSetting(ctx context.Context, q rowQuerier, key string, def int64) (int64, error) {
+        value, ok, err := getSystemSetting(ctx, q, key)
+        if err != nil || !ok {
+                return def, err
+        }
+
+        n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+        if err != nil {
+                return def, fmt.Errorf("setting %s is not an integer: %w", key, err)
+        }
+        return n, nil
+}
+
+// setSystemSetting creates or updates the value stored under key. Changes
+// to cached settings are announced so every instance drops its copy once
+// the change commits.
+func setSystemSetting(ctx context.Context, q rowQuerier, key, value string) error {
+        err := q.QueryRow(ctx, `
+                INSERT INTO system_settings (key, value, updated_at)
+                VALUES ($1, $2, $3)
+                ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value, updated_at = EXCLUDED.updated_at
+                RETURNING key`, key, value, clock.Now()).Scan(&key)
+        if err != nil {
+                return fmt.Errorf("failed to set setting %s: %w", key, err)
+        }
+        if cacheable(key) {
+                return publishSettingChange(ctx, q, key)
+        }
+        return nil
+}
+
+// publishSettingChange tells every instance to drop its cached value of key
+func publishSettingChange(ctx context.Context, q rowQuerier, key string) error {
+        settingCache.invalidate(key)
+        return publishEvent(ctx, q, eventSetting, "", map[string]string{"key": key})
+}
+
+// SettingValue is a registered setting with its current value. Value is
+// nil when the setting is unset and has no default.
+type SettingValue struct {
+        Key         string      `json:"key"`
+        Type        settingType `json:"type"`
+        Value       *string     `json:"value"`
+        Default     *string     `json:"default"`
+        IsDefault   bool        `json:"isDefault"`
+        Min         *string     `json:"min,omitempty"`
+        Max         *string     `json:"max,omitempty"`
+        Description string      `json:"description"`
+        System      bool        `json:"system"`
+        Public      bool        `json:"public"`
+        UpdatedAt   *time.Time  `json:"updatedAt"`
+}
+
+// SettingChange represents the setting_changes table. A nil NewValue
+// means the setting was reset to its default.
+type SettingChange struct {
+        ID                string    `json:"id" db:"id"`
+        Key               string    `json:"key" db:"key"`
+        OldValue          *string   `json:"oldValue" db:"old_value"`
+        NewValue          *string   `json:"newValue" db:"new_value"`
+        ChangedBy         *string   `json:"changedBy" db:"changed_by"`
+        ChangedByUsername *string   `json:"changedByUsername"`
+        Reason            *string   `json:"reason" db:"reason"`
+        CreatedAt         time.Time `json:"createdAt" db:"created_at"`
+}
+
+// SettingRequest sets a setting by key, matching the TypeScript server's
+// POST /api/settings
+type SettingRequest struct {
+        Key    string `json:"key" validate:"required,max=100"`
+        Value  string `json:"value" validate:"required,max=10000"`
+        Reason string `json:"reason" validate:"max=500"`
+}
+
+// SettingUpdateRequest sets the setting named in the path
+type SettingUpdateRequest struct {
+        Value  string `json:"value" validate:"required,max=10000"`
+        Reason string `json:"reason" validate:"max=500"`
+}
+
+// newSettingValue builds the view of a setting from its stored row
+func newSettingValue(def *settingDefinition, stored *string, updatedAt *time.Time) *SettingValue {
+        optional := func(s string) *string {
+                if s == "" {
+                        return nil
+                }
+                return &s
+        }
+        v := &SettingValue{
+                Key:         def.Key,
+                Type:        def.Type,
+                Value:       stored,
+                Default:     optional(def.Default),
+                IsDefault:   stored == nil,
+                Min:         optional(def.Min),
+                Max:         optional(def.Max),
+                Description: def.Description,
+                System:      def.System,
+                Public:      def.Public,
+                UpdatedAt:   updatedAt,
+        }
+        if stored == nil {
+                v.Value = v.Default
+        }
+        return v
+}
+
+// getSettingValue loads a registered setting, bypassing the cache
+func getSettingValue(ctx context.Context, q rowQuerier, def *settingDefinition) (*SettingValue, error) {
+        var value *string
+        var updatedAt *time.Time
+        err := q.QueryRow(ctx, "SELECT value, updated_at FROM system_settings WHERE key = $1", def.Key).Scan(&value, &updatedAt)
+        if err != nil && err != pgx.ErrNoRows {
+                return nil, fmt.Errorf("failed to get setting %s: %w", def.Key, err)
+        }
+        return newSettingValue(def, value, updatedAt), nil
+}
+
+// listSettingValues loads every registered setting, ordered by key
+func listSettingValues(ctx context.Context) ([]*SettingValue, error) {
+        keys := make([]string, 0, len(settingDefinitions))
+        for key := range settingDefinitions {
+                keys = append(keys, key)
+        }
+        sort.Strings(keys)
+
+        type storedSetting struct {
+                value     *string
+                updatedAt *time.Time
+        }
+        stored := make(map[string]storedSetting)
+        rows, err := db.Query(ctx, "SELECT key, value, updated_at FROM system_settings WHERE key = ANY($1)", keys)
+        if err != nil {
+                return nil, fmt.Errorf("failed to query settings: %w", err)
+        }
+        defer rows.Close()
+        for rows.Next() {
+                var key string
+                var s storedSetting
+                if err := rows.Scan(&key, &s.value, &s.updatedAt); err != nil {
+                        return nil, fmt.Errorf("failed to scan setting: %w", err)
+                }
+                stored[key] = s
+        }
+        if err := rows.Err(); err != nil {
+                return nil, err
+        }
+
+        values := make([]*SettingValue, 0, len(keys))
+        for _, key := range keys {
+                s := stored[key]
+                values = append(values, newSettingValue(settingDefinitions[key], s.value, s.updatedAt))
+        }
+        return values, nil
+}
+
+// updateSetting validates and stores a registered setting, or resets it to
+// its default when value is nil, and records the change in setting_changes
+func updateSetting(ctx context.Context, key string, value *string, adminID, reason string) (*SettingValue, error) {
+        def, ok := settingDefinitions[key]
+        if !ok {
+                return nil, newAPIError(ErrCodeNotFound, "Setting not found")
+        }
+        if def.System {
+                return nil, newAPIError(ErrCodeInvalidState, "Setting is maintained by the system and cannot be changed")
+        }
+        if value != nil {
+                trimmed := strings.TrimSpace(*value)
+                value = &trimmed
+                if msg := def.validate(trimmed); msg != "" {
+                        return nil, &APIError{
+                                Code:    ErrCodeValidation,
+                                Message: "Validation failed",
+                                Fields:  []FieldError{{Field: "value", Message: msg}},
+                        }
+                }
+        }
+
+        tx, err := db.Begin(ctx)
+        if err != nil {
+                return nil, fmt.Errorf("failed to begin transaction: %w", err)
+        }
+        defer tx.Rollback(ctx)
+
+        var old *string
+        err = tx.QueryRow(ctx, "SELECT value FROM system_settings WHERE key = $1 FOR UPDATE", key).Scan(&old)
+        if err != nil && err != pgx.ErrNoRows {
+                return nil, fmt.Errorf("failed to lock setting %s: %w", key, err)
+        }
+
+        unchanged := (old == nil && value == nil) || (old != nil && value != nil && *old == *value)
+        if !unchanged {
+                if value != nil {
+                        err = setSystemSetting(ctx, tx, key, *value)
+                } else if _, err = tx.Exec(ctx, "DELETE FROM system_settings WHERE key = $1", key); err == nil {
+                        err = publishSettingChange(ctx, tx, key)
+                }
+                if err != nil {
+                        return nil, err
+                }
+
+                var reasonArg *string
+                if reason != "" {
+                        reasonArg = &reason
+                }
+                _, err = tx.Exec(ctx, `
+                        INSERT INTO setting_changes (key, old_value, new_value, changed_by, reason, created_at)
+                        VALUES ($1, $2, $3, $4, $5, $6)`, key, old, value, adminID, reasonArg, clock.Now())
+                if err != nil {
+                        return nil, fmt.Errorf("failed to record setting change: %w", err)
+                }
+        }
+
+        setting, err := getSettingValue(ctx, tx, def)
+        if err != nil {
+                return nil, err
+        }
+        if err := tx.Commit(ctx); err != nil {
+                return nil, fmt.Errorf("failed to commit setting change: %w", err)
+        }
+        settingCache.invalidate(key)
+        return setting, nil
+}
+
+// listSettingChanges returns the latest changes to a setting, newest first
+func listSettingChanges(ctx context.Context, key string, limit int) ([]*SettingChange, error) {
+        rows, err := db.Query(ctx, `
+                SELECT c.id, c.key, c.old_value, c.new_value, c.changed_by, u.username, c.reason, c.created_at
+                FROM setting_changes c
+                LEFT JOIN users u ON u.id = c.changed_by
+                WHERE c.key = $1
+                ORDER BY c.created_at DESC, c.id
+                LIMIT $2`, key, limit)
+        if err != nil {
+                return nil, fmt.Errorf("failed to query setting changes: %w", err)
+        }
+        defer rows.Close()
+
+        changes := []*SettingChange{}
+        for rows.Next() {
+                var c SettingChange
+                if err := rows.Scan(&c.ID, &c.Key, &c.OldValue, &c.NewValue, &c.ChangedBy, &c.ChangedByUsername, &c.Reason,
+                        &c.CreatedAt); err != nil {
+                        return nil, fmt.Errorf("failed to scan setting change: %w", err)
+                }
+                changes = append(changes, &c)
+        }
+        return changes, rows.Err()
+}
+
+// Get setting endpoint. Public settings are readable by any user; the rest
+// only by admins.
+func handleGetSetting(w http.ResponseWriter, r *http.Request) {
+        user := getUserFromContext(r.Context())
+        if user == nil {
+                writeErrorResponse(w, r, ErrCodeUnauthorized, "Unauthorized")
+                return
+        }
+
+        def, ok := settingDefinitions[chi.URLParam(r, "key")]
+        if !ok || (!def.Public && !user.IsAdmin) {
+                writeErrorResponse(w, r, ErrCodeNotFound, "Setting not found")
+                return
+        }
+
+        setting, err := getSettingValue(r.Context(), db, def)
+        if err != nil {
+                writeErrorResponse(w, r, ErrCodeInternal, "Failed to load setting")
+                return
+        }
+
+        writeJSONResponse(w, http.StatusOK, setting)
+}
+
+// Set setting endpoint
+func handleSetSetting(w http.ResponseWriter, r *http.Request) {
+        admin := getUserFromContext(r.Context())
+
+        var req SettingRequest
+        if err := decodeAndValidate(w, r, &req); err != nil {
+                writeAPIError(w, r, err)
+                return
+        }
+
+        setting, err := updateSetting(r.Context(), req.Key, &req.Value, admin.ID, strings.TrimSpace(req.Reason))
+        if err != nil {
+                writeAPIError(w, r, err)
+                return
+        }
+
+        writeJSONResponse(w, http.StatusOK, map[string]interface{}{"message": "Setting updated", "setting": setting})
+}
+
+// Admin settings list endpoint
+func handleAdminListSettings(w http.ResponseWriter, r *http.Request) {
+        settings, err := listSettingValues(r.Context())
+        if err != nil {
+                writeErrorResponse(w, r, ErrCodeInternal, "Failed to load settings")
+                return
+        }
+
+        writeJSONResponse(w, http.StatusOK, map[string]interface{}{"settings": settings})
+}
+
+// Admin update setting endpoint
+func handleAdminUpdateSetting(w http.ResponseWriter, r *http.Request) {
+        admin := getUserFromContext(r.Context())
+
+        var req SettingUpdateRequest
+        if err := decodeAndValidate(w, r, &req); err != nil {
+                writeAPIError(w, r, err)
+                return
+        }
+
+        setting, err := updateSetting(r.Context(), chi.URLParam(r, "key"), &req.Value, admin.ID, strings.TrimSpace(req.Reason))
+        if err != nil {
+                writeAPIError(w, r, err)
+                return
+        }
+
+        writeJSONResponse(w, http.StatusOK, map[string]interface{}{"setting": setting})
+}
+
+// Admin reset setting endpoint. Removes the stored value so the default
+// applies again.
+func handleAdminResetSetting(w http.ResponseWriter, r *http.Request) {
+        admin := getUserFromContext(r.Context())
+
+        reason := strings.TrimSpace(r.URL.Query().Get("reason"))
+        if len(reason) > 500 {
+                writeErrorResponse(w, r, ErrCodeValidation, "reason must be at most 500 characters")
+                return
+        }
+
+        setting, err := updateSetting(r.Context(), chi.URLParam(r, "key"), nil, admin.ID, reason)
+        if err != nil {
+                writeAPIError(w, r, err)
+                return
+        }
+
+        writeJSONResponse(w, http.StatusOK, map[string]interface{}{"setting": setting})
+}
+
+// Admin setting history endpoint
+func handleAdminSettingHistory(w http.ResponseWriter, r *http.Request) {
+        key := chi.URLParam(r, "key")
+        if _, ok := settingDefinitions[key]; !ok {
+                writeErrorResponse(w, r, ErrCodeNotFound, "Setting not found")
+                return
+        }
+
+        limit := 50
+        if v := r.URL.Query().Get("limit"); v != "" {
+                n, err := strconv.Atoi(v)
+                if err != nil || n < 1 || n > 500 {
+                        writeErrorResponse(w, r, ErrCodeValidation, "limit must be between 1 and 500")
+                        return
+                }
+                limit = n
+        }
+
+        changes, err := listSettingChanges(r.Context(), key, limit)
+        if err != nil {
+                writeErrorResponse(w, r, ErrCodeInternal, "Failed to load setting history")
+                return
+        }
+
+        writeJSONResponse(w, http.StatusOK, map[string]interface{}{"changes": changes})
+}
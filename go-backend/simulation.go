@@ -0,0 +1,139 @@
+package main
+
+import (
+        "context"
+        "errors"
+        "fmt"
+        "net/http"
+        "sort"
+        "sync"
+        "time"
+)
+
+// maxSimulationAdvance caps how far one request can move the simulated clock
+const maxSimulationAdvance = 90 * 24 * time.Hour
+
+// simulationMu serializes advances of the simulated clock
+var simulationMu sync.Mutex
+
+// SimulationAdvanceRequest moves the simulated clock forward by a number of
+// blocks or days; exactly one must be set
+type SimulationAdvanceRequest struct {
+        Blocks int `json:"blocks" validate:"min=0,max=12960"`
+        Days   int `json:"days" validate:"min=0,max=90"`
+}
+
+// SimulationResult summarizes the job runs of one advance
+type SimulationResult struct {
+        From      time.Time      `json:"from"`
+        To        time.Time      `json:"to"`
+        Runs      map[string]int `json:"runs"`
+        Failures  map[string]int `json:"failures"`
+        Skipped   int            `json:"skipped"`
+        ElapsedMs int64          `json:"elapsedMs"`
+}
+
+// simulatedRun is one scheduled run of a job during an advance
+type simulatedRun struct {
+        at  time.Time
+        job int
+}
+
+// Advance moves the simulated clock forward by d, running every job that
+// comes due on the way, in schedule order and with the clock set to each
+// run's scheduled time. Jobs without CatchUp only run at their latest due
+// time before another job runs, as they would after a pause in real time.
+func (r *JobRunner) Advance(ctx context.Context, c *SimulatedClock, d time.Duration) (*SimulationResult, error) {
+        simulationMu.Lock()
+        defer simulationMu.Unlock()
+
+        started := time.Now()
+        from := c.Now()
+        to := from.Add(d)
+
+        var runs []simulatedRun
+        for i, job := range r.jobs {
+                for t := job.schedule.Next(from); !t.IsZero() && !t.After(to); t = job.schedule.Next(t) {
+                        runs = append(runs, simulatedRun{at: t, job: i})
+                }
+        }
+        sort.SliceStable(runs, func(i, j int) bool {
+                if !runs[i].at.Equal(runs[j].at) {
+                        return runs[i].at.Before(runs[j].at)
+                }
+                return runs[i].job < runs[j].job
+        })
+
+        result := &SimulationResult{From: from, To: to, Runs: make(map[string]int), Failures: make(map[string]int)}
+        for i, run := range runs {
+                job := r.jobs[run.job]
+                if !job.CatchUp && i+1 < len(runs) && runs[i+1].job == run.job {
+                        continue
+                }
+
+                c.Set(run.at)
+                jobRun, err := r.executeRun(ctx, job, run.at, jobTriggerSimulation)
+                if err != nil {
+                        return nil, fmt.Errorf("job %s at %s: %w", job.Name, run.at.Format(time.RFC3339), err)
+                }
+                if jobRun == nil {
+                        result.Skipped++
+                        continue
+                }
+                result.Runs[job.Name]++
+                if jobRun.Status == jobStatusFailed {
+                        result.Failures[job.Name]++
+                }
+        }
+
+        c.Set(to)
+        result.ElapsedMs = time.Since(started).Milliseconds()
+        return result, nil
+}
+
+// Admin simulation status endpoint
+func handleAdminSimulation(w http.ResponseWriter, r *http.Request) {
+        _, enabled := simulatedClock()
+        writeJSONResponse(w, http.StatusOK, map[string]interface{}{
+                "enabled": enabled,
+                "now":     clock.Now(),
+        })
+}
+
+// Admin simulation advance endpoint. Runs synchronously and reports the job
+// runs it triggered.
+func handleAdminSimulationAdvance(w http.ResponseWriter, r *http.Request) {
+        c, ok := simulatedClock()
+        if !ok {
+                writeErrorResponse(w, r, ErrCodeInvalidState, "Simulation mode is not enabled")
+                return
+        }
+
+        var req SimulationAdvanceRequest
+        if err := decodeAndValidate(w, r, &req); err != nil {
+                writeAPIError(w, r, err)
+                return
+        }
+        if (req.Blocks > 0) == (req.Days > 0) {
+                writeErrorResponse(w, r, ErrCodeValidation, "Set exactly one of blocks or days")
+                return
+        }
+
+        d := time.Duration(req.Blocks)*blockInterval + time.Duration(req.Days)*24*time.Hour
+        if d > maxSimulationAdvance {
+                writeErrorResponse(w, r, ErrCodeValidation, "Cannot advance more than 90 days at once")
+                return
+        }
+
+        result, err := jobRunner.Advance(context.WithoutCancel(r.Context()), c, d)
+        if errors.Is(err, errJobLocked) {
+                writeErrorResponse(w, r, ErrCodeConflict, "A job is already running")
+                return
+        }
+        if err != nil {
+                writeErrorResponse(w, r, ErrCodeInternal, "Simulation failed")
+                return
+        }
+
+        writeJSONResponse(w, http.StatusOK, map[string]interface{}{"simulation": result, "now": clock.Now()})
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+        "context"
+        "os"
+        "strings"
+        "testing"
+        "time"
+
+        "github.com/jackc/pgx/v4/pgxpool"
+        "github.com/shopspring/decimal"
+)
+
+// TestAdvanceThirtyDays drives the default job schedules through 30
+// simulated days with in-memory stand-ins for the database work, checking
+// each job runs when it should with the clock at its scheduled time.
+func TestAdvanceThirtyDays(t *testing.T) {
+        start := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+        c := NewSimulatedClock(start)
+        defer func(orig Clock) { clock = orig }(clock)
+        clock = c
+
+        var (
+                blocks     []time.Time
+                dailyBlock int
+                resets     []int
+        )
+
+        // A contract expiring mid-block-interval and a stake opened mid-day
+        contractExpiresAt := start.Add(10*24*time.Hour + 25*time.Minute)
+        var contractExpiredAt time.Time
+        stakedAt := start.Add(3 * time.Hour)
+        rewardPaidAt := make(map[time.Time]time.Time)
+
+        runs := map[string]func(ctx context.Context, scheduledFor time.Time) error{
+                "block": func(_ context.Context, scheduledFor time.Time) error {
+                        blocks = append(blocks, scheduledFor)
+                        dailyBlock++
+                        return nil
+                },
+                "daily-reset": func(_ context.Context, scheduledFor time.Time) error {
+                        resets = append(resets, dailyBlock)
+                        dailyBlock = 0
+                        return nil
+                },
+                "contract-expiry": func(_ context.Context, _ time.Time) error {
+                        now := clock.Now()
+                        if contractExpiredAt.IsZero() && !contractExpiresAt.After(now) {
+                                contractExpiredAt = now
+                        }
+                        return nil
+                },
+                "staking-payout": func(_ context.Context, _ time.Time) error {
+                        // One reward per full day staked, up to yesterday
+                        now := clock.Now()
+                        today := now.Truncate(24 * time.Hour)
+                        for day := stakedAt.Truncate(24*time.Hour).AddDate(0, 0, 1); day.Before(today); day = day.AddDate(0, 0, 1) {
+                                if _, ok := rewardPaidAt[day]; !ok {
+                                        rewardPaidAt[day] = now
+                                }
+                        }
+                        return nil
+                },
+        }
+        jobs := defaultJobs(nil, 0)
+        for _, job := range jobs {
+                run, ok := runs[job.Name]
+                if !ok {
+                        t.Fatalf("no stand-in for job %s", job.Name)
+                }
+                job.Run = run
+        }
+
+        runner, err := newJobRunner(jobs)
+        if err != nil {
+                t.Fatalf("newJobRunner: %v", err)
+        }
+        runner.executeRun = func(ctx context.Context, job *Job, scheduledFor time.Time, trigger string) (*JobRun, error) {
+                if now := clock.Now(); !now.Equal(scheduledFor) {
+                        t.Errorf("job %s scheduled for %s ran at %s", job.Name, scheduledFor, now)
+                }
+                if trigger != jobTriggerSimulation {
+                        t.Errorf("job %s ran with trigger %s", job.Name, trigger)
+                }
+                run := &JobRun{JobName: job.Name, ScheduledFor: scheduledFor, Trigger: trigger, Status: jobStatusSucceeded}
+                if err := job.Run(ctx, scheduledFor); err != nil {
+                        run.Status = jobStatusFailed
+                }
+                return run, nil
+        }
+
+        const days = 30
+        result, err := runner.Advance(context.Background(), c, days*24*time.Hour)
+        if err != nil {
+                t.Fatalf("Advance: %v", err)
+        }
+        end := start.AddDate(0, 0, days)
+        if !c.Now().Equal(end) {
+                t.Errorf("clock at %s after advance, want %s", c.Now(), end)
+        }
+
+        // Blocks: six an hour, evenly spaced
+        if want := days * 24 * 6; len(blocks) != want || result.Runs["block"] != want {
+                t.Errorf("mined %d blocks (%d runs), want %d", len(blocks), result.Runs["block"], want)
+        }
+        for i, at := range blocks {
+                if want := start.Add(time.Duration(i+1) * blockInterval); !at.Equal(want) {
+                        t.Fatalf("block %d mined at %s, want %s", i, at, want)
+                }
+        }
+
+        // Daily resets: one per midnight, after that midnight's block
+        if len(resets) != days || result.Runs["daily-reset"] != days {
+                t.Errorf("ran %d daily resets (%d runs), want %d", len(resets), result.Runs["daily-reset"], days)
+        }
+        for i, n := range resets {
+                if n != blocksPerDay {
+                        t.Errorf("day %d reset after %d blocks, want %d", i+1, n, blocksPerDay)
+                }
+        }
+
+        // Contract expiry: within one block interval of the expiry time
+        if contractExpiredAt.Before(contractExpiresAt) || !contractExpiredAt.Before(contractExpiresAt.Add(blockInterval)) {
+                t.Errorf("contract expiring at %s expired at %s", contractExpiresAt, contractExpiredAt)
+        }
+
+        // Staking: each full day staked is paid at the midnight ending it
+        if result.Runs["staking-payout"] != days {
+                t.Errorf("ran %d staking payouts, want %d", result.Runs["staking-payout"], days)
+        }
+        firstDay := stakedAt.Truncate(24*time.Hour).AddDate(0, 0, 1)
+        if want := int(end.Sub(firstDay) / (24 * time.Hour)); len(rewardPaidAt) != want {
+                t.Errorf("paid %d reward days, want %d", len(rewardPaidAt), want)
+        }
+        for day := firstDay; day.Before(end); day = day.AddDate(0, 0, 1) {
+                if paidAt, want := rewardPaidAt[day], day.AddDate(0, 0, 1); !paidAt.Equal(want) {
+                        t.Errorf("reward for %s paid at %s, want %s", day.Format("2006-01-02"), paidAt, want)
+                }
+        }
+        if len(result.Failures) != 0 || result.Skipped != 0 {
+                t.Errorf("failures %v, skipped %d", result.Failures, result.Skipped)
+        }
+}
+
+// TestAdvanceReplaysEconomics runs the real default jobs against a test
+// database for three simulated days and checks the balances they leave.
+// It needs TEST_DATABASE_URL pointing at a disposable database with the
+// shared schema pushed; every table it touches is truncated first.
+func TestAdvanceReplaysEconomics(t *testing.T) {
+        dbURL := os.Getenv("TEST_DATABASE_URL")
+        if dbURL == "" {
+                t.Skip("TEST_DATABASE_URL is not set")
+        }
+        ctx := context.Background()
+        pool, err := pgxpool.Connect(ctx, dbURL)
+        if err != nil {
+                t.Fatalf("connect: %v", err)
+        }
+        defer pool.Close()
+        defer func(orig *pgxpool.Pool) { db = orig }(db)
+        db = pool
+
+        start := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+        c := NewSimulatedClock(start)
+        defer func(orig Clock) { clock = orig }(clock)
+        clock = c
+
+        _, err = db.Exec(ctx, `TRUNCATE users, mining_blocks, mining_stats, system_settings, difficulty_adjustments,
+                job_runs, btc_price_history CASCADE`)
+        if err != nil {
+                t.Fatalf("truncate: %v", err)
+        }
+
+        // alice mines 600 of her own plus a 400 rental contract expiring 36
+        // hours in; bob mines 1000 and stakes 0.01 BTC against it
+        var aliceID, bobID string
+        err = db.QueryRow(ctx, `
+                INSERT INTO users (username, access_key, hash_power, base_hash_power, has_started_mining, created_at)
+                VALUES ('alice', 'alice-key', 1000, 1000, true, $1) RETURNING id`, start).Scan(&aliceID)
+        if err != nil {
+                t.Fatalf("create alice: %v", err)
+        }
+        err = db.QueryRow(ctx, `
+                INSERT INTO users (username, access_key, hash_power, base_hash_power, btc_balance, has_started_mining, created_at)
+                VALUES ('bob', 'bob-key', 1000, 1000, 1, true, $1) RETURNING id`, start).Scan(&bobID)
+        if err != nil {
+                t.Fatalf("create bob: %v", err)
+        }
+        contractExpiresAt := start.Add(36*time.Hour + 5*time.Minute)
+        _, err = db.Exec(ctx, `
+                INSERT INTO hash_power_contracts (user_id, hash_power, usdt_cost, unit_price, duration_days, status,
+                                                  starts_at, expires_at, created_at)
+                VALUES ($1, 400, 40, 0.1, 30, $2, $3, $4, $3)`,
+                aliceID, contractStatusActive, start.AddDate(0, 0, -29), contractExpiresAt)
+        if err != nil {
+                t.Fatalf("create contract: %v", err)
+        }
+        if _, err := recordBTCPrice(ctx, decimal.NewFromInt(50000), priceSourceManual); err != nil {
+                t.Fatalf("record price: %v", err)
+        }
+        stake, err := createStake(ctx, bobID, decimal.RequireFromString("0.01"))
+        if err != nil {
+                t.Fatalf("create stake: %v", err)
+        }
+
+        runner, err := newJobRunner(defaultJobs(nil, 0))
+        if err != nil {
+                t.Fatalf("newJobRunner: %v", err)
+        }
+        advance := func(d time.Duration) {
+                result, err := runner.Advance(ctx, c, d)
+                if err != nil {
+                        t.Fatalf("Advance: %v", err)
+                }
+                if len(result.Failures) != 0 {
+                        t.Fatalf("job failures %v", result.Failures)
+                }
+        }
+
+        // bob claims after two days, when the first day's rewards have expired
+        advance(48 * time.Hour)
+        if _, claimed, err := claimRewards(ctx, bobID); err != nil {
+                t.Fatalf("claim: %v", err)
+        } else if want := decimal.NewFromInt(blocksPerDay * 25); !claimed.Equal(want) {
+                t.Errorf("bob claimed %s, want %s", claimed, want)
+        }
+        advance(24 * time.Hour)
+
+        // Difficulty retargets daily from the window's eligible hash power:
+        // 2000, then half a day at 2000 and half at 1600, then 1600
+        rows, err := db.Query(ctx, "SELECT difficulty FROM difficulty_adjustments ORDER BY height")
+        if err != nil {
+                t.Fatalf("query difficulty: %v", err)
+        }
+        var difficulties []string
+        for rows.Next() {
+                var d decimal.Decimal
+                if err := rows.Scan(&d); err != nil {
+                        t.Fatalf("scan difficulty: %v", err)
+                }
+                difficulties = append(difficulties, d.String())
+        }
+        rows.Close()
+        if got, want := strings.Join(difficulties, " "), "2 1.8 1.6"; got != want {
+                t.Errorf("difficulties %s, want %s", got, want)
+        }
+
+        // Day one splits the full reward; day two pays the full reward until
+        // the contract expires and 1600 of the 2000 target after; day three
+        // prices 1600 against the 1800 target
+        var blocks int64
+        var mined, paid decimal.Decimal
+        err = db.QueryRow(ctx, `
+                SELECT COUNT(*), COALESCE(SUM(reward), 0),
+                       (SELECT COALESCE(SUM(reward), 0) FROM unclaimed_blocks)
+                FROM mining_blocks`).Scan(&blocks, &mined, &paid)
+        if err != nil {
+                t.Fatalf("query blocks: %v", err)
+        }
+        if blocks != 3*blocksPerDay {
+                t.Errorf("mined %d blocks, want %d", blocks, 3*blocksPerDay)
+        }
+        if want := decimal.RequireFromString("20079.99999792"); !mined.Equal(want) || !paid.Equal(want) {
+                t.Errorf("mined supply %s and rewards paid %s, want %s", mined, paid, want)
+        }
+
+        // Rewards still claimable are the last day's; the rest expired or
+        // were claimed
+        balances := map[string]string{
+                "alice hash power": "600",
+                "alice unclaimed":  "2399.99999904",
+                "alice gbtc":       "0",
+                "bob unclaimed":    "3999.99999888",
+                "bob gbtc":         "3600",
+                "bob btc":          decimal.RequireFromString("0.99").Add(stake.DailyReward.Mul(decimal.NewFromInt(2))).String(),
+        }
+        for _, u := range []struct{ name, id string }{{"alice", aliceID}, {"bob", bobID}} {
+                var hashPower, unclaimed, gbtc, btc decimal.Decimal
+                err := db.QueryRow(ctx, "SELECT hash_power, unclaimed_balance, gbtc_balance, btc_balance FROM users WHERE id = $1", u.id).
+                        Scan(&hashPower, &unclaimed, &gbtc, &btc)
+                if err != nil {
+                        t.Fatalf("query %s: %v", u.name, err)
+                }
+                got := map[string]decimal.Decimal{
+                        u.name + " hash power": hashPower,
+                        u.name + " unclaimed":  unclaimed,
+                        u.name + " gbtc":       gbtc,
+                        u.name + " btc":        btc,
+                }
+                for key, value := range got {
+                        if want, ok := balances[key]; ok && !value.Equal(decimal.RequireFromString(want)) {
+                                t.Errorf("%s = %s, want %s", key, value, want)
+                        }
+                }
+        }
+
+        var contractStatus string
+        if err := db.QueryRow(ctx, "SELECT status FROM hash_power_contracts WHERE user_id = $1", aliceID).Scan(&contractStatus); err != nil {
+                t.Fatalf("query contract: %v", err)
+        }
+        if contractStatus != contractStatusExpired {
+                t.Errorf("contract status %s, want %s", contractStatus, contractStatusExpired)
+        }
+}
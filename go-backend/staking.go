@@ -0,0 +1,611 @@
+package main
+
+import (
+        "context"
+        "fmt"
+        "log"
+        "net/http"
+        "time"
+
+        "github.com/go-chi/chi/v5"
+        "github.com/jackc/pgx/v4"
+        "github.com/shopspring/decimal"
+)
+
+// Stake statuses stored in btc_stakes.status
+const (
+        stakeStatusActive    = "active"
+        stakeStatusCompleted = "completed"
+        stakeStatusCancelled = "cancelled"
+)
+
+// Settings keys used for BTC staking
+const (
+        settingStakingAPR           = "btcStakingApr"
+        settingStakingCancelPenalty = "btcStakingCancelPenalty"
+)
+
+const (
+        // stakeLockMonths is how long a stake stays locked before it unlocks
+        stakeLockMonths = 12
+
+        // stakingPayoutBatchSize caps the rewards paid per pass
+        stakingPayoutBatchSize = 1000
+)
+
+var (
+        minStakeAmount              = decimal.RequireFromString("0.1")
+        defaultStakingAPR           = decimal.NewFromInt(20)
+        defaultStakingCancelPenalty = decimal.NewFromInt(10)
+)
+
+// BTCStake represents the btc_stakes table
+type BTCStake struct {
+        ID               string          `json:"id" db:"id"`
+        UserID           string          `json:"userId" db:"user_id"`
+        BTCAmount        decimal.Decimal `json:"btcAmount" db:"btc_amount"`
+        GBTCHashrate     decimal.Decimal `json:"gbtcHashrate" db:"gbtc_hashrate"`
+        BTCPriceAtStake  decimal.Decimal `json:"btcPriceAtStake" db:"btc_price_at_stake"`
+        APRRate          decimal.Decimal `json:"aprRate" db:"apr_rate"`
+        DailyReward      decimal.Decimal `json:"dailyReward" db:"daily_reward"`
+        TotalRewardsPaid decimal.Decimal `json:"totalRewardsPaid" db:"total_rewards_paid"`
+        StakedAt         time.Time       `json:"stakedAt" db:"staked_at"`
+        UnlockAt         time.Time       `json:"unlockAt" db:"unlock_at"`
+        Status           string          `json:"status" db:"status"`
+        LastRewardAt     *time.Time      `json:"lastRewardAt" db:"last_reward_at"`
+        CreatedAt        time.Time       `json:"createdAt" db:"created_at"`
+}
+
+// BTCStakingReward represents the btc_staking_rewards table
+type BTCStakingReward struct {
+        ID           string          `json:"id" db:"id"`
+        StakeID      string          `json:"stakeId" db:"stake_id"`
+        UserID       string          `json:"userId" db:"user_id"`
+        RewardAmount decimal.Decimal `json:"rewardAmount" db:"reward_amount"`
+        BTCPrice     decimal.Decimal `json:"btcPrice" db:"btc_price"`
+        RewardDate   time.Time       `json:"rewardDate" db:"reward_date"`
+        PaidAt       time.Time       `json:"paidAt" db:"paid_at"`
+}
+
+type StakeRequest struct {
+        BTCAmount string `json:"btcAmount" validate:"required,money=BTC"`
+}
+
+// stakeColumns lists the btc_stakes columns in the order scanStake expects
+const stakeColumns = `
+        id, user_id, btc_amount, gbtc_hashrate, btc_price_at_stake, apr_rate, daily_reward,
+        total_rewards_paid, staked_at, unlock_at, status, last_reward_at, created_at`
+
+func scanStake(row pgx.Row) (*BTCStake, error) {
+        var s BTCStake
+        err := row.Scan(
+                &s.ID, &s.UserID, &s.BTCAmount, &s.GBTCHashrate, &s.BTCPriceAtStake, &s.APRRate, &s.DailyReward,
+                &s.TotalRewardsPaid, &s.StakedAt, &s.UnlockAt, &s.Status, &s.LastRewardAt, &s.CreatedAt,
+        )
+        if err != nil {
+                return nil, err
+        }
+        return &s, nil
+}
+
+// getUserStakes returns all of a user's stakes, newest first
+func getUserStakes(ctx context.Context, userID string) ([]*BTCStake, error) {
+        rows, err := db.Query(ctx, `SELECT `+stakeColumns+` FROM btc_stakes WHERE user_id = $1 ORDER BY staked_at DESC`, userID)
+        if err != nil {
+                return nil, fmt.Errorf("failed to query stakes: %w", err)
+        }
+        defer rows.Close()
+
+        var stakes []*BTCStake
+        for rows.Next() {
+                s, err := scanStake(rows)
+                if err != nil {
+                        return nil, fmt.Errorf("failed to scan stake: %w", err)
+                }
+                stakes = append(stakes, s)
+        }
+        return stakes, rows.Err()
+}
+
+// lockedHashrate sums the hashrate locked by a user's active stakes
+func lockedHashrate(ctx context.Context, q rowQuerier, userID string) (decimal.Decimal, error) {
+        var locked decimal.Decimal
+        err := q.QueryRow(ctx,
+                "SELECT COALESCE(SUM(gbtc_hashrate), 0) FROM btc_stakes WHERE user_id = $1 AND status = $2",
+                userID, stakeStatusActive).Scan(&locked)
+        if err != nil {
+                return decimal.Zero, fmt.Errorf("failed to get locked hashrate: %w", err)
+        }
+        return locked, nil
+}
+
+// expiringHashPower sums the hash power of a user's active contracts that
+// expire before the given time
+func expiringHashPower(ctx context.Context, q rowQuerier, userID string, before time.Time) (decimal.Decimal, error) {
+        var expiring decimal.Decimal
+        err := q.QueryRow(ctx, `
+                SELECT COALESCE(SUM(hash_power), 0) FROM hash_power_contracts
+                WHERE user_id = $1 AND status = $2 AND expires_at < $3`,
+                userID, contractStatusActive, before).Scan(&expiring)
+        if err != nil {
+                return decimal.Zero, fmt.Errorf("failed to get expiring hash power: %w", err)
+        }
+        return expiring, nil
+}
+
+// createStake moves btcAmount from the user's BTC balance into a new stake
+// that locks an equivalent amount of hashrate (1 GH/s per USDT of value).
+func createStake(ctx context.Context, userID string, btcAmount decimal.Decimal) (*BTCStake, error) {
+        if btcAmount.LessThan(minStakeAmount) {
+                return nil, newAPIError(ErrCodeValidation, "Minimum stake is "+minStakeAmount.String()+" BTC")
+        }
+
+        tx, err := db.Begin(ctx)
+        if err != nil {
+                return nil, fmt.Errorf("failed to begin transaction: %w", err)
+        }
+        defer tx.Rollback(ctx)
+
+        var btcBalance, hashPower decimal.Decimal
+        err = tx.QueryRow(ctx, "SELECT btc_balance, hash_power FROM users WHERE id = $1 FOR UPDATE", userID).
+                Scan(&btcBalance, &hashPower)
+        if err == pgx.ErrNoRows {
+                return nil, newAPIError(ErrCodeNotFound, "User not found")
+        }
+        if err != nil {
+                return nil, fmt.Errorf("failed to lock user: %w", err)
+        }
+
+        if btcBalance.LessThan(btcAmount) {
+                return nil, newAPIError(ErrCodeInsufficientFunds, "Insufficient BTC balance")
+        }
+
+        quote, err := getFreshBTCPrice(ctx, tx)
+        if err != nil {
+                return nil, err
+        }
+        btcPrice := quote.Price
+        apr, err := getDecimalSetting(ctx, tx, settingStakingAPR, defaultStakingAPR)
+        if err != nil {
+                return nil, err
+        }
+
+        // Staked hashrate stays in hash_power so mining continues, but it cannot
+        // back more than one stake at a time, and rentals ending before the
+        // stake unlocks cannot back it at all
+        now := clock.Now()
+        unlockAt := now.AddDate(0, stakeLockMonths, 0)
+        required := btcAmount.Mul(btcPrice).Round(hashPowerScale)
+        locked, err := lockedHashrate(ctx, tx, userID)
+        if err != nil {
+                return nil, err
+        }
+        expiring, err := expiringHashPower(ctx, tx, userID, unlockAt)
+        if err != nil {
+                return nil, err
+        }
+        if available := hashPower.Sub(locked).Sub(expiring); available.LessThan(required) {
+                return nil, newAPIError(ErrCodeInsufficientFunds, fmt.Sprintf(
+                        "Insufficient hashrate. Need %s GH/s but only %s GH/s is unlocked",
+                        formatHashPower(required), formatHashPower(available)))
+        }
+
+        dailyReward := btcAmount.Mul(apr).Div(decimal.NewFromInt(100 * 365)).Truncate(8)
+
+        if _, err := tx.Exec(ctx, "UPDATE users SET btc_balance = btc_balance - $1 WHERE id = $2",
+                btcAmount.String(), userID); err != nil {
+                return nil, fmt.Errorf("failed to debit BTC: %w", err)
+        }
+
+        stake, err := scanStake(tx.QueryRow(ctx, `
+                INSERT INTO btc_stakes (user_id, btc_amount, gbtc_hashrate, btc_price_at_stake, apr_rate,
+                                        daily_reward, staked_at, unlock_at, status, created_at)
+                VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $7)
+                RETURNING `+stakeColumns,
+                userID, btcAmount.String(), required.String(), btcPrice.StringFixed(2), apr.StringFixed(2),
+                dailyReward.String(), now, unlockAt, stakeStatusActive))
+        if err != nil {
+                return nil, fmt.Errorf("failed to create stake: %w", err)
+        }
+
+        if err := tx.Commit(ctx); err != nil {
+                return nil, fmt.Errorf("failed to commit stake: %w", err)
+        }
+        return stake, nil
+}
+
+// cancelStake ends an active stake before it unlocks. The principal is
+// returned minus the early-cancel penalty; rewards already paid are kept.
+func cancelStake(ctx context.Context, userID, stakeID string) (*BTCStake, decimal.Decimal, error) {
+        tx, err := db.Begin(ctx)
+        if err != nil {
+                return nil, decimal.Zero, fmt.Errorf("failed to begin transaction: %w", err)
+        }
+        defer tx.Rollback(ctx)
+
+        stake, err := scanStake(tx.QueryRow(ctx,
+                `SELECT `+stakeColumns+` FROM btc_stakes WHERE id = $1 AND user_id = $2 FOR UPDATE`,
+                stakeID, userID))
+        if err == pgx.ErrNoRows {
+                return nil, decimal.Zero, newAPIError(ErrCodeNotFound, "Stake not found")
+        }
+        if err != nil {
+                return nil, decimal.Zero, fmt.Errorf("failed to lock stake: %w", err)
+        }
+
+        if stake.Status != stakeStatusActive {
+                return nil, decimal.Zero, newAPIError(ErrCodeInvalidState, "Stake is not active")
+        }
+        if !clock.Now().Before(stake.UnlockAt) {
+                return nil, decimal.Zero, newAPIError(ErrCodeInvalidState, "Stake has matured and will be unlocked automatically")
+        }
+
+        penaltyPercent, err := getDecimalSetting(ctx, tx, settingStakingCancelPenalty, defaultStakingCancelPenalty)
+        if err != nil {
+                return nil, decimal.Zero, err
+        }
+        penalty := stake.BTCAmount.Mul(penaltyPercent).Div(decimal.NewFromInt(100)).Truncate(8)
+        refund := stake.BTCAmount.Sub(penalty)
+
+        if _, err := tx.Exec(ctx, "UPDATE users SET btc_balance = btc_balance + $1 WHERE id = $2",
+                refund.String(), userID); err != nil {
+                return nil, decimal.Zero, fmt.Errorf("failed to refund BTC: %w", err)
+        }
+        if _, err := tx.Exec(ctx, "UPDATE btc_stakes SET status = $1, penalty_amount = $2, ended_at = $3 WHERE id = $4",
+                stakeStatusCancelled, penalty.String(), clock.Now(), stake.ID); err != nil {
+                return nil, decimal.Zero, fmt.Errorf("failed to cancel stake: %w", err)
+        }
+
+        if err := tx.Commit(ctx); err != nil {
+                return nil, decimal.Zero, fmt.Errorf("failed to commit cancellation: %w", err)
+        }
+        stake.Status = stakeStatusCancelled
+        return stake, refund, nil
+}
+
+// payDueStakingRewards pays every unpaid daily reward up to and including
+// yesterday. Each stake earns one reward per full day staked; the unique
+// (stake_id, reward_date) constraint makes each payout idempotent, so
+// missed days are caught up and concurrent runs never pay twice.
+func payDueStakingRewards(ctx context.Context, now time.Time) (int, error) {
+        btcPrice, err := getCurrentBTCPrice(ctx, db)
+        if err != nil {
+                return 0, err
+        }
+
+        rows, err := db.Query(ctx, `
+                SELECT s.id, s.user_id, s.daily_reward, d::date
+                FROM btc_stakes s
+                CROSS JOIN LATERAL generate_series(s.staked_at::date + 1, LEAST($1::date - 1, s.unlock_at::date), interval '1 day') d
+                WHERE s.status = $2
+                  AND NOT EXISTS (
+                      SELECT 1 FROM btc_staking_rewards r
+                      WHERE r.stake_id = s.id AND r.reward_date = d::date
+                  )
+                ORDER BY d
+                LIMIT $3`,
+                now, stakeStatusActive, stakingPayoutBatchSize)
+        if err != nil {
+                return 0, fmt.Errorf("failed to query due rewards: %w", err)
+        }
+
+        type dueReward struct {
+                stakeID, userID string
+                amount          decimal.Decimal
+                day             time.Time
+        }
+        var due []dueReward
+        for rows.Next() {
+                var d dueReward
+                if err := rows.Scan(&d.stakeID, &d.userID, &d.amount, &d.day); err != nil {
+                        rows.Close()
+                        return 0, fmt.Errorf("failed to scan due reward: %w", err)
+                }
+                due = append(due, d)
+        }
+        rows.Close()
+        if err := rows.Err(); err != nil {
+                return 0, err
+        }
+
+        paid := 0
+        for _, d := range due {
+                ok, err := payStakingReward(ctx, d.stakeID, d.userID, d.amount, btcPrice, d.day, now)
+                if err != nil {
+                        return paid, fmt.Errorf("stake %s: %w", d.stakeID, err)
+                }
+                if ok {
+                        paid++
+                }
+        }
+        return paid, nil
+}
+
+// payStakingReward records one day's reward and credits it to the user. It
+// reports false if the reward for that day had already been paid or the
+// stake is no longer active.
+func payStakingReward(ctx context.Context, stakeID, userID string, amount, btcPrice decimal.Decimal, day, now time.Time) (bool, error) {
+        tx, err := db.Begin(ctx)
+        if err != nil {
+                return false, fmt.Errorf("failed to begin transaction: %w", err)
+        }
+        defer tx.Rollback(ctx)
+
+        // Lock the stake so a concurrent cancellation cannot interleave
+        var status string
+        err = tx.QueryRow(ctx, "SELECT status FROM btc_stakes WHERE id = $1 FOR UPDATE", stakeID).Scan(&status)
+        if err == pgx.ErrNoRows {
+                return false, nil
+        }
+        if err != nil {
+                return false, fmt.Errorf("failed to lock stake: %w", err)
+        }
+        if status != stakeStatusActive {
+                return false, nil
+        }
+
+        tag, err := tx.Exec(ctx, `
+                INSERT INTO btc_staking_rewards (stake_id, user_id, reward_amount, btc_price, reward_date, paid_at)
+                VALUES ($1, $2, $3, $4, $5, $6)
+                ON CONFLICT (stake_id, reward_date) DO NOTHING`,
+                stakeID, userID, amount.String(), btcPrice.StringFixed(2), day, now)
+        if err != nil {
+                return false, fmt.Errorf("failed to record reward: %w", err)
+        }
+        if tag.RowsAffected() == 0 {
+                return false, nil
+        }
+
+        if _, err := tx.Exec(ctx, "UPDATE users SET btc_balance = btc_balance + $1 WHERE id = $2", amount.String(), userID); err != nil {
+                return false, fmt.Errorf("failed to credit reward: %w", err)
+        }
+        if _, err := tx.Exec(ctx, `
+                UPDATE btc_stakes
+                SET total_rewards_paid = total_rewards_paid + $1, last_reward_at = $2
+                WHERE id = $3`,
+                amount.String(), now, stakeID); err != nil {
+                return false, fmt.Errorf("failed to update stake: %w", err)
+        }
+
+        return true, tx.Commit(ctx)
+}
+
+// unlockMaturedStakes returns the principal of every stake whose lock period
+// has ended and whose rewards have all been paid, and marks it completed.
+func unlockMaturedStakes(ctx context.Context, now time.Time) (int, error) {
+        tx, err := db.Begin(ctx)
+        if err != nil {
+                return 0, fmt.Errorf("failed to begin transaction: %w", err)
+        }
+        defer tx.Rollback(ctx)
+
+        rows, err := tx.Query(ctx, `
+                UPDATE btc_stakes s
+                SET status = $1, ended_at = $3
+                WHERE s.status = $2 AND s.unlock_at <= $3
+                  AND NOT EXISTS (
+                      SELECT 1 FROM generate_series(s.staked_at::date + 1, s.unlock_at::date, interval '1 day') d
+                      WHERE NOT EXISTS (
+                          SELECT 1 FROM btc_staking_rewards r WHERE r.stake_id = s.id AND r.reward_date = d::date
+                      )
+                  )
+                RETURNING s.user_id, s.btc_amount`,
+                stakeStatusCompleted, stakeStatusActive, now)
+        if err != nil {
+                return 0, fmt.Errorf("failed to complete stakes: %w", err)
+        }
+
+        type unlock struct {
+                userID string
+                amount decimal.Decimal
+        }
+        var unlocks []unlock
+        for rows.Next() {
+                var u unlock
+                if err := rows.Scan(&u.userID, &u.amount); err != nil {
+                        rows.Close()
+                        return 0, fmt.Errorf("failed to scan unlocked stake: %w", err)
+                }
+                unlocks = append(unlocks, u)
+        }
+        rows.Close()
+        if err := rows.Err(); err != nil {
+                return 0, err
+        }
+
+        for _, u := range unlocks {
+                if _, err := tx.Exec(ctx, "UPDATE users SET btc_balance = btc_balance + $1 WHERE id = $2",
+                        u.amount.String(), u.userID); err != nil {
+                        return 0, fmt.Errorf("failed to return principal: %w", err)
+                }
+        }
+
+        return len(unlocks), tx.Commit(ctx)
+}
+
+// runStakingPayouts is the scheduled job paying due staking rewards and unlocking matured stakes
+func runStakingPayouts(ctx context.Context, _ time.Time) error {
+        now := clock.Now()
+        paid, err := payDueStakingRewards(ctx, now)
+        if err != nil {
+                return err
+        }
+        if paid > 0 {
+                log.Printf("Staking rewards paid: %d", paid)
+        }
+
+        unlocked, err := unlockMaturedStakes(ctx, now)
+        if err != nil {
+                return err
+        }
+        if unlocked > 0 {
+                log.Printf("Stakes unlocked: %d", unlocked)
+        }
+        return nil
+}
+
+// Create BTC stake endpoint
+func handleCreateStake(w http.ResponseWriter, r *http.Request) {
+        user := getUserFromContext(r.Context())
+        if user == nil {
+                writeErrorResponse(w, r, ErrCodeUnauthorized, "Unauthorized")
+                return
+        }
+
+        var req StakeRequest
+        if err := decodeAndValidate(w, r, &req); err != nil {
+                writeAPIError(w, r, err)
+                return
+        }
+
+        amount, err := parseAmount(req.BTCAmount, CurrencyBTC)
+        if err != nil {
+                writeAPIError(w, r, err)
+                return
+        }
+
+        stake, err := createStake(r.Context(), user.ID, amount)
+        if err != nil {
+                writeAPIError(w, r, err)
+                return
+        }
+
+        writeJSONResponse(w, http.StatusCreated, map[string]interface{}{
+                "message":      "BTC stake created successfully",
+                "stake":        stakeResponse(stake, clock.Now()),
+                "lockDuration": fmt.Sprintf("%d months", stakeLockMonths),
+                "aprRate":      stake.APRRate.StringFixed(2) + "%",
+                "dailyReward":  formatAmount(stake.DailyReward, CurrencyBTC),
+        })
+}
+
+// Cancel BTC stake endpoint
+func handleCancelStake(w http.ResponseWriter, r *http.Request) {
+        user := getUserFromContext(r.Context())
+        if user == nil {
+                writeErrorResponse(w, r, ErrCodeUnauthorized, "Unauthorized")
+                return
+        }
+
+        stakeID := chi.URLParam(r, "id")
+        if !isUUID(stakeID) {
+                writeErrorResponse(w, r, ErrCodeNotFound, "Stake not found")
+                return
+        }
+
+        stake, refund, err := cancelStake(r.Context(), user.ID, stakeID)
+        if err != nil {
+                writeAPIError(w, r, err)
+                return
+        }
+
+        writeJSONResponse(w, http.StatusOK, map[string]interface{}{
+                "message":   "BTC stake cancelled",
+                "stake":     stakeResponse(stake, clock.Now()),
+                "refunded":  formatAmount(refund, CurrencyBTC),
+                "penalty":   formatAmount(stake.BTCAmount.Sub(refund), CurrencyBTC),
+                "btcAmount": formatAmount(stake.BTCAmount, CurrencyBTC),
+        })
+}
+
+// Stake dashboard endpoint
+func handleGetStakes(w http.ResponseWriter, r *http.Request) {
+        user := getUserFromContext(r.Context())
+        if user == nil {
+                writeErrorResponse(w, r, ErrCodeUnauthorized, "Unauthorized")
+                return
+        }
+
+        stakes, err := getUserStakes(r.Context(), user.ID)
+        if err != nil {
+                writeErrorResponse(w, r, ErrCodeInternal, "Failed to load stakes")
+                return
+        }
+
+        btcPrice, err := getCurrentBTCPrice(r.Context(), db)
+        if err != nil {
+                writeErrorResponse(w, r, ErrCodeInternal, "Failed to load BTC price")
+                return
+        }
+
+        now := clock.Now()
+        totalStaked, totalDaily, totalPaid, locked := decimal.Zero, decimal.Zero, decimal.Zero, decimal.Zero
+        items := make([]map[string]interface{}, 0, len(stakes))
+        for _, s := range stakes {
+                totalPaid = totalPaid.Add(s.TotalRewardsPaid)
+                if s.Status == stakeStatusActive {
+                        totalStaked = totalStaked.Add(s.BTCAmount)
+                        totalDaily = totalDaily.Add(s.DailyReward)
+                        locked = locked.Add(s.GBTCHashrate)
+                }
+                items = append(items, stakeResponse(s, now))
+        }
+
+        expiring, err := expiringHashPower(r.Context(), db, user.ID, now.AddDate(0, stakeLockMonths, 0))
+        if err != nil {
+                writeErrorResponse(w, r, ErrCodeInternal, "Failed to load stakes")
+                return
+        }
+        available := user.HashPower.Sub(locked).Sub(expiring)
+        if available.IsNegative() {
+                available = decimal.Zero
+        }
+
+        writeJSONResponse(w, http.StatusOK, map[string]interface{}{
+                "stakes":            items,
+                "currentBtcPrice":   btcPrice.StringFixed(2),
+                "totalStaked":       formatAmount(totalStaked, CurrencyBTC),
+                "totalStakedUsdt":   formatAmount(totalStaked.Mul(btcPrice), CurrencyUSDT),
+                "totalDailyRewards": formatAmount(totalDaily, CurrencyBTC),
+                "totalRewardsPaid":  formatAmount(totalPaid, CurrencyBTC),
+                "lockedHashrate":    formatHashPower(locked),
+                "availableHashrate": formatHashPower(available),
+                "minStake":          formatAmount(minStakeAmount, CurrencyBTC),
+        })
+}
+
+// stakeResponse renders a stake with its progress through the lock period
+func stakeResponse(s *BTCStake, now time.Time) map[string]interface{} {
+        totalDays := int(s.UnlockAt.Sub(s.StakedAt).Hours() / 24)
+        elapsedDays := int(now.Sub(s.StakedAt).Hours() / 24)
+        if elapsedDays > totalDays {
+                elapsedDays = totalDays
+        }
+
+        progress := decimal.Zero
+        if totalDays > 0 {
+                progress = decimal.NewFromInt(int64(elapsedDays * 100)).Div(decimal.NewFromInt(int64(totalDays)))
+        }
+
+        // Each day's reward is paid at the midnight ending it, from the first
+        // full day staked through the unlock day
+        var nextRewardAt *time.Time
+        if s.Status == stakeStatusActive {
+                next := now.Truncate(24*time.Hour).AddDate(0, 0, 1)
+                if first := s.StakedAt.Truncate(24*time.Hour).AddDate(0, 0, 2); next.Before(first) {
+                        next = first
+                }
+                if last := s.UnlockAt.Truncate(24*time.Hour).AddDate(0, 0, 1); !next.After(last) {
+                        nextRewardAt = &next
+                }
+        }
+
+        return map[string]interface{}{
+                "id":               s.ID,
+                "btcAmount":        formatAmount(s.BTCAmount, CurrencyBTC),
+                "gbtcHashrate":     formatHashPower(s.GBTCHashrate),
+                "btcPriceAtStake":  s.BTCPriceAtStake.StringFixed(2),
+                "aprRate":          s.APRRate.StringFixed(2),
+                "dailyReward":      formatAmount(s.DailyReward, CurrencyBTC),
+                "totalRewardsPaid": formatAmount(s.TotalRewardsPaid, CurrencyBTC),
+                "expectedRewards":  formatAmount(s.DailyReward.Mul(decimal.NewFromInt(int64(totalDays))), CurrencyBTC),
+                "status":           s.Status,
+                "stakedAt":         s.StakedAt,
+                "unlockAt":         s.UnlockAt,
+                "lastRewardAt":     s.LastRewardAt,
+                "nextRewardAt":     nextRewardAt,
+                "daysElapsed":      elapsedDays,
+                "daysRemaining":    totalDays - elapsedDays,
+                "progressPercent":  progress.StringFixed(2),
+        }
+}
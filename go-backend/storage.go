@@ -0,0 +1,98 @@
+package main
+
+import (
+        "context"
+        "errors"
+        "fmt"
+        "io"
+        "os"
+        "path/filepath"
+        "strings"
+)
+
+// ErrObjectNotFound is returned by ObjectStorage.Get for a missing key
+var ErrObjectNotFound = errors.New("object not found")
+
+// ObjectStorage stores uploaded files such as KYC documents under opaque keys
+type ObjectStorage interface {
+        Put(ctx context.Context, key string, r io.Reader) error
+        Get(ctx context.Context, key string) (io.ReadCloser, error)
+}
+
+// LocalObjectStorage keeps objects as files under a root directory
+type LocalObjectStorage struct {
+        Root string
+}
+
+// path resolves a key inside the root, rejecting keys that would escape it
+func (s *LocalObjectStorage) path(key string) (string, error) {
+        clean := filepath.Clean("/" + key)
+        if clean == "/" || strings.Contains(key, "..") {
+                return "", fmt.Errorf("invalid object key %q", key)
+        }
+        return filepath.Join(s.Root, clean), nil
+}
+
+func (s *LocalObjectStorage) Put(ctx context.Context, key string, r io.Reader) error {
+        path, err := s.path(key)
+        if err != nil {
+                return err
+        }
+        if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+                return fmt.Errorf("failed to create object directory: %w", err)
+        }
+
+        // Write to a temporary file first so a failed upload never leaves a partial object
+        tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+        if err != nil {
+                return fmt.Errorf("failed to create object: %w", err)
+        }
+        defer os.Remove(tmp.Name())
+
+        if _, err := io.Copy(tmp, r); err != nil {
+                tmp.Close()
+                return fmt.Errorf("failed to write object: %w", err)
+        }
+        if err := tmp.Close(); err != nil {
+                return fmt.Errorf("failed to write object: %w", err)
+        }
+        if err := os.Rename(tmp.Name(), path); err != nil {
+                return fmt.Errorf("failed to store object: %w", err)
+        }
+        return nil
+}
+
+func (s *LocalObjectStorage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+        path, err := s.path(key)
+        if err != nil {
+                return nil, err
+        }
+        f, err := os.Open(path)
+        if errors.Is(err, os.ErrNotExist) {
+                return nil, ErrObjectNotFound
+        }
+        if err != nil {
+                return nil, fmt.Errorf("failed to open object: %w", err)
+        }
+        return f, nil
+}
+
+// newObjectStorageFromEnv builds the storage backend named by OBJECT_STORAGE.
+// Only "local" is built in; it stores files under OBJECT_STORAGE_DIR.
+func newObjectStorageFromEnv() (ObjectStorage, error) {
+        backend := os.Getenv("OBJECT_STORAGE")
+        if backend == "" {
+                backend = "local"
+        }
+
+        switch backend {
+        case "local":
+                root := os.Getenv("OBJECT_STORAGE_DIR")
+                if root == "" {
+                        root = "data/objects"
+                }
+                return &LocalObjectStorage{Root: root}, nil
+        default:
+                return nil, fmt.Errorf("unsupported OBJECT_STORAGE backend %q", backend)
+        }
+}
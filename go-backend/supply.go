@@ -0,0 +1,192 @@
+package main
+
+import (
+        "context"
+        "fmt"
+        "net/http"
+        "time"
+
+        "github.com/shopspring/decimal"
+)
+
+// Settings keys for the emission schedule
+const (
+        settingMaxSupply          = "maxSupply"
+        settingHalvingInterval    = "halvingInterval"
+        settingInitialBlockReward = "initialBlockReward"
+)
+
+const defaultHalvingInterval = 210000
+
+var defaultMaxSupply = decimal.NewFromInt(21000000)
+
+// supplyParams is the emission schedule: the reward starts at InitialReward
+// and halves every HalvingInterval blocks until MaxSupply has been mined
+type supplyParams struct {
+        MaxSupply       decimal.Decimal
+        InitialReward   decimal.Decimal
+        HalvingInterval int64
+}
+
+// loadSupplyParams reads the emission schedule from system settings
+func loadSupplyParams(ctx context.Context, q rowQuerier) (*supplyParams, error) {
+        maxSupply, err := getDecimalSetting(ctx, q, settingMaxSupply, defaultMaxSupply)
+        if err != nil {
+                return nil, err
+        }
+        initialReward, err := getDecimalSetting(ctx, q, settingInitialBlockReward, defaultBlockReward)
+        if err != nil {
+                return nil, err
+        }
+        interval, err := getIntSetting(ctx, q, settingHalvingInterval, defaultHalvingInterval)
+        if err != nil {
+                return nil, err
+        }
+        if interval <= 0 {
+                return nil, fmt.Errorf("setting %s must be positive", settingHalvingInterval)
+        }
+        return &supplyParams{MaxSupply: maxSupply, InitialReward: initialReward, HalvingInterval: interval}, nil
+}
+
+// halvingEra returns how many halvings have happened before the block at height
+func (p *supplyParams) halvingEra(height int64) int64 {
+        if height < 1 {
+                return 0
+        }
+        return (height - 1) / p.HalvingInterval
+}
+
+// scheduledReward returns the reward of the block at height ignoring the
+// supply cap. Like Bitcoin it halves in whole base units, so it reaches
+// exactly zero after enough halvings.
+func (p *supplyParams) scheduledReward(height int64) decimal.Decimal {
+        era := p.halvingEra(height)
+        if era >= 63 {
+                return decimal.Zero
+        }
+        units := p.InitialReward.Shift(8).IntPart() >> uint(era)
+        return decimal.New(units, -8)
+}
+
+// rewardAt returns the reward of the block at height given the amount
+// already mined, trimmed so emission stops exactly at the max supply
+func (p *supplyParams) rewardAt(height int64, mined decimal.Decimal) decimal.Decimal {
+        remaining := p.MaxSupply.Sub(mined)
+        if !remaining.IsPositive() {
+                return decimal.Zero
+        }
+        return decimal.Min(p.scheduledReward(height), remaining)
+}
+
+// totalMinedSupply returns the sum of all block rewards
+func totalMinedSupply(ctx context.Context, q rowQuerier) (decimal.Decimal, error) {
+        var mined decimal.Decimal
+        if err := q.QueryRow(ctx, "SELECT COALESCE(SUM(reward), 0) FROM mining_blocks").Scan(&mined); err != nil {
+                return decimal.Zero, fmt.Errorf("failed to get mined supply: %w", err)
+        }
+        return mined, nil
+}
+
+// currentBlockReward returns the reward the next block will pay
+func currentBlockReward(ctx context.Context, q rowQuerier) (decimal.Decimal, error) {
+        params, err := loadSupplyParams(ctx, q)
+        if err != nil {
+                return decimal.Zero, err
+        }
+        height, err := getIntSetting(ctx, q, settingTotalBlockHeight, 0)
+        if err != nil {
+                return decimal.Zero, err
+        }
+        mined, err := totalMinedSupply(ctx, q)
+        if err != nil {
+                return decimal.Zero, err
+        }
+        return params.rewardAt(height+1, mined), nil
+}
+
+// SupplyMetrics describes emission so far and the halving schedule
+type SupplyMetrics struct {
+        TotalMined         decimal.Decimal
+        UnclaimedExpired   decimal.Decimal
+        Circulating        decimal.Decimal
+        MaxSupply          decimal.Decimal
+        CurrentBlockReward decimal.Decimal
+        TotalBlocks        int64
+        HalvingInterval    int64
+        HalvingEra         int64
+        NextHalving        int64
+        BlocksRemaining    int64
+}
+
+// getSupplyMetrics computes the supply metrics. Circulating supply is
+// everything mined except rewards that expired without being claimed.
+func getSupplyMetrics(ctx context.Context, now time.Time) (*SupplyMetrics, error) {
+        params, err := loadSupplyParams(ctx, db)
+        if err != nil {
+                return nil, err
+        }
+        height, err := getIntSetting(ctx, db, settingTotalBlockHeight, 0)
+        if err != nil {
+                return nil, err
+        }
+        mined, err := totalMinedSupply(ctx, db)
+        if err != nil {
+                return nil, err
+        }
+
+        var unclaimedExpired decimal.Decimal
+        err = db.QueryRow(ctx, `
+                SELECT COALESCE(SUM(reward), 0) FROM unclaimed_blocks
+                WHERE claimed = false AND (expired = true OR expires_at <= $1)`, now).Scan(&unclaimedExpired)
+        if err != nil {
+                return nil, fmt.Errorf("failed to get expired rewards: %w", err)
+        }
+
+        era := height / params.HalvingInterval
+        nextHalving := (era + 1) * params.HalvingInterval
+        return &SupplyMetrics{
+                TotalMined:         mined,
+                UnclaimedExpired:   unclaimedExpired,
+                Circulating:        mined.Sub(unclaimedExpired),
+                MaxSupply:          params.MaxSupply,
+                CurrentBlockReward: params.rewardAt(height+1, mined),
+                TotalBlocks:        height,
+                HalvingInterval:    params.HalvingInterval,
+                HalvingEra:         era,
+                NextHalving:        nextHalving,
+                BlocksRemaining:    nextHalving - height,
+        }, nil
+}
+
+// Supply metrics endpoint
+func handleSupplyMetrics(w http.ResponseWriter, r *http.Request) {
+        metrics, err := getSupplyMetrics(r.Context(), clock.Now())
+        if err != nil {
+                writeErrorResponse(w, r, ErrCodeInternal, "Failed to load supply metrics")
+                return
+        }
+
+        percentageMined := decimal.Zero
+        if metrics.MaxSupply.IsPositive() {
+                percentageMined = metrics.TotalMined.Div(metrics.MaxSupply).Mul(decimal.NewFromInt(100))
+        }
+        blocksIntoEra := metrics.TotalBlocks - metrics.HalvingEra*metrics.HalvingInterval
+        eraProgress := decimal.NewFromInt(blocksIntoEra).Div(decimal.NewFromInt(metrics.HalvingInterval)).Mul(decimal.NewFromInt(100))
+
+        writeJSONResponse(w, http.StatusOK, map[string]interface{}{
+                "totalMined":         formatAmount(metrics.TotalMined, CurrencyGBTC),
+                "circulating":        formatAmount(metrics.Circulating, CurrencyGBTC),
+                "unclaimedExpired":   formatAmount(metrics.UnclaimedExpired, CurrencyGBTC),
+                "maxSupply":          formatAmount(metrics.MaxSupply, CurrencyGBTC),
+                "percentageMined":    percentageMined.StringFixed(2),
+                "currentBlockReward": formatAmount(metrics.CurrentBlockReward, CurrencyGBTC),
+                "totalBlocks":        metrics.TotalBlocks,
+                "halvingProgress": map[string]interface{}{
+                        "current":         metrics.HalvingEra,
+                        "interval":        metrics.HalvingInterval,
+                        "nextHalving":     metrics.NextHalving,
+                        "blocksRemaining": metrics.BlocksRemaining,
+                        "percent":         eraProgress.StringFixed(2),
+                },
+        })
+}
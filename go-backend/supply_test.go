@@ -0,0 +1,65 @@
+package main
+
+import (
+        "testing"
+
+        "github.com/shopspring/decimal"
+)
+
+func TestSupplyParamsRewardAt(t *testing.T) {
+        p := &supplyParams{
+                MaxSupply:       decimal.NewFromInt(21000000),
+                InitialReward:   decimal.NewFromInt(50),
+                HalvingInterval: 210000,
+        }
+        tests := []struct {
+                height int64
+                mined  string
+                want   string
+        }{
+                {1, "0", "50"},
+                {210000, "0", "50"},
+                {210001, "0", "25"},
+                {420001, "0", "12.5"},
+                // Halving in whole base units: 50 BTC halves to 1 satoshi and then 0
+                {32*210000 + 1, "0", "0.00000001"},
+                {33*210000 + 1, "0", "0"},
+                {64*210000 + 1, "0", "0"},
+                // The cap trims the last reward and then stops emission
+                {5, "20999990", "10"},
+                {5, "20999999.99999999", "0.00000001"},
+                {5, "21000000", "0"},
+                {5, "21000001", "0"},
+        }
+        for _, tt := range tests {
+                got := p.rewardAt(tt.height, decimal.RequireFromString(tt.mined))
+                if !got.Equal(decimal.RequireFromString(tt.want)) {
+                        t.Errorf("rewardAt(%d, %s) = %s, want %s", tt.height, tt.mined, got, tt.want)
+                }
+        }
+}
+
+func TestSupplyParamsHalvingEra(t *testing.T) {
+        p := &supplyParams{HalvingInterval: 100}
+        for height, want := range map[int64]int64{0: 0, 1: 0, 100: 0, 101: 1, 200: 1, 201: 2} {
+                if got := p.halvingEra(height); got != want {
+                        t.Errorf("halvingEra(%d) = %d, want %d", height, got, want)
+                }
+        }
+}
+
+func TestScheduledRewardsSumBelowMaxSupply(t *testing.T) {
+        p := &supplyParams{
+                MaxSupply:       decimal.NewFromInt(21000000),
+                InitialReward:   decimal.NewFromInt(50),
+                HalvingInterval: 210000,
+        }
+        total := decimal.Zero
+        for era := int64(0); era < 64; era++ {
+                total = total.Add(p.scheduledReward(era*p.HalvingInterval + 1).Mul(decimal.NewFromInt(p.HalvingInterval)))
+        }
+        // Bitcoin's schedule emits just under 21 million
+        if want := decimal.RequireFromString("20999999.9769"); !total.Equal(want) {
+                t.Errorf("total scheduled emission = %s, want %s", total, want)
+        }
+}
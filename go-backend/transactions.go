@@ -0,0 +1,393 @@
+package main
+
+import (
+        "encoding/base64"
+        "encoding/csv"
+        "fmt"
+        "net/http"
+        "sort"
+        "strconv"
+        "strings"
+        "time"
+
+        "github.com/shopspring/decimal"
+)
+
+const (
+        defaultTransactionsLimit = 50
+        maxTransactionsLimit     = 1000
+)
+
+// transactionSources holds, per transaction type, a query producing the
+// unified feed columns for user $1: id, currency, amount (signed from the
+// user's point of view), counter_currency, counter_amount, status, reference,
+// description and created_at.
+var transactionSources = map[string]string{
+        "deposit": `
+                SELECT id::text, currency, amount, NULL::text, NULL::numeric, status, tx_hash,
+                       network || ' deposit', created_at
+                FROM deposits WHERE user_id = $1`,
+        "withdrawal": `
+                SELECT id::text, currency, -amount, NULL::text, NULL::numeric, status, tx_hash,
+                       network || ' withdrawal to ' || address, created_at
+                FROM withdrawals WHERE user_id = $1`,
+        "transfer_sent": `
+                SELECT t.id::text, 'GBTC', -t.amount, NULL::text, NULL::numeric, 'completed', t.tx_hash,
+                       'Transfer to ' || COALESCE(u.username, 'Unknown') || COALESCE(': ' || t.memo, ''), t.created_at
+                FROM transfers t LEFT JOIN users u ON u.id = t.to_user_id
+                WHERE t.from_user_id = $1`,
+        "transfer_received": `
+                SELECT t.id::text, 'GBTC', t.amount, NULL::text, NULL::numeric, 'completed', t.tx_hash,
+                       'Transfer from ' || COALESCE(u.username, 'Unknown') || COALESCE(': ' || t.memo, ''), t.created_at
+                FROM transfers t LEFT JOIN users u ON u.id = t.from_user_id
+                WHERE t.to_user_id = $1`,
+        "hash_power_purchase": `
+                SELECT id::text, 'USDT', -usdt_cost, 'HASH', hash_power, 'completed', NULL::text,
+                       CASE WHEN duration_days IS NULL THEN 'Hash power purchase'
+                            ELSE duration_days || '-day hash power rental' END, created_at
+                FROM hash_power_contracts WHERE user_id = $1`,
+        "contract_renewal": `
+                SELECT r.id::text, 'USDT', -r.usdt_cost, 'HASH', r.hash_power, 'completed', r.contract_id::text,
+                       c.duration_days || '-day hash power rental renewed', r.renewed_at
+                FROM hash_power_contract_renewals r JOIN hash_power_contracts c ON c.id = r.contract_id
+                WHERE r.user_id = $1`,
+        "claim": `
+                SELECT id::text, 'GBTC', reward, NULL::text, NULL::numeric, 'completed', tx_hash,
+                       'Block #' || block_number || ' reward claimed', claimed_at
+                FROM unclaimed_blocks WHERE user_id = $1 AND claimed = true`,
+        "conversion": `
+                SELECT id::text, from_currency, -from_amount, to_currency, to_amount, 'completed', quote_id,
+                       'Converted ' || from_currency || ' to ' || to_currency, created_at
+                FROM conversions WHERE user_id = $1`,
+        "staking_reward": `
+                SELECT id::text, 'BTC', reward_amount, NULL::text, NULL::numeric, 'completed', NULL::text,
+                       'Staking reward for ' || reward_date, paid_at
+                FROM btc_staking_rewards WHERE user_id = $1`,
+        "stake": `
+                SELECT id::text, 'BTC', -btc_amount, 'HASH', gbtc_hashrate, 'completed', NULL::text,
+                       'BTC staked', staked_at
+                FROM btc_stakes WHERE user_id = $1`,
+        // The full principal comes back when a stake ends; an early cancel
+        // forfeits part of it as a separate stake_penalty entry
+        "stake_refund": `
+                SELECT id::text, 'BTC', btc_amount, NULL::text, NULL::numeric, 'completed', NULL::text,
+                       CASE WHEN status = 'cancelled' THEN 'Stake cancelled, principal returned'
+                            ELSE 'Stake unlocked, principal returned' END, ended_at
+                FROM btc_stakes WHERE user_id = $1 AND ended_at IS NOT NULL`,
+        "stake_penalty": `
+                SELECT id::text, 'BTC', -penalty_amount, NULL::text, NULL::numeric, 'completed', NULL::text,
+                       'Early cancel penalty', ended_at
+                FROM btc_stakes WHERE user_id = $1 AND ended_at IS NOT NULL AND penalty_amount > 0`,
+}
+
+// transactionTypes lists every type accepted by the type filter
+func transactionTypes() []string {
+        types := make([]string, 0, len(transactionSources))
+        for t := range transactionSources {
+                types = append(types, t)
+        }
+        sort.Strings(types)
+        return types
+}
+
+// Transaction is a single entry in the unified transaction feed
+type Transaction struct {
+        ID              string           `json:"id"`
+        Type            string           `json:"type"`
+        Currency        string           `json:"currency"`
+        Amount          decimal.Decimal  `json:"amount"`
+        CounterCurrency *string          `json:"counterCurrency"`
+        CounterAmount   *decimal.Decimal `json:"counterAmount"`
+        Status          string           `json:"status"`
+        Reference       *string          `json:"reference"`
+        Description     string           `json:"description"`
+        CreatedAt       time.Time        `json:"createdAt"`
+}
+
+// transactionCursor is the position after the last entry of a page. Entries
+// are ordered by created_at and then by type:id, both descending.
+type transactionCursor struct {
+        CreatedAt time.Time
+        Key       string
+}
+
+func (c transactionCursor) encode() string {
+        raw := c.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + c.Key
+        return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeTransactionCursor(s string) (*transactionCursor, error) {
+        raw, err := base64.RawURLEncoding.DecodeString(s)
+        if err != nil {
+                return nil, newAPIError(ErrCodeValidation, "Invalid cursor")
+        }
+        ts, key, ok := strings.Cut(string(raw), "|")
+        if !ok {
+                return nil, newAPIError(ErrCodeValidation, "Invalid cursor")
+        }
+        createdAt, err := time.Parse(time.RFC3339Nano, ts)
+        if err != nil {
+                return nil, newAPIError(ErrCodeValidation, "Invalid cursor")
+        }
+        return &transactionCursor{CreatedAt: createdAt, Key: key}, nil
+}
+
+// parseDateParam accepts either an RFC 3339 timestamp or a YYYY-MM-DD date.
+// A bare date used as an upper bound covers the whole day.
+func parseDateParam(name, value string, endOfDay bool) (time.Time, error) {
+        if t, err := time.Parse(time.RFC3339, value); err == nil {
+                return t.UTC(), nil
+        }
+        t, err := time.Parse("2006-01-02", value)
+        if err != nil {
+                return time.Time{}, newAPIError(ErrCodeValidation, name+" must be a YYYY-MM-DD date or RFC 3339 timestamp")
+        }
+        if endOfDay {
+                t = t.AddDate(0, 0, 1)
+        }
+        return t, nil
+}
+
+// transactionsQuery holds the parsed filters of a transactions request
+type transactionsQuery struct {
+        Types  []string
+        From   *time.Time
+        To     *time.Time
+        Cursor *transactionCursor
+        Limit  int
+        Format string
+}
+
+func parseTransactionsQuery(r *http.Request) (*transactionsQuery, error) {
+        params := r.URL.Query()
+        q := &transactionsQuery{Limit: defaultTransactionsLimit, Format: "json"}
+
+        if v := params.Get("type"); v != "" {
+                for _, t := range strings.Split(v, ",") {
+                        t = strings.TrimSpace(t)
+                        if _, ok := transactionSources[t]; !ok {
+                                return nil, newAPIError(ErrCodeValidation,
+                                        "type must be a comma-separated list of: "+strings.Join(transactionTypes(), ", "))
+                        }
+                        q.Types = append(q.Types, t)
+                }
+        } else {
+                q.Types = transactionTypes()
+        }
+
+        if v := params.Get("from"); v != "" {
+                t, err := parseDateParam("from", v, false)
+                if err != nil {
+                        return nil, err
+                }
+                q.From = &t
+        }
+        if v := params.Get("to"); v != "" {
+                t, err := parseDateParam("to", v, true)
+                if err != nil {
+                        return nil, err
+                }
+                q.To = &t
+        }
+        if q.From != nil && q.To != nil && !q.From.Before(*q.To) {
+                return nil, newAPIError(ErrCodeValidation, "from must be before to")
+        }
+
+        if v := params.Get("cursor"); v != "" {
+                cursor, err := decodeTransactionCursor(v)
+                if err != nil {
+                        return nil, err
+                }
+                q.Cursor = cursor
+        }
+
+        if v := params.Get("limit"); v != "" {
+                limit, err := strconv.Atoi(v)
+                if err != nil || limit < 1 || limit > maxTransactionsLimit {
+                        return nil, newAPIError(ErrCodeValidation, fmt.Sprintf("limit must be between 1 and %d", maxTransactionsLimit))
+                }
+                q.Limit = limit
+        }
+
+        if v := params.Get("format"); v != "" {
+                if v != "json" && v != "csv" {
+                        return nil, newAPIError(ErrCodeValidation, "format must be one of: json, csv")
+                }
+                q.Format = v
+        }
+        return q, nil
+}
+
+// listTransactions returns one page of the user's unified transaction feed
+// and the cursor of the next page, if there is one.
+func listTransactions(r *http.Request, userID string, q *transactionsQuery) ([]Transaction, *transactionCursor, error) {
+        parts := make([]string, 0, len(q.Types))
+        for _, t := range q.Types {
+                parts = append(parts, fmt.Sprintf(
+                        "SELECT '%s' AS type, s.* FROM (%s) AS s(id, currency, amount, counter_currency, counter_amount, status, reference, description, created_at)",
+                        t, transactionSources[t]))
+        }
+
+        args := []interface{}{userID}
+        var conditions []string
+        if q.From != nil {
+                args = append(args, *q.From)
+                conditions = append(conditions, fmt.Sprintf("created_at >= $%d", len(args)))
+        }
+        if q.To != nil {
+                args = append(args, *q.To)
+                conditions = append(conditions, fmt.Sprintf("created_at < $%d", len(args)))
+        }
+        if q.Cursor != nil {
+                args = append(args, q.Cursor.CreatedAt, q.Cursor.Key)
+                conditions = append(conditions, fmt.Sprintf("(created_at, type || ':' || id) < ($%d, $%d)", len(args)-1, len(args)))
+        }
+
+        where := ""
+        if len(conditions) > 0 {
+                where = "WHERE " + strings.Join(conditions, " AND ")
+        }
+
+        // Fetch one extra row to learn whether another page follows
+        args = append(args, q.Limit+1)
+        sql := fmt.Sprintf(`
+                SELECT type, id, currency, amount, counter_currency, counter_amount, status, reference, description, created_at
+                FROM (
+                        SELECT type, id, currency, amount, counter_currency, counter_amount, status, reference, description,
+                               COALESCE(created_at, 'epoch') AS created_at
+                        FROM (%s) AS feed
+                ) AS feed
+                %s
+                ORDER BY created_at DESC, type || ':' || id DESC
+                LIMIT $%d`, strings.Join(parts, " UNION ALL "), where, len(args))
+
+        rows, err := db.Query(r.Context(), sql, args...)
+        if err != nil {
+                return nil, nil, fmt.Errorf("failed to query transactions: %w", err)
+        }
+        defer rows.Close()
+
+        transactions := make([]Transaction, 0, q.Limit)
+        for rows.Next() {
+                var t Transaction
+                if err := rows.Scan(&t.Type, &t.ID, &t.Currency, &t.Amount, &t.CounterCurrency, &t.CounterAmount,
+                        &t.Status, &t.Reference, &t.Description, &t.CreatedAt); err != nil {
+                        return nil, nil, fmt.Errorf("failed to scan transaction: %w", err)
+                }
+                transactions = append(transactions, t)
+        }
+        if err := rows.Err(); err != nil {
+                return nil, nil, fmt.Errorf("failed to read transactions: %w", err)
+        }
+
+        var next *transactionCursor
+        if len(transactions) > q.Limit {
+                transactions = transactions[:q.Limit]
+                last := transactions[len(transactions)-1]
+                next = &transactionCursor{CreatedAt: last.CreatedAt, Key: last.Type + ":" + last.ID}
+        }
+        return transactions, next, nil
+}
+
+// formatTransactionAmount renders an amount with the scale of its currency.
+// Hash power and currencies the platform does not track are left as stored.
+func formatTransactionAmount(amount decimal.Decimal, currency string) string {
+        if currency == "HASH" {
+                return formatHashPower(amount)
+        }
+        return formatAmount(amount, Currency(currency))
+}
+
+func transactionResponse(t Transaction) map[string]interface{} {
+        var counterAmount *string
+        if t.CounterAmount != nil && t.CounterCurrency != nil {
+                s := formatTransactionAmount(*t.CounterAmount, *t.CounterCurrency)
+                counterAmount = &s
+        }
+        return map[string]interface{}{
+                "id":              t.ID,
+                "type":            t.Type,
+                "currency":        t.Currency,
+                "amount":          formatTransactionAmount(t.Amount, t.Currency),
+                "counterCurrency": t.CounterCurrency,
+                "counterAmount":   counterAmount,
+                "status":          t.Status,
+                "reference":       t.Reference,
+                "description":     t.Description,
+                "createdAt":       t.CreatedAt,
+        }
+}
+
+// writeTransactionsCSV writes the page as a CSV attachment. The next page
+// cursor, if any, is returned in the X-Next-Cursor header.
+func writeTransactionsCSV(w http.ResponseWriter, transactions []Transaction, next *transactionCursor) {
+        w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+        w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="transactions-%s.csv"`,
+                clock.Now().Format("20060102")))
+        if next != nil {
+                w.Header().Set("X-Next-Cursor", next.encode())
+        }
+        w.WriteHeader(http.StatusOK)
+
+        cw := csv.NewWriter(w)
+        cw.Write([]string{"id", "type", "currency", "amount", "counter_currency", "counter_amount",
+                "status", "reference", "description", "created_at"})
+        for _, t := range transactions {
+                var counterCurrency, counterAmount, reference string
+                if t.CounterCurrency != nil {
+                        counterCurrency = *t.CounterCurrency
+                        if t.CounterAmount != nil {
+                                counterAmount = formatTransactionAmount(*t.CounterAmount, counterCurrency)
+                        }
+                }
+                if t.Reference != nil {
+                        reference = *t.Reference
+                }
+                cw.Write([]string{t.ID, t.Type, t.Currency, formatTransactionAmount(t.Amount, t.Currency),
+                        counterCurrency, counterAmount, t.Status, reference, t.Description,
+                        t.CreatedAt.UTC().Format(time.RFC3339)})
+        }
+        cw.Flush()
+}
+
+// Unified transaction history endpoint
+func handleGetTransactions(w http.ResponseWriter, r *http.Request) {
+        user := getUserFromContext(r.Context())
+        if user == nil {
+                writeErrorResponse(w, r, ErrCodeUnauthorized, "Unauthorized")
+                return
+        }
+
+        q, err := parseTransactionsQuery(r)
+        if err != nil {
+                writeAPIError(w, r, err)
+                return
+        }
+
+        transactions, next, err := listTransactions(r, user.ID, q)
+        if err != nil {
+                writeErrorResponse(w, r, ErrCodeInternal, "Failed to load transactions")
+                return
+        }
+
+        if q.Format == "csv" {
+                writeTransactionsCSV(w, transactions, next)
+                return
+        }
+
+        entries := make([]map[string]interface{}, 0, len(transactions))
+        for _, t := range transactions {
+                entries = append(entries, transactionResponse(t))
+        }
+
+        var nextCursor *string
+        if next != nil {
+                s := next.encode()
+                nextCursor = &s
+        }
+
+        writeJSONResponse(w, http.StatusOK, map[string]interface{}{
+                "transactions": entries,
+                "nextCursor":   nextCursor,
+        })
+}
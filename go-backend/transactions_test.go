@@ -0,0 +1,100 @@
+package main
+
+import (
+        "encoding/base64"
+        "net/http/httptest"
+        "reflect"
+        "testing"
+        "time"
+)
+
+func TestTransactionCursorRoundTrip(t *testing.T) {
+        cursors := []transactionCursor{
+                {CreatedAt: time.Date(2025, 3, 1, 12, 30, 45, 123456789, time.UTC), Key: "deposit:6f1c2d9e-0000-4000-8000-000000000001"},
+                {CreatedAt: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), Key: "transfer_sent:abc|def"},
+                {CreatedAt: time.Date(2025, 3, 1, 14, 0, 0, 0, time.FixedZone("CET", 3600)), Key: "claim:1"},
+        }
+        for _, c := range cursors {
+                got, err := decodeTransactionCursor(c.encode())
+                if err != nil {
+                        t.Fatalf("decode(%+v): %v", c, err)
+                }
+                if !got.CreatedAt.Equal(c.CreatedAt) || got.Key != c.Key {
+                        t.Errorf("round trip of %+v = %+v", c, got)
+                }
+        }
+}
+
+func TestDecodeTransactionCursorRejectsGarbage(t *testing.T) {
+        for _, s := range []string{
+                "not base64!",
+                base64.RawURLEncoding.EncodeToString([]byte("no separator")),
+                base64.RawURLEncoding.EncodeToString([]byte("yesterday|deposit:1")),
+        } {
+                _, err := decodeTransactionCursor(s)
+                apiErr, ok := err.(*APIError)
+                if !ok || apiErr.Code != ErrCodeValidation {
+                        t.Errorf("decode(%q) err = %v, want %s", s, err, ErrCodeValidation)
+                }
+        }
+}
+
+func TestParseDateParam(t *testing.T) {
+        tests := []struct {
+                value    string
+                endOfDay bool
+                want     time.Time
+        }{
+                {"2025-03-01", false, time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)},
+                {"2025-03-01", true, time.Date(2025, 3, 2, 0, 0, 0, 0, time.UTC)},
+                {"2025-03-01T10:00:00+02:00", true, time.Date(2025, 3, 1, 8, 0, 0, 0, time.UTC)},
+        }
+        for _, tt := range tests {
+                got, err := parseDateParam("to", tt.value, tt.endOfDay)
+                if err != nil || !got.Equal(tt.want) {
+                        t.Errorf("parseDateParam(%q, %v) = %s, %v, want %s", tt.value, tt.endOfDay, got, err, tt.want)
+                }
+        }
+        if _, err := parseDateParam("from", "03/01/2025", false); err == nil {
+                t.Error("parsed a date in the wrong format")
+        }
+}
+
+func TestParseTransactionsQuery(t *testing.T) {
+        cursor := transactionCursor{CreatedAt: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), Key: "deposit:1"}
+        r := httptest.NewRequest("GET", "/api/transactions?type=deposit,+claim&from=2025-02-01&to=2025-02-28&limit=10&format=csv&cursor="+cursor.encode(), nil)
+        q, err := parseTransactionsQuery(r)
+        if err != nil {
+                t.Fatalf("parseTransactionsQuery: %v", err)
+        }
+        if !reflect.DeepEqual(q.Types, []string{"deposit", "claim"}) || q.Limit != 10 || q.Format != "csv" {
+                t.Errorf("query = %+v", q)
+        }
+        if !q.From.Equal(time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)) || !q.To.Equal(time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)) {
+                t.Errorf("range = %s to %s", q.From, q.To)
+        }
+        if q.Cursor == nil || q.Cursor.Key != cursor.Key {
+                t.Errorf("cursor = %+v, want %+v", q.Cursor, cursor)
+        }
+
+        defaults, err := parseTransactionsQuery(httptest.NewRequest("GET", "/api/transactions", nil))
+        if err != nil {
+                t.Fatalf("parseTransactionsQuery: %v", err)
+        }
+        if !reflect.DeepEqual(defaults.Types, transactionTypes()) || defaults.Limit != defaultTransactionsLimit || defaults.Format != "json" {
+                t.Errorf("defaults = %+v", defaults)
+        }
+
+        for _, query := range []string{
+                "type=bogus",
+                "from=2025-03-02&to=2025-03-01",
+                "limit=0",
+                "limit=1001",
+                "format=xml",
+                "cursor=%21",
+        } {
+                if _, err := parseTransactionsQuery(httptest.NewRequest("GET", "/api/transactions?"+query, nil)); err == nil {
+                        t.Errorf("accepted %s", query)
+                }
+        }
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+        "context"
+        "crypto/rand"
+        "encoding/hex"
+        "encoding/json"
+        "fmt"
+        "net/http"
+        "strings"
+        "time"
+
+        "github.com/jackc/pgx/v4"
+        "github.com/shopspring/decimal"
+)
+
+// settingTransferLimits holds the JSON per-tier limits on how much a user
+// can send per UTC day
+const settingTransferLimits = "transferLimits"
+
+// transferLimit caps the GBTC amount and number of transfers sent per day
+type transferLimit struct {
+        DailyAmount decimal.Decimal `json:"dailyAmount"`
+        DailyCount  int             `json:"dailyCount"`
+}
+
+// defaultTransferLimits is used when transferLimits has not been configured
+var defaultTransferLimits = map[string]transferLimit{
+        "unverified": {DailyAmount: decimal.NewFromInt(100), DailyCount: 10},
+        "basic":      {DailyAmount: decimal.NewFromInt(10000), DailyCount: 20},
+        "advanced":   {DailyAmount: decimal.NewFromInt(100000), DailyCount: 50},
+}
+
+// loadTransferLimit returns the daily transfer limit of a KYC tier
+func loadTransferLimit(ctx context.Context, q rowQuerier, tier int) (*transferLimit, error) {
+        limits := defaultTransferLimits
+
+        value, ok, err := getSystemSetting(ctx, q, settingTransferLimits)
+        if err != nil {
+                return nil, err
+        }
+        if ok {
+                var configured map[string]transferLimit
+                if err := json.Unmarshal([]byte(value), &configured); err != nil {
+                        return nil, fmt.Errorf("setting %s is not valid JSON: %w", settingTransferLimits, err)
+                }
+                limits = configured
+        }
+
+        limit, ok := policyForTier(limits, tier)
+        if !ok {
+                return nil, fmt.Errorf("setting %s has no limit for KYC tier %s", settingTransferLimits, kycTierName(tier))
+        }
+        return &limit, nil
+}
+
+// Transfer represents the transfers table
+type Transfer struct {
+        ID         string          `json:"id" db:"id"`
+        FromUserID string          `json:"fromUserId" db:"from_user_id"`
+        ToUserID   string          `json:"toUserId" db:"to_user_id"`
+        Amount     decimal.Decimal `json:"amount" db:"amount"`
+        TxHash     string          `json:"txHash" db:"tx_hash"`
+        Memo       *string         `json:"memo" db:"memo"`
+        CreatedAt  time.Time       `json:"createdAt" db:"created_at"`
+}
+
+type TransferRequest struct {
+        ToUsername string  `json:"toUsername" validate:"required,max=20"`
+        Amount     string  `json:"amount" validate:"required,money=GBTC"`
+        Memo       *string `json:"memo,omitempty" validate:"max=140"`
+}
+
+// generateTxHash returns a random 0x-prefixed 32 byte hex hash
+func generateTxHash() (string, error) {
+        b := make([]byte, 32)
+        if _, err := rand.Read(b); err != nil {
+                return "", fmt.Errorf("failed to generate tx hash: %w", err)
+        }
+        return "0x" + hex.EncodeToString(b), nil
+}
+
+// createTransfer moves amount GBTC from the sender to the recipient in a
+// single transaction, enforcing the sender's daily limits.
+func createTransfer(ctx context.Context, fromUserID, toUsername string, amount decimal.Decimal, memo *string) (*Transfer, error) {
+        tx, err := db.Begin(ctx)
+        if err != nil {
+                return nil, fmt.Errorf("failed to begin transaction: %w", err)
+        }
+        defer tx.Rollback(ctx)
+
+        var toUserID string
+        var toFrozen, toBanned bool
+        err = tx.QueryRow(ctx, "SELECT id, is_frozen, is_banned FROM users WHERE username = $1", toUsername).
+                Scan(&toUserID, &toFrozen, &toBanned)
+        if err == pgx.ErrNoRows {
+                return nil, newAPIError(ErrCodeNotFound, "Recipient not found")
+        }
+        if err != nil {
+                return nil, fmt.Errorf("failed to find recipient: %w", err)
+        }
+
+        if toUserID == fromUserID {
+                return nil, newAPIError(ErrCodeValidation, "Cannot transfer to yourself")
+        }
+        if toBanned {
+                return nil, newAPIError(ErrCodeAccountBanned, "Recipient account is banned")
+        }
+        if toFrozen {
+                return nil, newAPIError(ErrCodeAccountFrozen, "Recipient account is frozen")
+        }
+
+        // Lock both users in a fixed order so concurrent transfers cannot deadlock
+        rows, err := tx.Query(ctx, `
+                SELECT id, gbtc_balance, kyc_tier, kyc_verified
+                FROM users WHERE id IN ($1, $2) ORDER BY id FOR UPDATE`,
+                fromUserID, toUserID)
+        if err != nil {
+                return nil, fmt.Errorf("failed to lock users: %w", err)
+        }
+        var senderBalance decimal.Decimal
+        var senderTier int
+        for rows.Next() {
+                var id string
+                var balance decimal.Decimal
+                var tier int
+                var kycVerified bool
+                if err := rows.Scan(&id, &balance, &tier, &kycVerified); err != nil {
+                        rows.Close()
+                        return nil, fmt.Errorf("failed to scan user: %w", err)
+                }
+                if id == fromUserID {
+                        senderBalance = balance
+                        senderTier = effectiveKYCTier(tier, kycVerified)
+                }
+        }
+        rows.Close()
+        if err := rows.Err(); err != nil {
+                return nil, err
+        }
+
+        if senderBalance.LessThan(amount) {
+                return nil, newAPIError(ErrCodeInsufficientFunds, "Insufficient GBTC balance")
+        }
+
+        now := clock.Now()
+        dayStart := now.Truncate(24 * time.Hour)
+
+        var sentToday decimal.Decimal
+        var countToday int64
+        err = tx.QueryRow(ctx, `
+                SELECT COALESCE(SUM(amount), 0), COUNT(*)
+                FROM transfers
+                WHERE from_user_id = $1 AND created_at >= $2`,
+                fromUserID, dayStart).Scan(&sentToday, &countToday)
+        if err != nil {
+                return nil, fmt.Errorf("failed to get daily transfer totals: %w", err)
+        }
+
+        limit, err := loadTransferLimit(ctx, tx, senderTier)
+        if err != nil {
+                return nil, err
+        }
+
+        if countToday >= int64(limit.DailyCount) {
+                return nil, newAPIError(ErrCodeLimitExceeded, "Daily transfer count limit reached")
+        }
+        if sentToday.Add(amount).GreaterThan(limit.DailyAmount) {
+                return nil, newAPIError(ErrCodeLimitExceeded, fmt.Sprintf(
+                        "Daily transfer limit exceeded; %s GBTC remaining today",
+                        formatAmount(decimal.Max(limit.DailyAmount.Sub(sentToday), decimal.Zero), CurrencyGBTC)))
+        }
+
+        txHash, err := generateTxHash()
+        if err != nil {
+                return nil, err
+        }
+
+        if _, err := tx.Exec(ctx, "UPDATE users SET gbtc_balance = gbtc_balance - $1 WHERE id = $2",
+                amount.String(), fromUserID); err != nil {
+                return nil, fmt.Errorf("failed to debit sender: %w", err)
+        }
+        if _, err := tx.Exec(ctx, "UPDATE users SET gbtc_balance = gbtc_balance + $1 WHERE id = $2",
+                amount.String(), toUserID); err != nil {
+                return nil, fmt.Errorf("failed to credit recipient: %w", err)
+        }
+
+        transfer := Transfer{FromUserID: fromUserID, ToUserID: toUserID, Amount: amount, TxHash: txHash, Memo: memo}
+        err = tx.QueryRow(ctx, `
+                INSERT INTO transfers (from_user_id, to_user_id, amount, tx_hash, memo, created_at)
+                VALUES ($1, $2, $3, $4, $5, $6)
+                RETURNING id, created_at`,
+                fromUserID, toUserID, amount.String(), txHash, memo, now,
+        ).Scan(&transfer.ID, &transfer.CreatedAt)
+        if err != nil {
+                return nil, fmt.Errorf("failed to record transfer: %w", err)
+        }
+
+        if err := tx.Commit(ctx); err != nil {
+                return nil, fmt.Errorf("failed to commit transfer: %w", err)
+        }
+        return &transfer, nil
+}
+
+// Transfer endpoint
+func handleTransfer(w http.ResponseWriter, r *http.Request) {
+        user := getUserFromContext(r.Context())
+        if user == nil {
+                writeErrorResponse(w, r, ErrCodeUnauthorized, "Unauthorized")
+                return
+        }
+
+        var req TransferRequest
+        if err := decodeAndValidate(w, r, &req); err != nil {
+                writeAPIError(w, r, err)
+                return
+        }
+
+        amount, err := parseAmount(req.Amount, CurrencyGBTC)
+        if err != nil {
+                writeAPIError(w, r, err)
+                return
+        }
+
+        var memo *string
+        if req.Memo != nil {
+                if trimmed := strings.TrimSpace(*req.Memo); trimmed != "" {
+                        memo = &trimmed
+                }
+        }
+
+        transfer, err := createTransfer(r.Context(), user.ID, strings.TrimSpace(req.ToUsername), amount, memo)
+        if err != nil {
+                writeAPIError(w, r, err)
+                return
+        }
+
+        writeJSONResponse(w, http.StatusOK, map[string]interface{}{
+                "message": "Transfer successful",
+                "transfer": map[string]interface{}{
+                        "id":         transfer.ID,
+                        "toUsername": strings.TrimSpace(req.ToUsername),
+                        "amount":     formatAmount(transfer.Amount, CurrencyGBTC),
+                        "txHash":     transfer.TxHash,
+                        "memo":       transfer.Memo,
+                        "createdAt":  transfer.CreatedAt,
+                },
+        })
+}
+
+// Transfer history endpoint listing transfers sent and received
+func handleGetTransfers(w http.ResponseWriter, r *http.Request) {
+        user := getUserFromContext(r.Context())
+        if user == nil {
+                writeErrorResponse(w, r, ErrCodeUnauthorized, "Unauthorized")
+                return
+        }
+
+        rows, err := db.Query(r.Context(), `
+                SELECT t.id, t.amount, t.tx_hash, t.memo, t.created_at,
+                       CASE WHEN t.from_user_id = $1 THEN 'sent' ELSE 'received' END AS direction,
+                       COALESCE(u.username, 'Unknown') AS counterparty
+                FROM transfers t
+                LEFT JOIN users u ON u.id = CASE WHEN t.from_user_id = $1 THEN t.to_user_id ELSE t.from_user_id END
+                WHERE t.from_user_id = $1 OR t.to_user_id = $1
+                ORDER BY t.created_at DESC
+                LIMIT 100`, user.ID)
+        if err != nil {
+                writeErrorResponse(w, r, ErrCodeInternal, "Failed to load transfers")
+                return
+        }
+        defer rows.Close()
+
+        transfers := make([]map[string]interface{}, 0)
+        for rows.Next() {
+                var id, txHash, direction, counterparty string
+                var amount decimal.Decimal
+                var memo *string
+                var createdAt time.Time
+                if err := rows.Scan(&id, &amount, &txHash, &memo, &createdAt, &direction, &counterparty); err != nil {
+                        writeErrorResponse(w, r, ErrCodeInternal, "Failed to load transfers")
+                        return
+                }
+                transfers = append(transfers, map[string]interface{}{
+                        "id":           id,
+                        "direction":    direction,
+                        "counterparty": counterparty,
+                        "amount":       formatAmount(amount, CurrencyGBTC),
+                        "txHash":       txHash,
+                        "memo":         memo,
+                        "createdAt":    createdAt,
+                })
+        }
+        if rows.Err() != nil {
+                writeErrorResponse(w, r, ErrCodeInternal, "Failed to load transfers")
+                return
+        }
+
+        writeJSONResponse(w, http.StatusOK, map[string]interface{}{"transfers": transfers})
+}
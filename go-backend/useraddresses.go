@@ -0,0 +1,217 @@
+package main
+
+import (
+        "context"
+        "errors"
+        "fmt"
+        "strings"
+        "time"
+
+        "github.com/jackc/pgx/v4"
+)
+
+// Settings keys holding the account-level extended public keys per-user
+// deposit addresses are derived from
+const (
+        settingBTCDepositXpub = "btcDepositXpub"
+        settingEVMDepositXpub = "evmDepositXpub"
+)
+
+// Chains per-user deposit addresses are derived for. EVM networks share
+// one address per user.
+const (
+        hdChainBTC = "BTC"
+        hdChainEVM = "EVM"
+)
+
+// hdAddressAttempts bounds retries when another request takes the same
+// derivation index
+const hdAddressAttempts = 5
+
+// hdDepositChains maps networks to the chain their per-user address is
+// derived for
+var hdDepositChains = map[string]string{
+        "BTC":   hdChainBTC,
+        "BSC":   hdChainEVM,
+        "ETH":   hdChainEVM,
+        "ERC20": hdChainEVM,
+}
+
+// hdChains lists the chains in the order addresses are assigned
+var hdChains = []string{hdChainBTC, hdChainEVM}
+
+// hdXpubSettings maps chains to the setting holding their xpub
+var hdXpubSettings = map[string]string{
+        hdChainBTC: settingBTCDepositXpub,
+        hdChainEVM: settingEVMDepositXpub,
+}
+
+// UserDepositAddress represents the user_deposit_addresses table. Each
+// address is derived at m/0/DerivationIndex below the xpub KeyID identifies.
+type UserDepositAddress struct {
+        ID              string    `json:"id" db:"id"`
+        UserID          string    `json:"userId" db:"user_id"`
+        Chain           string    `json:"chain" db:"chain"`
+        KeyID           string    `json:"keyId" db:"key_id"`
+        DerivationIndex int64     `json:"derivationIndex" db:"derivation_index"`
+        Address         string    `json:"address" db:"address"`
+        CreatedAt       time.Time `json:"createdAt" db:"created_at"`
+}
+
+// checkDepositXpub returns a settings Check for the xpub of chain
+func checkDepositXpub(chain string) func(string) error {
+        return func(value string) error {
+                key, err := parseExtendedPublicKey(value)
+                if err != nil {
+                        return err
+                }
+                if key.depth != accountKeyDepth {
+                        return fmt.Errorf("must be an account-level key (depth %d), got depth %d", accountKeyDepth, key.depth)
+                }
+                if chain == hdChainEVM && key.version != xpubVersion {
+                        return errors.New("EVM deposit addresses need an xpub")
+                }
+                return nil
+        }
+}
+
+// loadDepositXpub returns the configured xpub of chain, or nil when per-user
+// addresses are not enabled for it
+func loadDepositXpub(ctx context.Context, q rowQuerier, chain string) (*extendedPublicKey, error) {
+        value, ok, err := getSystemSetting(ctx, q, hdXpubSettings[chain])
+        if err != nil || !ok || strings.TrimSpace(value) == "" {
+                return nil, err
+        }
+        key, err := parseExtendedPublicKey(strings.TrimSpace(value))
+        if err != nil {
+                return nil, fmt.Errorf("setting %s: %w", hdXpubSettings[chain], err)
+        }
+        return key, nil
+}
+
+// deriveChildKey derives a non-hardened child key; tests swap it to reach
+// indexes BIP32 cannot derive
+var deriveChildKey = (*extendedPublicKey).child
+
+// deriveDepositAddress derives the receive address at index below an
+// account xpub. Indexes BIP32 cannot derive are skipped, so the returned
+// index may be higher than requested.
+func deriveDepositAddress(account *extendedPublicKey, chain string, index int64) (string, int64, error) {
+        external, err := account.child(0)
+        if err != nil {
+                return "", 0, err
+        }
+        for ; index < hardenedKeyStart; index++ {
+                key, err := deriveChildKey(external, uint32(index))
+                if errors.Is(err, errInvalidChildKey) {
+                        continue
+                }
+                if err != nil {
+                        return "", 0, err
+                }
+                if chain == hdChainEVM {
+                        return key.evmAddress(), index, nil
+                }
+                return key.btcAddress(), index, nil
+        }
+        return "", 0, errors.New("no derivation indexes left below the xpub")
+}
+
+func scanUserDepositAddress(row pgx.Row) (*UserDepositAddress, error) {
+        var a UserDepositAddress
+        if err := row.Scan(&a.ID, &a.UserID, &a.Chain, &a.KeyID, &a.DerivationIndex, &a.Address, &a.CreatedAt); err != nil {
+                return nil, err
+        }
+        return &a, nil
+}
+
+// getUserDepositAddress returns the user's address on chain for the
+// configured xpub, deriving it at the next free index on first use. It
+// returns nil when no xpub is configured for chain.
+func getUserDepositAddress(ctx context.Context, userID, chain string) (*UserDepositAddress, error) {
+        account, err := loadDepositXpub(ctx, db, chain)
+        if err != nil || account == nil {
+                return nil, err
+        }
+        keyID := account.id()
+
+        for attempt := 0; attempt < hdAddressAttempts; attempt++ {
+                address, err := scanUserDepositAddress(db.QueryRow(ctx, `
+                        SELECT id, user_id, chain, key_id, derivation_index, address, created_at
+                        FROM user_deposit_addresses WHERE user_id = $1 AND chain = $2 AND key_id = $3`, userID, chain, keyID))
+                if err == nil {
+                        return address, nil
+                }
+                if err != pgx.ErrNoRows {
+                        return nil, fmt.Errorf("failed to get deposit address: %w", err)
+                }
+
+                var next int64
+                err = db.QueryRow(ctx, `
+                        SELECT COALESCE(MAX(derivation_index) + 1, 0) FROM user_deposit_addresses
+                        WHERE chain = $1 AND key_id = $2`, chain, keyID).Scan(&next)
+                if err != nil {
+                        return nil, fmt.Errorf("failed to find next derivation index: %w", err)
+                }
+                derived, index, err := deriveDepositAddress(account, chain, next)
+                if err != nil {
+                        return nil, err
+                }
+
+                // Either a concurrent request assigned the user an address or took
+                // the index; both are resolved by trying again
+                address, err = scanUserDepositAddress(db.QueryRow(ctx, `
+                        INSERT INTO user_deposit_addresses (user_id, chain, key_id, derivation_index, address, created_at)
+                        VALUES ($1, $2, $3, $4, $5, $6)
+                        ON CONFLICT DO NOTHING
+                        RETURNING id, user_id, chain, key_id, derivation_index, address, created_at`,
+                        userID, chain, keyID, index, derived, clock.Now()))
+                if err == nil {
+                        return address, nil
+                }
+                if err != pgx.ErrNoRows {
+                        return nil, fmt.Errorf("failed to assign deposit address: %w", err)
+                }
+        }
+        return nil, fmt.Errorf("failed to assign a %s deposit address after %d attempts", chain, hdAddressAttempts)
+}
+
+// userDepositAddresses returns the user's derived address on every chain
+// with a configured xpub, keyed by chain
+func userDepositAddresses(ctx context.Context, userID string) (map[string]*UserDepositAddress, error) {
+        addresses := make(map[string]*UserDepositAddress)
+        for _, chain := range hdChains {
+                address, err := getUserDepositAddress(ctx, userID, chain)
+                if err != nil {
+                        return nil, err
+                }
+                if address != nil {
+                        addresses[chain] = address
+                }
+        }
+        return addresses, nil
+}
+
+// findDerivedAddressOwner returns the user a derived deposit address
+// belongs to, under any xpub the chain has used. ok is false when the
+// address was not derived for a user.
+func findDerivedAddressOwner(ctx context.Context, q rowQuerier, network, address string) (userID string, ok bool, err error) {
+        chain, derived := hdDepositChains[network]
+        if !derived {
+                return "", false, nil
+        }
+        // EVM addresses are case-insensitive; the case only carries a checksum
+        match := "address = $2"
+        if chain == hdChainEVM {
+                match = "LOWER(address) = LOWER($2)"
+        }
+        err = q.QueryRow(ctx, "SELECT user_id FROM user_deposit_addresses WHERE chain = $1 AND "+match, chain, address).
+                Scan(&userID)
+        if err == pgx.ErrNoRows {
+                return "", false, nil
+        }
+        if err != nil {
+                return "", false, fmt.Errorf("failed to look up deposit address: %w", err)
+        }
+        return userID, true, nil
+}
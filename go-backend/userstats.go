@@ -0,0 +1,216 @@
+package main
+
+import (
+        "context"
+        "fmt"
+        "net/http"
+        "time"
+
+        "github.com/jackc/pgx/v4"
+        "github.com/shopspring/decimal"
+)
+
+const (
+        // miningStatsDays and miningStatsWeeks are the lengths of the earnings
+        // series returned by /api/mining/stats
+        miningStatsDays  = 30
+        miningStatsWeeks = 12
+)
+
+// efficiencySQL computes user_mining_stats.mining_efficiency from claimed and
+// mined totals, capped to fit numeric(5, 2)
+const efficiencySQL = `LEAST(100, ROUND(COALESCE(%[1]s / NULLIF(%[2]s, 0) * 100, 100), 2))`
+
+// UserMiningStats represents the user_mining_stats table
+type UserMiningStats struct {
+        TotalHashPower     decimal.Decimal `json:"totalHashPower" db:"total_hash_power"`
+        TotalMined         decimal.Decimal `json:"totalMined" db:"total_mined"`
+        TotalClaimed       decimal.Decimal `json:"totalClaimed" db:"total_claimed"`
+        BlocksParticipated int             `json:"blocksParticipated" db:"blocks_participated"`
+        LastMiningActivity *time.Time      `json:"lastMiningActivity" db:"last_mining_activity"`
+        MiningEfficiency   decimal.Decimal `json:"miningEfficiency" db:"mining_efficiency"`
+}
+
+// EarningsPoint is one bucket of an earnings series
+type EarningsPoint struct {
+        Start   time.Time
+        Mined   decimal.Decimal
+        Claimed decimal.Decimal
+}
+
+// recordBlockMiningStats adds a block's rewards to the stats of every miner
+// paid by it
+func recordBlockMiningStats(ctx context.Context, tx pgx.Tx, height int64, at time.Time) error {
+        _, err := tx.Exec(ctx, fmt.Sprintf(`
+                INSERT INTO user_mining_stats
+                        (user_id, total_hash_power, total_mined, total_claimed, blocks_participated, last_mining_activity, mining_efficiency, updated_at)
+                SELECT b.user_id, u.hash_power, b.reward, 0, 1, $2, 0, $2
+                FROM unclaimed_blocks b
+                JOIN users u ON u.id = b.user_id
+                WHERE b.block_height = $1
+                ON CONFLICT (user_id) DO UPDATE
+                SET total_hash_power = EXCLUDED.total_hash_power,
+                    total_mined = COALESCE(user_mining_stats.total_mined, 0) + EXCLUDED.total_mined,
+                    blocks_participated = COALESCE(user_mining_stats.blocks_participated, 0) + 1,
+                    last_mining_activity = EXCLUDED.last_mining_activity,
+                    mining_efficiency = `+efficiencySQL+`,
+                    updated_at = EXCLUDED.updated_at`,
+                "COALESCE(user_mining_stats.total_claimed, 0)",
+                "(COALESCE(user_mining_stats.total_mined, 0) + EXCLUDED.total_mined)"),
+                height, at)
+        if err != nil {
+                return fmt.Errorf("failed to update user mining stats: %w", err)
+        }
+        return nil
+}
+
+// recordClaimMiningStats adds a claim to the user's stats
+func recordClaimMiningStats(ctx context.Context, q rowQuerier, userID string, claimed decimal.Decimal, now time.Time) error {
+        err := q.QueryRow(ctx, fmt.Sprintf(`
+                INSERT INTO user_mining_stats (user_id, total_claimed, mining_efficiency, updated_at)
+                VALUES ($1, $2, 100, $3)
+                ON CONFLICT (user_id) DO UPDATE
+                SET total_claimed = COALESCE(user_mining_stats.total_claimed, 0) + EXCLUDED.total_claimed,
+                    mining_efficiency = `+efficiencySQL+`,
+                    updated_at = EXCLUDED.updated_at
+                RETURNING user_id`,
+                "(COALESCE(user_mining_stats.total_claimed, 0) + EXCLUDED.total_claimed)",
+                "user_mining_stats.total_mined"),
+                userID, claimed.String(), now).Scan(&userID)
+        if err != nil {
+                return fmt.Errorf("failed to update user mining stats: %w", err)
+        }
+        return nil
+}
+
+// getUserMiningStats returns the user's mining stats, zero when they have
+// never mined
+func getUserMiningStats(ctx context.Context, userID string) (*UserMiningStats, error) {
+        stats := UserMiningStats{MiningEfficiency: decimal.NewFromInt(100)}
+        err := db.QueryRow(ctx, `
+                SELECT COALESCE(total_hash_power, 0), COALESCE(total_mined, 0), COALESCE(total_claimed, 0),
+                       COALESCE(blocks_participated, 0), last_mining_activity, COALESCE(mining_efficiency, 100)
+                FROM user_mining_stats
+                WHERE user_id = $1`, userID).Scan(&stats.TotalHashPower, &stats.TotalMined, &stats.TotalClaimed,
+                &stats.BlocksParticipated, &stats.LastMiningActivity, &stats.MiningEfficiency)
+        if err != nil && err != pgx.ErrNoRows {
+                return nil, fmt.Errorf("failed to get user mining stats: %w", err)
+        }
+        return &stats, nil
+}
+
+// getEarningsSeries returns the rewards mined and claimed by the user in
+// buckets of step starting at from, up to and including the bucket holding to
+func getEarningsSeries(ctx context.Context, userID string, from, to time.Time, step string) ([]EarningsPoint, error) {
+        rows, err := db.Query(ctx, `
+                WITH buckets AS (
+                        SELECT generate_series($2::timestamp, $3::timestamp, $4::interval) AS start
+                )
+                SELECT start,
+                       (SELECT COALESCE(SUM(reward), 0) FROM unclaimed_blocks
+                        WHERE user_id = $1 AND created_at >= start AND created_at < start + $4::interval),
+                       (SELECT COALESCE(SUM(reward), 0) FROM unclaimed_blocks
+                        WHERE user_id = $1 AND claimed = true AND claimed_at >= start AND claimed_at < start + $4::interval)
+                FROM buckets
+                ORDER BY start`, userID, from, to, step)
+        if err != nil {
+                return nil, fmt.Errorf("failed to query earnings series: %w", err)
+        }
+        defer rows.Close()
+
+        var points []EarningsPoint
+        for rows.Next() {
+                var p EarningsPoint
+                if err := rows.Scan(&p.Start, &p.Mined, &p.Claimed); err != nil {
+                        return nil, fmt.Errorf("failed to scan earnings: %w", err)
+                }
+                points = append(points, p)
+        }
+        return points, rows.Err()
+}
+
+// earningsSeriesResponse renders an earnings series with fixed scale amounts
+func earningsSeriesResponse(points []EarningsPoint) []map[string]interface{} {
+        series := make([]map[string]interface{}, 0, len(points))
+        for _, p := range points {
+                series = append(series, map[string]interface{}{
+                        "date":    p.Start.Format("2006-01-02"),
+                        "mined":   formatAmount(p.Mined, CurrencyGBTC),
+                        "claimed": formatAmount(p.Claimed, CurrencyGBTC),
+                })
+        }
+        return series
+}
+
+// Mining stats endpoint with lifetime totals, earnings series and an
+// estimate of daily earnings
+func handleMiningStats(w http.ResponseWriter, r *http.Request) {
+        user := getUserFromContext(r.Context())
+        if user == nil {
+                writeErrorResponse(w, r, ErrCodeUnauthorized, "Unauthorized")
+                return
+        }
+
+        ctx := r.Context()
+        stats, err := getUserMiningStats(ctx, user.ID)
+        if err != nil {
+                writeErrorResponse(w, r, ErrCodeInternal, "Failed to load mining stats")
+                return
+        }
+
+        today := clock.Now().Truncate(24 * time.Hour)
+        daily, err := getEarningsSeries(ctx, user.ID, today.AddDate(0, 0, 1-miningStatsDays), today, "1 day")
+        if err != nil {
+                writeErrorResponse(w, r, ErrCodeInternal, "Failed to load mining stats")
+                return
+        }
+        // Weeks start on Monday
+        thisWeek := today.AddDate(0, 0, -(int(today.Weekday())+6)%7)
+        weekly, err := getEarningsSeries(ctx, user.ID, thisWeek.AddDate(0, 0, -7*(miningStatsWeeks-1)), thisWeek, "1 week")
+        if err != nil {
+                writeErrorResponse(w, r, ErrCodeInternal, "Failed to load mining stats")
+                return
+        }
+
+        // Estimate at the current reward, live network hash power and
+        // difficulty; a user who is not mining yet is added to the network
+        // they would join
+        reward, err := currentBlockReward(ctx, db)
+        if err != nil {
+                writeErrorResponse(w, r, ErrCodeInternal, "Failed to load mining stats")
+                return
+        }
+        networkHashPower, err := eligibleNetworkHashPower(ctx, db)
+        if err != nil {
+                writeErrorResponse(w, r, ErrCodeInternal, "Failed to load mining stats")
+                return
+        }
+        var eligible bool
+        err = db.QueryRow(ctx, "SELECT "+eligibleMinerCondition+" FROM users u WHERE u.id = $1", user.ID).Scan(&eligible)
+        if err != nil {
+                writeErrorResponse(w, r, ErrCodeInternal, "Failed to load mining stats")
+                return
+        }
+        if !eligible {
+                networkHashPower = networkHashPower.Add(user.HashPower)
+        }
+        target, err := targetHashPower(ctx, db)
+        if err != nil {
+                writeErrorResponse(w, r, ErrCodeInternal, "Failed to load mining stats")
+                return
+        }
+        estimatedDaily := estimateDailyEarnings(reward, user.HashPower, rewardHashPower(networkHashPower, target))
+
+        writeJSONResponse(w, http.StatusOK, map[string]interface{}{
+                "hashPower":              formatHashPower(user.HashPower),
+                "totalMined":             formatAmount(stats.TotalMined, CurrencyGBTC),
+                "totalClaimed":           formatAmount(stats.TotalClaimed, CurrencyGBTC),
+                "blocksParticipated":     stats.BlocksParticipated,
+                "lastMiningActivity":     stats.LastMiningActivity,
+                "efficiency":             stats.MiningEfficiency.StringFixed(2),
+                "estimatedDailyEarnings": formatAmount(estimatedDaily, CurrencyGBTC),
+                "networkHashPower":       formatHashPower(networkHashPower),
+                "daily":                  earningsSeriesResponse(daily),
+                "weekly":                 earningsSeriesResponse(weekly),
+        })
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+        "encoding/json"
+        "errors"
+        "fmt"
+        "io"
+        "net/http"
+        "reflect"
+        "regexp"
+        "strconv"
+        "strings"
+
+        "github.com/shopspring/decimal"
+)
+
+// maxRequestBodyBytes caps the size of JSON request bodies
+const maxRequestBodyBytes = 1 << 20
+
+var (
+        usernamePattern = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+        txHashPattern   = regexp.MustCompile(`^(0x)?[0-9a-fA-F]{64}$`)
+        uuidPattern     = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+)
+
+// validNetworks lists the deposit and withdrawal networks the platform accepts
+var validNetworks = map[string]bool{
+        "BSC":   true,
+        "ETH":   true,
+        "ERC20": true,
+        "TRC20": true,
+        "APTOS": true,
+        "BTC":   true,
+        "GBTC":  true,
+}
+
+// decodeAndValidate reads a JSON body into dst, rejecting unknown fields and
+// oversized bodies, then validates dst against its validate struct tags.
+func decodeAndValidate(w http.ResponseWriter, r *http.Request, dst interface{}) error {
+        decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBodyBytes))
+        decoder.DisallowUnknownFields()
+
+        if err := decoder.Decode(dst); err != nil {
+                var maxBytesErr *http.MaxBytesError
+                switch {
+                case errors.As(err, &maxBytesErr):
+                        return newAPIError(ErrCodeBadRequest, "Request body too large")
+                case strings.HasPrefix(err.Error(), "json: unknown field "):
+                        field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+                        return &APIError{
+                                Code:    ErrCodeValidation,
+                                Message: "Validation failed",
+                                Fields:  []FieldError{{Field: field, Message: "unknown field"}},
+                        }
+                default:
+                        return newAPIError(ErrCodeBadRequest, "Invalid request format")
+                }
+        }
+
+        if decoder.Decode(&struct{}{}) != io.EOF {
+                return newAPIError(ErrCodeBadRequest, "Request body must contain a single JSON object")
+        }
+
+        return validateStruct(dst)
+}
+
+// validateStruct checks every field of a struct against its validate tag and
+// returns an APIError listing all failing fields, or nil.
+func validateStruct(s interface{}) error {
+        v := reflect.Indirect(reflect.ValueOf(s))
+        if v.Kind() != reflect.Struct {
+                return nil
+        }
+
+        var fieldErrors []FieldError
+        t := v.Type()
+        for i := 0; i < t.NumField(); i++ {
+                field := t.Field(i)
+                tag := field.Tag.Get("validate")
+                if tag == "" || tag == "-" {
+                        continue
+                }
+
+                if msg := validateField(v.Field(i), tag); msg != "" {
+                        fieldErrors = append(fieldErrors, FieldError{Field: jsonFieldName(field), Message: msg})
+                }
+        }
+
+        if len(fieldErrors) == 0 {
+                return nil
+        }
+        return &APIError{Code: ErrCodeValidation, Message: "Validation failed", Fields: fieldErrors}
+}
+
+// validateField applies the comma-separated rules of a validate tag to a
+// single value and returns the first failure message.
+func validateField(v reflect.Value, tag string) string {
+        rules := strings.Split(tag, ",")
+
+        if v.Kind() == reflect.Ptr {
+                if v.IsNil() {
+                        for _, rule := range rules {
+                                if rule == "required" {
+                                        return "is required"
+                                }
+                        }
+                        return ""
+                }
+                v = v.Elem()
+        }
+
+        for _, rule := range rules {
+                name, param, _ := strings.Cut(rule, "=")
+                if msg := applyRule(v, name, param); msg != "" {
+                        return msg
+                }
+        }
+        return ""
+}
+
+func applyRule(v reflect.Value, name, param string) string {
+        switch name {
+        case "required":
+                if v.IsZero() || (v.Kind() == reflect.String && strings.TrimSpace(v.String()) == "") {
+                        return "is required"
+                }
+        case "min", "max":
+                return checkBound(v, name, param)
+        case "oneof":
+                options := strings.Fields(param)
+                actual := fmt.Sprint(v.Interface())
+                for _, option := range options {
+                        if actual == option {
+                                return ""
+                        }
+                }
+                return "must be one of: " + strings.Join(options, ", ")
+        case "username":
+                if !usernamePattern.MatchString(v.String()) {
+                        return "may only contain letters, numbers and underscores"
+                }
+        case "network":
+                if !validNetworks[strings.ToUpper(v.String())] {
+                        return "is not a supported network"
+                }
+        case "txhash":
+                if !txHashPattern.MatchString(v.String()) {
+                        return "must be a 64-character hex transaction hash"
+                }
+        case "decimal":
+                return checkDecimal(v.String(), param)
+        case "money":
+                scale, err := scaleOf(Currency(param))
+                if err != nil {
+                        panic(fmt.Sprintf("validation: %v", err))
+                }
+                return checkDecimal(v.String(), strconv.Itoa(int(scale)))
+        default:
+                panic(fmt.Sprintf("validation: unknown rule %q", name))
+        }
+        return ""
+}
+
+// checkBound enforces min/max as a length for strings and a value for numbers
+func checkBound(v reflect.Value, name, param string) string {
+        limit, err := strconv.ParseFloat(param, 64)
+        if err != nil {
+                panic(fmt.Sprintf("validation: invalid %s parameter %q", name, param))
+        }
+
+        var actual float64
+        var unit string
+        switch v.Kind() {
+        case reflect.String:
+                actual = float64(len(v.String()))
+                unit = " characters"
+        case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+                actual = float64(v.Int())
+        case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+                actual = float64(v.Uint())
+        case reflect.Float32, reflect.Float64:
+                actual = v.Float()
+        case reflect.Slice, reflect.Map:
+                actual = float64(v.Len())
+                unit = " items"
+        default:
+                return ""
+        }
+
+        if name == "min" && actual < limit {
+                return fmt.Sprintf("must be at least %s%s", param, unit)
+        }
+        if name == "max" && actual > limit {
+                return fmt.Sprintf("must be at most %s%s", param, unit)
+        }
+        return ""
+}
+
+// checkDecimal validates a positive decimal string, optionally limited to
+// param decimal places.
+func checkDecimal(s, param string) string {
+        if s == "" {
+                return ""
+        }
+
+        d, err := decimal.NewFromString(s)
+        if err != nil {
+                return "must be a decimal number"
+        }
+        if !d.IsPositive() {
+                return "must be greater than zero"
+        }
+
+        if param != "" {
+                places, err := strconv.Atoi(param)
+                if err != nil {
+                        panic(fmt.Sprintf("validation: invalid decimal parameter %q", param))
+                }
+                if !d.Equal(d.Truncate(int32(places))) {
+                        return fmt.Sprintf("must have at most %d decimal places", places)
+                }
+        }
+        return ""
+}
+
+// isUUID reports whether s is a UUID, so path IDs can be rejected before
+// they reach a uuid column
+func isUUID(s string) bool {
+        return uuidPattern.MatchString(s)
+}
+
+// jsonFieldName returns the name a struct field uses in JSON
+func jsonFieldName(field reflect.StructField) string {
+        name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+        if name == "" || name == "-" {
+                return field.Name
+        }
+        return name
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+        "go/ast"
+        "go/parser"
+        "go/token"
+        "path/filepath"
+        "reflect"
+        "strconv"
+        "strings"
+        "testing"
+)
+
+func TestValidateStruct(t *testing.T) {
+        type request struct {
+                Username string  `json:"username" validate:"required,min=3,max=20,username"`
+                Network  string  `json:"network" validate:"required,network"`
+                TxHash   string  `json:"txHash" validate:"txhash"`
+                Amount   string  `json:"amount" validate:"required,decimal=2"`
+                Kind     string  `json:"kind" validate:"oneof=a b"`
+                Count    int     `json:"count" validate:"min=1,max=10"`
+                Note     *string `json:"note" validate:"max=5"`
+        }
+        valid := func() request {
+                return request{
+                        Username: "miner_1",
+                        Network:  "trc20",
+                        TxHash:   "0x" + strings.Repeat("ab", 32),
+                        Amount:   "10.25",
+                        Kind:     "a",
+                        Count:    3,
+                }
+        }
+        long := "too long"
+
+        tests := []struct {
+                name   string
+                modify func(*request)
+                want   map[string]string
+        }{
+                {name: "valid", modify: func(r *request) {}},
+                {name: "missing required", modify: func(r *request) { r.Username, r.Network, r.Amount = "", "", "" }, want: map[string]string{
+                        "username": "is required",
+                        "network":  "is required",
+                        "amount":   "is required",
+                }},
+                {name: "whitespace is not a value", modify: func(r *request) { r.Username = "   " }, want: map[string]string{
+                        "username": "is required",
+                }},
+                {name: "string length", modify: func(r *request) { r.Username = "ab" }, want: map[string]string{
+                        "username": "must be at least 3 characters",
+                }},
+                {name: "pattern rules", modify: func(r *request) { r.Username, r.TxHash = "bad name", "0x1234" }, want: map[string]string{
+                        "username": "may only contain letters, numbers and underscores",
+                        "txHash":   "must be a 64-character hex transaction hash",
+                }},
+                {name: "unsupported network", modify: func(r *request) { r.Network = "DOGE" }, want: map[string]string{
+                        "network": "is not a supported network",
+                }},
+                {name: "decimal places", modify: func(r *request) { r.Amount = "1.001" }, want: map[string]string{
+                        "amount": "must have at most 2 decimal places",
+                }},
+                {name: "decimal sign", modify: func(r *request) { r.Amount = "-1" }, want: map[string]string{
+                        "amount": "must be greater than zero",
+                }},
+                {name: "not a decimal", modify: func(r *request) { r.Amount = "1e" }, want: map[string]string{
+                        "amount": "must be a decimal number",
+                }},
+                {name: "oneof", modify: func(r *request) { r.Kind = "c" }, want: map[string]string{
+                        "kind": "must be one of: a, b",
+                }},
+                {name: "number bounds", modify: func(r *request) { r.Count = 11 }, want: map[string]string{
+                        "count": "must be at most 10",
+                }},
+                {name: "pointer rules apply to the value", modify: func(r *request) { r.Note = &long }, want: map[string]string{
+                        "note": "must be at most 5 characters",
+                }},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        req := valid()
+                        tt.modify(&req)
+
+                        err := validateStruct(&req)
+                        if len(tt.want) == 0 {
+                                if err != nil {
+                                        t.Fatalf("validateStruct: %v", err)
+                                }
+                                return
+                        }
+                        apiErr, ok := err.(*APIError)
+                        if !ok || apiErr.Code != ErrCodeValidation {
+                                t.Fatalf("err = %v, want a %s error", err, ErrCodeValidation)
+                        }
+                        got := make(map[string]string)
+                        for _, f := range apiErr.Fields {
+                                got[f.Field] = f.Message
+                        }
+                        if !reflect.DeepEqual(got, tt.want) {
+                                t.Errorf("fields = %v, want %v", got, tt.want)
+                        }
+                })
+        }
+}
+
+// tagSamples are values of each field type the rules of a validate tag are
+// run against
+var tagSamples = map[string]interface{}{
+        "string":  "1",
+        "int":     1,
+        "int64":   int64(1),
+        "float64": 1.0,
+        "bool":    true,
+}
+
+// TestValidateTagsInSource runs every validate tag declared in the package
+// once, since applyRule panics on unknown rules and bad parameters and
+// would otherwise only do so when a request arrives.
+func TestValidateTagsInSource(t *testing.T) {
+        files, err := filepath.Glob("*.go")
+        if err != nil {
+                t.Fatal(err)
+        }
+        fset := token.NewFileSet()
+        checked := 0
+        for _, name := range files {
+                if strings.HasSuffix(name, "_test.go") {
+                        continue
+                }
+                file, err := parser.ParseFile(fset, name, nil, 0)
+                if err != nil {
+                        t.Fatal(err)
+                }
+                ast.Inspect(file, func(n ast.Node) bool {
+                        field, ok := n.(*ast.Field)
+                        if !ok || field.Tag == nil {
+                                return true
+                        }
+                        raw, err := strconv.Unquote(field.Tag.Value)
+                        if err != nil {
+                                return true
+                        }
+                        tag := reflect.StructTag(raw).Get("validate")
+                        if tag == "" || tag == "-" {
+                                return true
+                        }
+
+                        typ := field.Type
+                        if star, ok := typ.(*ast.StarExpr); ok {
+                                typ = star.X
+                        }
+                        sample := reflect.ValueOf("1")
+                        switch typ := typ.(type) {
+                        case *ast.Ident:
+                                if v, ok := tagSamples[typ.Name]; ok {
+                                        sample = reflect.ValueOf(v)
+                                }
+                        case *ast.ArrayType:
+                                sample = reflect.ValueOf([]string{"1"})
+                        }
+
+                        checked++
+                        func() {
+                                defer func() {
+                                        if r := recover(); r != nil {
+                                                t.Errorf("%s: validate:%q panics: %v", fset.Position(field.Pos()), tag, r)
+                                        }
+                                }()
+                                validateField(sample, tag)
+                        }()
+                        return true
+                })
+        }
+        if checked == 0 {
+                t.Fatal("found no validate tags")
+        }
+}
@@ -1,5 +1,6 @@
 import { storage } from "./storage";
 import cron from "node-cron";
+import { log } from "./vite";
 
 let dailyBlockNumber = 1; // Daily block counter (resets to 1 at 00:00 UTC)
 let totalBlockHeight = 0; // Total blocks mined (never resets)
@@ -12,6 +13,14 @@ export function setupMining() {
     // Silent retry
   });
   
+  // Block generation, daily resets and staking payouts run as jobs in the
+  // Go backend. Only schedule them here when explicitly opted in, otherwise
+  // both services would mint blocks and pay staking rewards.
+  if (process.env.TS_MINING_SCHEDULER !== 'true') {
+    log("mining jobs run in the Go backend; set TS_MINING_SCHEDULER=true to schedule them here", "mining");
+    return;
+  }
+  
   // Generate block every 10 minutes (Bitcoin-like timing)
   cron.schedule("*/10 * * * *", async () => {
     // Generate block and distribute rewards every 10 minutes
@@ -166,6 +166,12 @@ export class DatabaseStorage implements IStorage {
     this.sessionStore = new session.MemoryStore();
   }
 
+  // Publishes a status change on the channel the Go event stream listens on
+  private async notifyUser(type: "deposit" | "withdrawal", userId: string, data: Record<string, unknown>): Promise<void> {
+    const payload = JSON.stringify({ type, userId, data });
+    await db.execute(sql`SELECT pg_notify('b2b_events', ${payload})`);
+  }
+
   async getUser(id: string): Promise<User | undefined> {
     const [user] = await db.select().from(users).where(eq(users.id, id));
     return user || undefined;
@@ -319,7 +325,7 @@ export class DatabaseStorage implements IStorage {
     const amountToCredit = actualAmount || deposit.amount;
     
     // Update deposit status and amount if actualAmount provided
-    await db
+    const [approved] = await db
       .update(deposits)
       .set({ 
         status: "approved", 
@@ -327,7 +333,8 @@ export class DatabaseStorage implements IStorage {
         amount: amountToCredit,
         updatedAt: new Date() 
       })
-      .where(eq(deposits.id, depositId));
+      .where(eq(deposits.id, depositId))
+      .returning();
     
     // Update user balance with the verified amount
     const [user] = await db
@@ -343,13 +350,19 @@ export class DatabaseStorage implements IStorage {
         .set({ usdtBalance: newBalance })
         .where(eq(users.id, deposit.userId));
     }
+
+    await this.notifyUser("deposit", deposit.userId, approved);
   }
 
   async rejectDeposit(depositId: string, adminNote?: string): Promise<void> {
-    await db
+    const [rejected] = await db
       .update(deposits)
       .set({ status: "rejected", adminNote, updatedAt: new Date() })
-      .where(eq(deposits.id, depositId));
+      .where(eq(deposits.id, depositId))
+      .returning();
+    if (rejected) {
+      await this.notifyUser("deposit", rejected.userId, rejected);
+    }
   }
 
   async createWithdrawal(withdrawal: InsertWithdrawal & { userId: string }): Promise<Withdrawal> {
@@ -430,18 +443,25 @@ export class DatabaseStorage implements IStorage {
     }
 
     // Update withdrawal status
-    await db.update(withdrawals)
+    const [completed] = await db.update(withdrawals)
       .set({ 
         status: "completed",
         txHash: txHash || null
       })
-      .where(eq(withdrawals.id, withdrawalId));
+      .where(eq(withdrawals.id, withdrawalId))
+      .returning();
+
+    await this.notifyUser("withdrawal", withdrawal.userId, completed);
   }
 
   async rejectWithdrawal(withdrawalId: string): Promise<void> {
-    await db.update(withdrawals)
+    const [rejected] = await db.update(withdrawals)
       .set({ status: "rejected" })
-      .where(eq(withdrawals.id, withdrawalId));
+      .where(eq(withdrawals.id, withdrawalId))
+      .returning();
+    if (rejected) {
+      await this.notifyUser("withdrawal", rejected.userId, rejected);
+    }
   }
 
   async createMiningBlock(blockNumber: number, reward: string, totalHashPower: string): Promise<MiningBlock> {
@@ -484,6 +504,9 @@ export class DatabaseStorage implements IStorage {
         target: systemSettings.key,
         set: { value, updatedAt: new Date() }
       });
+    // Let the Go instances drop their cached copy
+    const payload = JSON.stringify({ type: "setting", data: { key } });
+    await db.execute(sql`SELECT pg_notify('b2b_events', ${payload})`);
   }
 
   async getUserCount(): Promise<number> {
@@ -909,14 +932,19 @@ export class DatabaseStorage implements IStorage {
     const activeStakes = await this.getActiveBtcStakes();
     const currentBtcPrice = await this.getCurrentBtcPrice();
 
+    const rewardDate = new Date().toISOString().split('T')[0]; // YYYY-MM-DD
+
     for (const stake of activeStakes) {
-      // Pay daily reward
-      await db.insert(btcStakingRewards).values({
+      // Pay daily reward (at most once per stake per day)
+      const [reward] = await db.insert(btcStakingRewards).values({
         stakeId: stake.id,
         userId: stake.userId,
         rewardAmount: stake.dailyReward,
         btcPrice: currentBtcPrice,
-      });
+        rewardDate,
+      }).onConflictDoNothing().returning();
+
+      if (!reward) continue;
 
       // Update user BTC balance
       const user = await this.getUser(stake.userId);
@@ -1,5 +1,5 @@
 import { sql } from "drizzle-orm";
-import { pgTable, text, varchar, decimal, timestamp, integer, boolean, uuid } from "drizzle-orm/pg-core";
+import { pgTable, text, varchar, decimal, timestamp, integer, boolean, uuid, date, unique, uniqueIndex, index } from "drizzle-orm/pg-core";
 import { relations } from "drizzle-orm";
 import { createInsertSchema } from "drizzle-zod";
 import { z } from "zod";
@@ -24,8 +24,12 @@ export const users = pgTable("users", {
   isFrozen: boolean("is_frozen").default(false),
   isBanned: boolean("is_banned").default(false),
   hasStartedMining: boolean("has_started_mining").default(false),
+  miningPausedAt: timestamp("mining_paused_at"), // Set while an admin has paused the user's mining
+  miningPauseReason: text("mining_pause_reason"),
+  miningPausedBy: uuid("mining_paused_by"), // Admin who paused mining
   kycVerified: boolean("kyc_verified").default(false),
   kycVerificationHash: text("kyc_verification_hash"), // Stores verification hash from KYC process
+  kycTier: integer("kyc_tier").default(0), // 0 unverified, 1 basic, 2 advanced
   createdAt: timestamp("created_at").defaultNow(),
 });
 
@@ -36,12 +40,52 @@ export const deposits = pgTable("deposits", {
   txHash: text("tx_hash").notNull().unique(),
   amount: decimal("amount", { precision: 18, scale: 8 }).notNull(),
   currency: text("currency").notNull().default("USDT"), // "USDT" or "ETH"
+  address: text("address"), // Address the funds were sent to, for deposits detected on chain
   status: text("status").notNull().default("pending"), // "pending", "approved", "rejected"
   adminNote: text("admin_note"),
   createdAt: timestamp("created_at").defaultNow(),
   updatedAt: timestamp("updated_at").defaultNow(),
 });
 
+// Admin-managed deposit addresses; each network has one active (unretired)
+// address and keeps the retired ones as rotation history
+export const depositAddresses = pgTable("deposit_addresses", {
+  id: uuid("id").primaryKey().default(sql`gen_random_uuid()`),
+  network: text("network").notNull(), // "BSC", "ETH", "TRC20", "APTOS", "BTC"
+  currency: text("currency").notNull(), // "USDT" or "BTC"
+  address: text("address").notNull(),
+  memoRequired: boolean("memo_required").default(false).notNull(), // Deposits must carry the user's deposit memo
+  reason: text("reason"),
+  createdBy: uuid("created_by").references(() => users.id),
+  createdAt: timestamp("created_at").defaultNow().notNull(),
+  retiredBy: uuid("retired_by").references(() => users.id),
+  retiredAt: timestamp("retired_at"),
+}, (table) => [
+  uniqueIndex("deposit_addresses_active_network_unique").on(table.network).where(sql`retired_at IS NULL`),
+]);
+
+// Per-user memo matching deposits to networks with a shared address
+export const depositMemos = pgTable("deposit_memos", {
+  userId: uuid("user_id").primaryKey().references(() => users.id),
+  memo: text("memo").notNull().unique(),
+  createdAt: timestamp("created_at").defaultNow().notNull(),
+});
+
+// Per-user deposit addresses derived from an account xpub at m/0/derivationIndex
+export const userDepositAddresses = pgTable("user_deposit_addresses", {
+  id: uuid("id").primaryKey().default(sql`gen_random_uuid()`),
+  userId: uuid("user_id").references(() => users.id).notNull(),
+  chain: text("chain").notNull(), // "BTC" or "EVM" (shared by BSC and ETH)
+  keyId: text("key_id").notNull(), // hash160 of the xpub the address was derived from
+  derivationIndex: integer("derivation_index").notNull(),
+  address: text("address").notNull(),
+  createdAt: timestamp("created_at").defaultNow().notNull(),
+}, (table) => [
+  unique("user_deposit_addresses_user_unique").on(table.userId, table.chain, table.keyId),
+  unique("user_deposit_addresses_index_unique").on(table.chain, table.keyId, table.derivationIndex),
+  unique("user_deposit_addresses_address_unique").on(table.chain, table.address),
+]);
+
 export const withdrawals = pgTable("withdrawals", {
   id: uuid("id").primaryKey().default(sql`gen_random_uuid()`),
   userId: uuid("user_id").references(() => users.id).notNull(),
@@ -58,13 +102,18 @@ export const unclaimedBlocks = pgTable("unclaimed_blocks", {
   id: uuid("id").primaryKey().default(sql`gen_random_uuid()`),
   userId: uuid("user_id").references(() => users.id).notNull(),
   blockNumber: integer("block_number").notNull(),
+  blockHeight: integer("block_height"), // Height of the mining block the reward came from
   txHash: text("tx_hash").notNull(),
   reward: decimal("reward", { precision: 18, scale: 8 }).notNull(),
   expiresAt: timestamp("expires_at").notNull(),
   claimed: boolean("claimed").default(false),
+  expired: boolean("expired").default(false), // Set once the unclaimed reward is removed from unclaimed_balance
   claimedAt: timestamp("claimed_at"),
   createdAt: timestamp("created_at").defaultNow(),
-});
+}, (table) => [
+  index("unclaimed_blocks_block_height_idx").on(table.blockHeight),
+  index("unclaimed_blocks_tx_hash_lower_idx").on(sql`lower(${table.txHash})`), // Explorer looks hashes up case-insensitively
+]);
 
 export const minerActivity = pgTable("miner_activity", {
   id: uuid("id").primaryKey().default(sql`gen_random_uuid()`),
@@ -72,7 +121,8 @@ export const minerActivity = pgTable("miner_activity", {
   lastClaimTime: timestamp("last_claim_time"),
   totalClaims: integer("total_claims").default(0),
   missedClaims: integer("missed_claims").default(0),
-  isActive: boolean("is_active").default(true),
+  isActive: boolean("is_active").default(true), // false once missed_claims reaches maxMissedClaims; excluded from rewards
+  resumedAt: timestamp("resumed_at"), // Last time an inactive miner restarted mining
   updatedAt: timestamp("updated_at").defaultNow(),
 });
 
@@ -82,12 +132,14 @@ export const transfers = pgTable("transfers", {
   toUserId: uuid("to_user_id").references(() => users.id).notNull(),
   amount: decimal("amount", { precision: 18, scale: 8 }).notNull(),
   txHash: text("tx_hash").notNull(),
+  memo: text("memo"), // Optional note from the sender
   createdAt: timestamp("created_at").defaultNow(),
 });
 
 export const miningBlocks = pgTable("mining_blocks", {
   id: uuid("id").primaryKey().default(sql`gen_random_uuid()`),
-  blockNumber: integer("block_number").notNull(),
+  blockNumber: integer("block_number").notNull(), // Daily block number, resets at 00:00 UTC
+  height: integer("height").unique(), // Total block height, never resets
   reward: decimal("reward", { precision: 18, scale: 8 }).notNull(),
   totalHashPower: decimal("total_hash_power", { precision: 10, scale: 2 }).notNull(),
   timestamp: timestamp("timestamp").defaultNow(),
@@ -107,7 +159,7 @@ export const miningStats = pgTable("mining_stats", {
 
 export const userMiningStats = pgTable("user_mining_stats", {
   id: uuid("id").primaryKey().default(sql`gen_random_uuid()`),
-  userId: uuid("user_id").references(() => users.id).notNull(),
+  userId: uuid("user_id").references(() => users.id).notNull().unique(),
   totalHashPower: decimal("total_hash_power", { precision: 10, scale: 2 }).default("0.00"),
   totalMined: decimal("total_mined", { precision: 18, scale: 8 }).default("0.00000000"),
   totalClaimed: decimal("total_claimed", { precision: 18, scale: 8 }).default("0.00000000"),
@@ -124,6 +176,44 @@ export const systemSettings = pgTable("system_settings", {
   updatedAt: timestamp("updated_at").defaultNow(),
 });
 
+// Audit trail of admin changes to system settings
+export const settingChanges = pgTable("setting_changes", {
+  id: uuid("id").primaryKey().default(sql`gen_random_uuid()`),
+  key: text("key").notNull(),
+  oldValue: text("old_value"), // Null when the setting was unset
+  newValue: text("new_value"), // Null when the setting was reset to its default
+  changedBy: uuid("changed_by").references(() => users.id),
+  reason: text("reason"),
+  createdAt: timestamp("created_at").defaultNow().notNull(),
+});
+
+export const hashPowerContracts = pgTable("hash_power_contracts", {
+  id: uuid("id").primaryKey().default(sql`gen_random_uuid()`),
+  userId: uuid("user_id").references(() => users.id).notNull(),
+  hashPower: decimal("hash_power", { precision: 10, scale: 2 }).notNull(),
+  usdtCost: decimal("usdt_cost", { precision: 10, scale: 2 }).notNull(),
+  unitPrice: decimal("unit_price", { precision: 18, scale: 8 }).notNull(), // Effective USDT price per unit after discount
+  discountPercent: decimal("discount_percent", { precision: 5, scale: 2 }).default("0.00"), // Volume tier discount
+  durationDays: integer("duration_days"), // null for permanent hash power
+  autoRenew: boolean("auto_renew").default(false), // Renew from USDT balance at expiry
+  renewalCount: integer("renewal_count").default(0),
+  status: text("status").notNull().default("active"), // "active", "expired"
+  startsAt: timestamp("starts_at").defaultNow(),
+  expiresAt: timestamp("expires_at"), // null for permanent hash power
+  createdAt: timestamp("created_at").defaultNow(),
+});
+
+// One row per auto-renewal charge, so the USDT debit shows in the transaction feed
+export const hashPowerContractRenewals = pgTable("hash_power_contract_renewals", {
+  id: uuid("id").primaryKey().default(sql`gen_random_uuid()`),
+  contractId: uuid("contract_id").references(() => hashPowerContracts.id).notNull(),
+  userId: uuid("user_id").references(() => users.id).notNull(),
+  usdtCost: decimal("usdt_cost", { precision: 10, scale: 2 }).notNull(),
+  hashPower: decimal("hash_power", { precision: 10, scale: 2 }).notNull(),
+  expiresAt: timestamp("expires_at").notNull(), // Expiry after this renewal
+  renewedAt: timestamp("renewed_at").defaultNow(),
+});
+
 // BTC Staking Tables
 export const btcStakes = pgTable("btc_stakes", {
   id: uuid("id").primaryKey().default(sql`gen_random_uuid()`),
@@ -138,6 +228,8 @@ export const btcStakes = pgTable("btc_stakes", {
   unlockAt: timestamp("unlock_at").notNull(), // 1 year from stake date
   status: text("status").notNull().default("active"), // "active", "completed", "cancelled"
   lastRewardAt: timestamp("last_reward_at"),
+  penaltyAmount: decimal("penalty_amount", { precision: 18, scale: 8 }).default("0.00000000"), // BTC forfeited on early cancel
+  endedAt: timestamp("ended_at"), // When the stake completed or was cancelled
   createdAt: timestamp("created_at").defaultNow(),
 });
 
@@ -147,8 +239,11 @@ export const btcStakingRewards = pgTable("btc_staking_rewards", {
   userId: uuid("user_id").references(() => users.id).notNull(),
   rewardAmount: decimal("reward_amount", { precision: 18, scale: 8 }).notNull(),
   btcPrice: decimal("btc_price", { precision: 10, scale: 2 }).notNull(),
+  rewardDate: date("reward_date").notNull(), // UTC day the reward covers; one reward per stake per day
   paidAt: timestamp("paid_at").defaultNow(),
-});
+}, (table) => [
+  unique("btc_staking_rewards_stake_day_unique").on(table.stakeId, table.rewardDate),
+]);
 
 export const btcPriceHistory = pgTable("btc_price_history", {
   id: uuid("id").primaryKey().default(sql`gen_random_uuid()`),
@@ -157,6 +252,98 @@ export const btcPriceHistory = pgTable("btc_price_history", {
   timestamp: timestamp("timestamp").defaultNow(),
 });
 
+// Conversion Tables
+export const conversions = pgTable("conversions", {
+  id: uuid("id").primaryKey().default(sql`gen_random_uuid()`),
+  userId: uuid("user_id").references(() => users.id).notNull(),
+  quoteId: text("quote_id").notNull().unique(), // Signed quote this conversion executed; prevents replay
+  fromCurrency: text("from_currency").notNull(), // "USDT", "BTC", "GBTC"
+  toCurrency: text("to_currency").notNull(),
+  fromAmount: decimal("from_amount", { precision: 18, scale: 8 }).notNull(),
+  toAmount: decimal("to_amount", { precision: 18, scale: 8 }).notNull(), // Net of fee
+  rate: decimal("rate", { precision: 24, scale: 12 }).notNull(), // Units of toCurrency per fromCurrency
+  fee: decimal("fee", { precision: 18, scale: 8 }).notNull(), // Charged in toCurrency
+  createdAt: timestamp("created_at").defaultNow(),
+});
+
+// Scheduled Job Tables
+export const jobRuns = pgTable("job_runs", {
+  id: uuid("id").primaryKey().default(sql`gen_random_uuid()`),
+  jobName: text("job_name").notNull(),
+  scheduledFor: timestamp("scheduled_for").notNull(), // Schedule slot this run covers; manual runs use their start time
+  trigger: text("trigger").notNull(), // "schedule", "catchup", "manual", "simulation"
+  status: text("status").notNull(), // "running", "succeeded", "failed"
+  instance: text("instance").notNull(), // host:pid of the Go instance that ran the job
+  startedAt: timestamp("started_at").notNull(),
+  finishedAt: timestamp("finished_at"),
+  durationMs: integer("duration_ms"),
+  error: text("error"),
+}, (table) => [
+  unique("job_runs_job_scheduled_unique").on(table.jobName, table.scheduledFor),
+]);
+
+// Block Participation and Difficulty Tables
+// One row per block for every user with hash power; reason is null when eligible.
+// Rows older than the participationRetentionDays setting are pruned daily.
+export const blockParticipation = pgTable("block_participation", {
+  id: uuid("id").primaryKey().default(sql`gen_random_uuid()`),
+  blockHeight: integer("block_height").notNull(),
+  userId: uuid("user_id").references(() => users.id).notNull(),
+  hashPower: decimal("hash_power", { precision: 10, scale: 2 }).notNull(),
+  eligible: boolean("eligible").notNull(),
+  reason: text("reason"), // "paused", "stopped", "inactive"
+  reward: decimal("reward", { precision: 18, scale: 8 }).notNull().default("0.00000000"),
+  createdAt: timestamp("created_at").notNull(),
+}, (table) => [
+  unique("block_participation_block_user_unique").on(table.blockHeight, table.userId),
+  index("block_participation_user_height_idx").on(table.userId, table.blockHeight),
+  index("block_participation_created_at_idx").on(table.createdAt),
+]);
+
+// One row per difficulty retarget; total_hash_power is the window average
+export const difficultyAdjustments = pgTable("difficulty_adjustments", {
+  id: uuid("id").primaryKey().default(sql`gen_random_uuid()`),
+  height: integer("height").notNull().unique(), // Block height that closed the adjustment window
+  previousDifficulty: decimal("previous_difficulty", { precision: 10, scale: 2 }).notNull(),
+  difficulty: decimal("difficulty", { precision: 10, scale: 2 }).notNull(),
+  totalHashPower: decimal("total_hash_power", { precision: 15, scale: 2 }).notNull(),
+  avgBlockTime: integer("avg_block_time").notNull(), // seconds
+  rewardPerHash: decimal("reward_per_hash", { precision: 18, scale: 8 }).notNull(), // GBTC per unit of hash power per block
+  adjustedAt: timestamp("adjusted_at").notNull(),
+});
+
+// KYC Tables
+export const kycSubmissions = pgTable("kyc_submissions", {
+  id: uuid("id").primaryKey().default(sql`gen_random_uuid()`),
+  userId: uuid("user_id").references(() => users.id).notNull(),
+  tier: integer("tier").notNull(), // Requested tier: 1 basic, 2 advanced
+  fullName: text("full_name").notNull(),
+  dateOfBirth: date("date_of_birth").notNull(),
+  country: text("country").notNull(), // ISO 3166-1 alpha-2
+  documentType: text("document_type").notNull(), // "passport", "national_id", "drivers_license"
+  documentNumberHash: text("document_number_hash").notNull(), // SHA-256 of the document number, to detect reuse
+  documentLast4: text("document_last4").notNull(),
+  status: text("status").notNull().default("pending"), // "pending", "approved", "rejected"
+  rejectionReason: text("rejection_reason"),
+  reviewedBy: uuid("reviewed_by").references(() => users.id),
+  reviewedAt: timestamp("reviewed_at"),
+  createdAt: timestamp("created_at").defaultNow(),
+}, (table) => [
+  index("kyc_submissions_document_number_hash_idx").on(table.documentNumberHash),
+]);
+
+export const kycDocuments = pgTable("kyc_documents", {
+  id: uuid("id").primaryKey().default(sql`gen_random_uuid()`),
+  submissionId: uuid("submission_id").references(() => kycSubmissions.id).notNull(),
+  kind: text("kind").notNull(), // "documentFront", "documentBack", "selfie"
+  fileName: text("file_name").notNull(),
+  contentType: text("content_type").notNull(),
+  sizeBytes: integer("size_bytes").notNull(),
+  sha256: text("sha256").notNull(),
+  storageKey: text("storage_key").notNull(), // Key of the file in object storage
+  createdAt: timestamp("created_at").defaultNow(),
+});
+
 // Device Fingerprinting Tables
 export const devices = pgTable("devices", {
   id: uuid("id").primaryKey().default(sql`gen_random_uuid()`),
@@ -203,9 +390,16 @@ export const usersRelations = relations(users, ({ many, one }) => ({
   }),
   minerActivity: one(minerActivity),
   miningStats: one(userMiningStats),
+  hashPowerContracts: many(hashPowerContracts),
+  conversions: many(conversions),
   btcStakes: many(btcStakes),
   btcStakingRewards: many(btcStakingRewards),
+  kycSubmissions: many(kycSubmissions),
+  blockParticipation: many(blockParticipation),
   userDevices: many(userDevices),
+  settingChanges: many(settingChanges),
+  depositMemo: one(depositMemos),
+  depositAddresses: many(userDepositAddresses),
 }));
 
 export const depositsRelations = relations(deposits, ({ one }) => ({
@@ -256,6 +450,20 @@ export const userMiningStatsRelations = relations(userMiningStats, ({ one }) =>
   }),
 }));
 
+export const hashPowerContractsRelations = relations(hashPowerContracts, ({ one }) => ({
+  user: one(users, {
+    fields: [hashPowerContracts.userId],
+    references: [users.id],
+  }),
+}));
+
+export const conversionsRelations = relations(conversions, ({ one }) => ({
+  user: one(users, {
+    fields: [conversions.userId],
+    references: [users.id],
+  }),
+}));
+
 export const btcStakesRelations = relations(btcStakes, ({ one, many }) => ({
   user: one(users, {
     fields: [btcStakes.userId],
@@ -275,6 +483,28 @@ export const btcStakingRewardsRelations = relations(btcStakingRewards, ({ one })
   }),
 }));
 
+export const blockParticipationRelations = relations(blockParticipation, ({ one }) => ({
+  user: one(users, {
+    fields: [blockParticipation.userId],
+    references: [users.id],
+  }),
+}));
+
+export const kycSubmissionsRelations = relations(kycSubmissions, ({ one, many }) => ({
+  user: one(users, {
+    fields: [kycSubmissions.userId],
+    references: [users.id],
+  }),
+  documents: many(kycDocuments),
+}));
+
+export const kycDocumentsRelations = relations(kycDocuments, ({ one }) => ({
+  submission: one(kycSubmissions, {
+    fields: [kycDocuments.submissionId],
+    references: [kycSubmissions.id],
+  }),
+}));
+
 export const devicesRelations = relations(devices, ({ many }) => ({
   fingerprints: many(deviceFingerprints),
   userDevices: many(userDevices),
@@ -298,6 +528,34 @@ export const userDevicesRelations = relations(userDevices, ({ one }) => ({
   }),
 }));
 
+export const settingChangesRelations = relations(settingChanges, ({ one }) => ({
+  changedBy: one(users, {
+    fields: [settingChanges.changedBy],
+    references: [users.id],
+  }),
+}));
+
+export const depositAddressesRelations = relations(depositAddresses, ({ one }) => ({
+  createdBy: one(users, {
+    fields: [depositAddresses.createdBy],
+    references: [users.id],
+  }),
+}));
+
+export const depositMemosRelations = relations(depositMemos, ({ one }) => ({
+  user: one(users, {
+    fields: [depositMemos.userId],
+    references: [users.id],
+  }),
+}));
+
+export const userDepositAddressesRelations = relations(userDepositAddresses, ({ one }) => ({
+  user: one(users, {
+    fields: [userDepositAddresses.userId],
+    references: [users.id],
+  }),
+}));
+
 export const insertUserSchema = createInsertSchema(users).omit({
   id: true,
   createdAt: true,
@@ -315,8 +573,12 @@ export const insertUserSchema = createInsertSchema(users).omit({
   isFrozen: true,
   isBanned: true,
   hasStartedMining: true,
+  miningPausedAt: true,
+  miningPauseReason: true,
+  miningPausedBy: true,
   kycVerified: true,
   kycVerificationHash: true,
+  kycTier: true,
 }).extend({
   accessKey: z.string().regex(/^B2B-[A-Z0-9]{5}-[A-Z0-9]{5}-[A-Z0-9]{5}-[A-Z0-9]{5}$/, "Access key must be in format B2B-XXXXX-XXXXX-XXXXX-XXXXX"),
 });
@@ -352,6 +614,7 @@ export const insertUnclaimedBlockSchema = createInsertSchema(unclaimedBlocks).om
   userId: true,
   claimed: true,
   claimedAt: true,
+  expired: true,
   createdAt: true,
 });
 
@@ -405,6 +668,10 @@ export type Withdrawal = typeof withdrawals.$inferSelect;
 export type InsertWithdrawal = z.infer<typeof insertWithdrawalSchema>;
 export type MiningBlock = typeof miningBlocks.$inferSelect;
 export type SystemSetting = typeof systemSettings.$inferSelect;
+export type SettingChange = typeof settingChanges.$inferSelect;
+export type DepositAddress = typeof depositAddresses.$inferSelect;
+export type DepositMemo = typeof depositMemos.$inferSelect;
+export type UserDepositAddress = typeof userDepositAddresses.$inferSelect;
 export type MiningStats = typeof miningStats.$inferSelect;
 export type Transfer = typeof transfers.$inferSelect;
 export type MinerActivity = typeof minerActivity.$inferSelect;
@@ -412,12 +679,20 @@ export type UserMiningStats = typeof userMiningStats.$inferSelect;
 export type UnclaimedBlock = typeof unclaimedBlocks.$inferSelect;
 export type InsertUnclaimedBlock = z.infer<typeof insertUnclaimedBlockSchema>;
 export type InsertTransfer = z.infer<typeof insertTransferSchema>;
+export type HashPowerContract = typeof hashPowerContracts.$inferSelect;
+export type HashPowerContractRenewal = typeof hashPowerContractRenewals.$inferSelect;
+export type Conversion = typeof conversions.$inferSelect;
 export type BtcStake = typeof btcStakes.$inferSelect;
 export type InsertBtcStake = z.infer<typeof insertBtcStakeSchema>;
 export type BtcStakingReward = typeof btcStakingRewards.$inferSelect;
 export type InsertBtcStakingReward = z.infer<typeof insertBtcStakingRewardSchema>;
 export type BtcPriceHistory = typeof btcPriceHistory.$inferSelect;
 export type InsertBtcPriceHistory = z.infer<typeof insertBtcPriceHistorySchema>;
+export type JobRun = typeof jobRuns.$inferSelect;
+export type BlockParticipation = typeof blockParticipation.$inferSelect;
+export type DifficultyAdjustment = typeof difficultyAdjustments.$inferSelect;
+export type KycSubmission = typeof kycSubmissions.$inferSelect;
+export type KycDocument = typeof kycDocuments.$inferSelect;
 export type Device = typeof devices.$inferSelect;
 export type InsertDevice = z.infer<typeof insertDeviceSchema>;
 export type DeviceFingerprint = typeof deviceFingerprints.$inferSelect;